@@ -3,8 +3,10 @@ package main
 import (
 	"log"
 	"os"
+	"time"
 
 	apiflag "github.com/dictyBase/aphgrpc"
+	"github.com/dictyBase/modware-auth/internal/app/client"
 	"github.com/dictyBase/modware-auth/internal/app/generate"
 	"github.com/dictyBase/modware-auth/internal/app/server"
 	"github.com/dictyBase/modware-auth/internal/app/validate"
@@ -51,6 +53,46 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:  "client",
+			Usage: "manage the confidential clients of the client credentials grant",
+			Subcommands: []cli.Command{
+				{
+					Name:   "add",
+					Usage:  "register a client or replace its secret, scopes and ttl",
+					Action: client.AddClient,
+					Before: validate.ClientArgs,
+					Flags: append(getClientFlags(),
+						cli.StringFlag{
+							Name:  "secret",
+							Usage: "secret of the client, generated if not given",
+						},
+						cli.StringFlag{
+							Name:  "scopes",
+							Usage: "comma separated list of the scopes the client may request",
+						},
+						cli.DurationFlag{
+							Name:  "ttl",
+							Usage: "lifetime of the tokens of the client, defaults to the lifetime of access tokens",
+						},
+					),
+				},
+				{
+					Name:   "list",
+					Usage:  "list the registered clients",
+					Action: client.ListClients,
+					Before: validate.ClientArgs,
+					Flags:  getRepositoryFlags(),
+				},
+				{
+					Name:   "remove",
+					Usage:  "remove a client, its issued tokens stay valid until they expire",
+					Action: client.RemoveClient,
+					Before: validate.ClientArgs,
+					Flags:  getClientFlags(),
+				},
+			},
+		},
 	}
 	if err := app.Run(os.Args); err != nil {
 		log.Fatalf("error in running command %s", err)
@@ -61,11 +103,33 @@ func getServerFlags() []cli.Flag {
 	var f []cli.Flag
 	f = append(f, authFlags()...)
 	f = append(f, grpcFlags()...)
+	f = append(f, repositoryFlags()...)
 	f = append(f, redisFlags()...)
+	f = append(f, postgresFlags()...)
 	f = append(f, commonFlags()...)
+	f = append(f, topicFlags()...)
+	f = append(f, messagingFlags()...)
+	f = append(f, tracingFlags()...)
+	f = append(f, tlsFlags()...)
+	f = append(f, rateLimitFlags()...)
+	f = append(f, auditFlags()...)
 	return append(f, apiflag.NatsFlag()...)
 }
 
+func getRepositoryFlags() []cli.Flag {
+	var f []cli.Flag
+	f = append(f, repositoryFlags()...)
+	f = append(f, redisFlags()...)
+	return append(f, postgresFlags()...)
+}
+
+func getClientFlags() []cli.Flag {
+	return append(getRepositoryFlags(), cli.StringFlag{
+		Name:  "id",
+		Usage: "id of the client",
+	})
+}
+
 func commonFlags() []cli.Flag {
 	return []cli.Flag{
 		cli.StringFlag{
@@ -73,6 +137,49 @@ func commonFlags() []cli.Flag {
 			Usage: "tcp port at which the server will be available",
 			Value: "9560",
 		},
+		cli.StringFlag{
+			Name:  "health-port",
+			Usage: "tcp port of the http /healthz, /readyz and /metrics endpoints",
+			Value: "9561",
+		},
+		cli.StringFlag{
+			Name:  "rest-port",
+			Usage: "tcp port of the http/json endpoints of the auth service",
+			Value: "9562",
+		},
+		cli.StringFlag{
+			Name:   "trusted-proxies",
+			EnvVar: "TRUSTED_PROXIES",
+			Usage:  "comma separated addresses or networks of the proxies whose x-forwarded-for header gives the client address",
+		},
+		cli.StringFlag{
+			Name:   "cors-origins",
+			EnvVar: "CORS_ALLOWED_ORIGINS",
+			Usage:  "comma separated web origins allowed to call the http/json endpoints, * allows every origin",
+		},
+		cli.BoolFlag{
+			Name:  "session-cookie",
+			Usage: "serve the http/json endpoints that keep the refresh token in an HttpOnly cookie",
+		},
+		cli.StringFlag{
+			Name:  "cookie-domain",
+			Usage: "domain of the session cookies, by default the host of the request",
+		},
+		cli.StringFlag{
+			Name:  "cookie-same-site",
+			Usage: "SameSite attribute of the session cookies, either of strict, lax or none",
+			Value: "strict",
+		},
+		cli.DurationFlag{
+			Name:  "health-interval",
+			Usage: "interval for checking the dependencies",
+			Value: 10 * time.Second,
+		},
+		cli.DurationFlag{
+			Name:  "shutdown-timeout",
+			Usage: "time to wait for requests in flight before the server stops",
+			Value: 25 * time.Second,
+		},
 	}
 }
 
@@ -101,6 +208,282 @@ func grpcFlags() []cli.Flag {
 	}
 }
 
+func repositoryFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "repository",
+			Usage: "storage backend for the tokens, either of redis or postgres",
+			Value: "redis",
+		},
+	}
+}
+
+func postgresFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:   "postgres-host",
+			EnvVar: "POSTGRES_SERVICE_HOST",
+			Usage:  "postgres database host",
+		},
+		cli.StringFlag{
+			Name:   "postgres-port",
+			EnvVar: "POSTGRES_SERVICE_PORT",
+			Usage:  "postgres database port",
+		},
+		cli.StringFlag{
+			Name:   "postgres-user",
+			EnvVar: "POSTGRES_USER",
+			Usage:  "postgres database user",
+		},
+		cli.StringFlag{
+			Name:   "postgres-password",
+			EnvVar: "POSTGRES_PASSWORD",
+			Usage:  "postgres database password",
+		},
+		cli.StringFlag{
+			Name:   "postgres-database",
+			EnvVar: "POSTGRES_DB",
+			Usage:  "postgres database name",
+		},
+		cli.StringFlag{
+			Name:   "postgres-sslmode",
+			EnvVar: "POSTGRES_SSLMODE",
+			Usage:  "ssl mode of the postgres connection",
+			Value:  "disable",
+		},
+		cli.DurationFlag{
+			Name:  "postgres-sweep-interval",
+			Usage: "interval for removing expired tokens from postgres",
+			Value: 5 * time.Minute,
+		},
+	}
+}
+
+func messagingFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "messaging",
+			Usage: "publisher for the events, either of nats, jetstream, kafka or none",
+			Value: "nats",
+		},
+		cli.StringFlag{
+			Name:  "event-format",
+			Usage: "payload of the cloudevents, either of protobuf or json",
+			Value: "protobuf",
+		},
+		cli.StringFlag{
+			Name:  "event-source",
+			Usage: "source attribute of the cloudevents",
+			Value: "modware-auth",
+		},
+		cli.StringFlag{
+			Name:  "jetstream-stream",
+			Usage: "jetstream stream for the events, created if missing",
+			Value: "AUTH_EVENTS",
+		},
+		cli.IntFlag{
+			Name:  "jetstream-buffer",
+			Usage: "maximum number of buffered events while jetstream is unreachable",
+			Value: 1000,
+		},
+		cli.StringFlag{
+			Name:   "kafka-brokers",
+			EnvVar: "KAFKA_BROKERS",
+			Usage:  "comma separated addresses of the kafka brokers",
+		},
+		cli.StringFlag{
+			Name:  "kafka-topic",
+			Usage: "kafka topic for all events, by default every subject is its own topic",
+		},
+		cli.DurationFlag{
+			Name:  "outbox-interval",
+			Usage: "interval for relaying the events of the outbox",
+			Value: time.Second,
+		},
+		cli.IntFlag{
+			Name:  "outbox-batch",
+			Usage: "maximum number of events that are relayed at once",
+			Value: 100,
+		},
+	}
+}
+
+func auditFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "audit-sink",
+			Usage: "sink of the audit log, either of stdout, file, syslog or none",
+			Value: "stdout",
+		},
+		cli.StringFlag{
+			Name:   "audit-file",
+			EnvVar: "AUDIT_LOG_FILE",
+			Usage:  "file that the audit records are appended to",
+		},
+		cli.StringFlag{
+			Name:  "audit-syslog-network",
+			Usage: "network of the syslog server, either of udp or tcp, by default the local server is used",
+		},
+		cli.StringFlag{
+			Name:  "audit-syslog-address",
+			Usage: "host:port of the syslog server",
+		},
+	}
+}
+
+func rateLimitFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.BoolFlag{
+			Name:  "rate-limit",
+			Usage: "limit logins and refreshes and lock out identities after failed refreshes, requires redis",
+		},
+		cli.Float64Flag{
+			Name:  "ip-rate",
+			Usage: "logins and refreshes per second that are allowed for a client address",
+			Value: 1,
+		},
+		cli.IntFlag{
+			Name:  "ip-burst",
+			Usage: "logins and refreshes that a client address is allowed at once",
+			Value: 20,
+		},
+		cli.Float64Flag{
+			Name:  "identity-rate",
+			Usage: "refreshes per second that are allowed for an identity",
+			Value: 0.1,
+		},
+		cli.IntFlag{
+			Name:  "identity-burst",
+			Usage: "refreshes that an identity is allowed at once",
+			Value: 5,
+		},
+		cli.IntFlag{
+			Name:  "lockout-threshold",
+			Usage: "failed refreshes after which the refreshes of an identity are locked",
+			Value: 5,
+		},
+		cli.DurationFlag{
+			Name:  "lockout-base",
+			Usage: "first lockout of an identity, doubled with every further failure",
+			Value: time.Minute,
+		},
+		cli.DurationFlag{
+			Name:  "lockout-max",
+			Usage: "longest lockout of an identity",
+			Value: time.Hour,
+		},
+	}
+}
+
+func tlsFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:   "tls-cert",
+			EnvVar: "TLS_CERT_FILE",
+			Usage:  "certificate file of the grpc server, enables tls",
+		},
+		cli.StringFlag{
+			Name:   "tls-key",
+			EnvVar: "TLS_KEY_FILE",
+			Usage:  "private key file of the grpc server",
+		},
+		cli.StringFlag{
+			Name:   "tls-client-ca",
+			EnvVar: "TLS_CLIENT_CA_FILE",
+			Usage:  "certificate authorities for verifying the clients, enables mtls",
+		},
+		cli.DurationFlag{
+			Name:  "tls-reload-interval",
+			Usage: "interval for checking the certificate files for changes",
+			Value: 30 * time.Second,
+		},
+		cli.StringFlag{
+			Name:  "user-grpc-ca",
+			Usage: "certificate authorities for verifying the user grpc server, enables tls",
+		},
+		cli.StringFlag{
+			Name:  "user-grpc-cert",
+			Usage: "client certificate file for the user grpc server, enables tls",
+		},
+		cli.StringFlag{
+			Name:  "user-grpc-key",
+			Usage: "client private key file for the user grpc server",
+		},
+		cli.StringFlag{
+			Name:  "identity-grpc-ca",
+			Usage: "certificate authorities for verifying the identity grpc server, enables tls",
+		},
+		cli.StringFlag{
+			Name:  "identity-grpc-cert",
+			Usage: "client certificate file for the identity grpc server, enables tls",
+		},
+		cli.StringFlag{
+			Name:  "identity-grpc-key",
+			Usage: "client private key file for the identity grpc server",
+		},
+	}
+}
+
+func tracingFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "trace-exporter",
+			Usage: "exporter for the spans, either of otlp, stdout or none",
+			Value: "none",
+		},
+		cli.StringFlag{
+			Name:   "otlp-endpoint",
+			EnvVar: "OTEL_EXPORTER_OTLP_ENDPOINT",
+			Usage:  "host:port of the otlp grpc collector",
+			Value:  "localhost:4317",
+		},
+		cli.BoolFlag{
+			Name:  "otlp-insecure",
+			Usage: "connect to the otlp collector without tls",
+		},
+		cli.Float64Flag{
+			Name:  "trace-sample-ratio",
+			Usage: "fraction of the traces that are sampled, unless the caller decided",
+			Value: 1,
+		},
+	}
+}
+
+func topicFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "login-topic",
+			Usage: "subject for publishing successful logins",
+			Value: "AuthService.Create",
+		},
+		cli.StringFlag{
+			Name:  "refresh-topic",
+			Usage: "subject for publishing refreshed tokens",
+			Value: "AuthService.Refresh",
+		},
+		cli.StringFlag{
+			Name:  "failure-topic",
+			Usage: "subject for publishing failed logins and refreshes",
+			Value: "AuthService.Failure",
+		},
+		cli.StringFlag{
+			Name:  "logout-topic",
+			Usage: "subject for publishing logouts",
+			Value: "AuthService.Logout",
+		},
+		cli.StringFlag{
+			Name:  "revoke-topic",
+			Usage: "subject for publishing sessions revoked by the user",
+			Value: "AuthService.Revoke",
+		},
+		cli.StringFlag{
+			Name:  "admin-topic",
+			Usage: "subject for publishing the actions of administrators",
+			Value: "AuthService.Admin",
+		},
+	}
+}
+
 func redisFlags() []cli.Flag {
 	return []cli.Flag{
 		cli.StringFlag{
@@ -113,6 +496,12 @@ func redisFlags() []cli.Flag {
 			EnvVar: "REDIS_MASTER_SERVICE_PORT",
 			Usage:  "redis master grpc port",
 		},
+		cli.StringFlag{
+			Name:   "redis-namespace",
+			EnvVar: "REDIS_NAMESPACE",
+			Usage:  "prefix for all redis keys, allows sharing the redis instance",
+			Value:  "modware-auth",
+		},
 	}
 }
 
@@ -133,5 +522,30 @@ func authFlags() []cli.Flag {
 			Usage:  "private key file for signing jwt",
 			EnvVar: "JWT_PRIVATE_KEY",
 		},
+		cli.StringFlag{
+			Name:  "admin-role",
+			Usage: "role that is required for using the admin service",
+			Value: "admin",
+		},
+		cli.StringFlag{
+			Name:  "admin-audience",
+			Usage: "audience of the access tokens that are accepted by the admin service",
+			Value: "admin",
+		},
+		cli.StringFlag{
+			Name:   "scope-policy",
+			Usage:  "policy of the audiences and scopes that the clients may request",
+			EnvVar: "SCOPE_POLICY",
+		},
+		cli.IntFlag{
+			Name:  "max-claims-size",
+			Usage: "bytes of roles and permissions above which the permissions are left out of access tokens, no limit if 0",
+			Value: 4096,
+		},
+		cli.DurationFlag{
+			Name:  "permission-cache-ttl",
+			Usage: "how long the permissions of the roles are cached for new logins, not cached if 0",
+			Value: time.Minute,
+		},
 	}
 }
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dictyBase/modware-auth/internal/app/server"
+	"github.com/dictyBase/modware-auth/internal/repository"
+	"github.com/urfave/cli"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// secretSize is the number of random bytes of a generated secret
+const secretSize = 32
+
+// AddClient registers a confidential client and prints its secret, which
+// is generated unless given. Adding an existing client replaces its
+// secret, scopes and TTL.
+func AddClient(c *cli.Context) error {
+	secret := c.String("secret")
+	if len(secret) == 0 {
+		s, err := generateSecret()
+		if err != nil {
+			return cli.NewExitError(fmt.Sprintf("unable to generate secret %q", err), 2)
+		}
+		secret = s
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("unable to hash secret %q", err), 2)
+	}
+	repo, err := server.GetRepository(c)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	defer repo.Close() //nolint:errcheck
+	cl := &repository.Client{
+		ID:         c.String("id"),
+		SecretHash: string(hash),
+		Scopes:     splitScopes(c.String("scopes")),
+		TTL:        c.Duration("ttl"),
+		CreatedAt:  time.Now(),
+	}
+	existing, err := repo.GetClient(cl.ID)
+	switch {
+	case err == nil:
+		cl.CreatedAt = existing.CreatedAt
+	case !errors.Is(err, repository.ErrClientNotFound):
+		return cli.NewExitError(fmt.Sprintf("unable to get client %q", err), 2)
+	}
+	if err := repo.SetClient(cl); err != nil {
+		return cli.NewExitError(fmt.Sprintf("unable to store client %q", err), 2)
+	}
+	fmt.Fprintf(c.App.Writer, "client_id: %s\nclient_secret: %s\n", cl.ID, secret)
+	return nil
+}
+
+// ListClients prints the registered clients
+func ListClients(c *cli.Context) error {
+	repo, err := server.GetRepository(c)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	defer repo.Close() //nolint:errcheck
+	cl, err := repo.ListClients()
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("unable to list clients %q", err), 2)
+	}
+	w := tabwriter.NewWriter(c.App.Writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSCOPES\tTTL\tCREATED")
+	for _, cr := range cl {
+		ttl := "default"
+		if cr.TTL > 0 {
+			ttl = cr.TTL.String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			cr.ID, strings.Join(cr.Scopes, ","), ttl,
+			cr.CreatedAt.UTC().Format(time.RFC3339),
+		)
+	}
+	return w.Flush()
+}
+
+// RemoveClient deletes a registered client, the tokens it was issued stay
+// valid until they expire
+func RemoveClient(c *cli.Context) error {
+	repo, err := server.GetRepository(c)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 2)
+	}
+	defer repo.Close() //nolint:errcheck
+	if err := repo.DeleteClient(c.String("id")); err != nil {
+		return cli.NewExitError(fmt.Sprintf("unable to remove client %q", err), 2)
+	}
+	return nil
+}
+
+func generateSecret() (string, error) {
+	b := make([]byte, secretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func splitScopes(s string) []string {
+	scopes := make([]string, 0)
+	for _, sc := range strings.Split(s, ",") {
+		if sc = strings.TrimSpace(sc); len(sc) > 0 {
+			scopes = append(scopes, sc)
+		}
+	}
+	return scopes
+}
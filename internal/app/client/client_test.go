@@ -0,0 +1,85 @@
+package client
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dictyBase/modware-auth/internal/repository"
+	"github.com/dictyBase/modware-auth/internal/repository/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestApp(out *bytes.Buffer) *cli.App {
+	flags := []cli.Flag{
+		cli.StringFlag{Name: "repository", Value: "redis"},
+		cli.StringFlag{Name: "redis-master-service-host"},
+		cli.StringFlag{Name: "redis-master-service-port"},
+		cli.StringFlag{Name: "redis-namespace", Value: "modware-auth"},
+		cli.StringFlag{Name: "id"},
+		cli.StringFlag{Name: "secret"},
+		cli.StringFlag{Name: "scopes"},
+		cli.DurationFlag{Name: "ttl"},
+	}
+	// the exit errors of the commands should not end the test
+	cli.OsExiter = func(int) {}
+	app := cli.NewApp()
+	app.Writer = out
+	app.Commands = []cli.Command{
+		{Name: "add", Action: AddClient, Flags: flags},
+		{Name: "list", Action: ListClients, Flags: flags},
+		{Name: "remove", Action: RemoveClient, Flags: flags},
+	}
+	return app
+}
+
+func TestClientCommands(t *testing.T) {
+	assert := assert.New(t)
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("unable to start miniredis %s", err)
+	}
+	defer mr.Close()
+	repo, err := redis.NewAuthRepo(mr.Addr(), "modware-auth")
+	assert.NoError(err, "error in connecting to redis")
+	defer repo.Close() //nolint:errcheck
+	out := &bytes.Buffer{}
+	app := newTestApp(out)
+	run := func(args ...string) error {
+		out.Reset()
+		return app.Run(append(append([]string{"modware-auth"}, args...),
+			"--redis-master-service-host", mr.Host(),
+			"--redis-master-service-port", mr.Port(),
+		))
+	}
+	assert.NoError(run("add", "--id", "loader", "--scopes", "read:stock, write:stock", "--ttl", "1h"), "error in adding client")
+	assert.Contains(out.String(), "client_id: loader", "should print client id")
+	var secret string
+	for _, l := range strings.Split(out.String(), "\n") {
+		if strings.HasPrefix(l, "client_secret: ") {
+			secret = strings.TrimPrefix(l, "client_secret: ")
+		}
+	}
+	assert.NotEmpty(secret, "should print generated secret")
+	c, err := repo.GetClient("loader")
+	assert.NoError(err, "error in getting client")
+	assert.NoError(bcrypt.CompareHashAndPassword([]byte(c.SecretHash), []byte(secret)), "should store hash of secret")
+	assert.Equal([]string{"read:stock", "write:stock"}, c.Scopes, "should store scopes")
+	assert.Equal(time.Hour, c.TTL, "should store ttl")
+	assert.NoError(run("add", "--id", "loader", "--secret", "rotated"), "error in replacing client")
+	rc, err := repo.GetClient("loader")
+	assert.NoError(err, "error in getting client")
+	assert.NoError(bcrypt.CompareHashAndPassword([]byte(rc.SecretHash), []byte("rotated")), "should replace secret")
+	assert.True(c.CreatedAt.Equal(rc.CreatedAt), "should keep creation time")
+	assert.NoError(run("list"), "error in listing clients")
+	assert.Contains(out.String(), "loader", "should list client")
+	assert.NotContains(out.String(), rc.SecretHash, "should not list secret")
+	assert.NoError(run("remove", "--id", "loader"), "error in removing client")
+	_, err = repo.GetClient("loader")
+	assert.ErrorIs(err, repository.ErrClientNotFound, "should remove client")
+	assert.Error(run("remove", "--id", "loader"), "should fail to remove unknown client")
+}
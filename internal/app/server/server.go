@@ -1,14 +1,22 @@
 package server
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/dictyBase/modware-auth/internal/health"
 	"github.com/dictyBase/modware-auth/internal/jwtauth"
 
 	"github.com/dictyBase/aphgrpc"
@@ -16,25 +24,48 @@ import (
 	"github.com/dictyBase/go-genproto/dictybaseapis/identity"
 	"github.com/dictyBase/go-genproto/dictybaseapis/user"
 	"github.com/dictyBase/modware-auth/internal/app/service"
+	"github.com/dictyBase/modware-auth/internal/audit"
+	"github.com/dictyBase/modware-auth/internal/authapi"
 	"github.com/dictyBase/modware-auth/internal/message"
+	"github.com/dictyBase/modware-auth/internal/message/cloudevent"
+	"github.com/dictyBase/modware-auth/internal/message/jetstream"
+	"github.com/dictyBase/modware-auth/internal/message/kafka"
 	"github.com/dictyBase/modware-auth/internal/message/nats"
+	"github.com/dictyBase/modware-auth/internal/message/noop"
+	"github.com/dictyBase/modware-auth/internal/message/outbox"
+	"github.com/dictyBase/modware-auth/internal/metrics"
 	"github.com/dictyBase/modware-auth/internal/oauth"
+	"github.com/dictyBase/modware-auth/internal/ratelimit"
 	"github.com/dictyBase/modware-auth/internal/repository"
+	"github.com/dictyBase/modware-auth/internal/repository/postgres"
 	"github.com/dictyBase/modware-auth/internal/repository/redis"
+	"github.com/dictyBase/modware-auth/internal/rest"
+	"github.com/dictyBase/modware-auth/internal/tlsconfig"
+	"github.com/dictyBase/modware-auth/internal/tracing"
+	goredis "github.com/go-redis/redis/v7"
 	"github.com/golang-jwt/jwt"
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	grpc_logrus "github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus"
 	grpc_ctxtags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
-	gnats "github.com/nats-io/go-nats"
+	gnats "github.com/nats-io/nats.go"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/reflection"
 )
 
 type ClientsGRPC struct {
 	userClient     user.UserServiceClient
+	roleClient     user.RoleServiceClient
 	identityClient identity.IdentityServiceClient
+	userConn       *grpc.ClientConn
+	identityConn   *grpc.ClientConn
+	// reloaders of the client certificates
+	reloaders []*tlsconfig.Reloader
 }
 
 type Connections struct {
@@ -42,7 +73,48 @@ type Connections struct {
 	publisher message.Publisher
 }
 
+type serviceParams struct {
+	conns   *Connections
+	clients *ClientsGRPC
+	secrets *oauth.ProviderSecrets
+	jwtAuth *jwtauth.JWTAuth
+	logger  *logrus.Entry
+	topics  map[string]string
+	role    string
+	metrics *metrics.Metrics
+	lockout *ratelimit.Lockout
+	scopes  service.ScopePolicy
+	// audience of the access tokens of the admin service
+	adminAudience string
+	maxClaimsSize int
+	// how long the permissions of the roles are cached
+	permissionCacheTTL time.Duration
+}
+
 func RunServer(c *cli.Context) error {
+	tp, err := tracing.NewProvider(context.Background(), &tracing.Params{
+		Exporter:    c.String("trace-exporter"),
+		Endpoint:    c.String("otlp-endpoint"),
+		Insecure:    c.Bool("otlp-insecure"),
+		Service:     "modware-auth",
+		SampleRatio: c.Float64("trace-sample-ratio"),
+	})
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("Unable to set up tracing %q", err),
+			2,
+		)
+	}
+	m := metrics.New()
+	logger := getLogger(c)
+	// whatever has been set up is released when the server stops or its
+	// setup fails
+	sp := &shutdownParams{
+		tracing: tp,
+		timeout: c.Duration("shutdown-timeout"),
+		logger:  logger,
+	}
+	defer shutdown(sp)
 	conns, err := getConnections(c)
 	if err != nil {
 		return cli.NewExitError(
@@ -50,7 +122,11 @@ func RunServer(c *cli.Context) error {
 			2,
 		)
 	}
-	clients, err := connectToGRPC(c)
+	conns.authRepo = tracing.Repository(m.Repository(conns.authRepo))
+	sp.conns = conns
+	clients, err := connectToGRPC(c, m, logger)
+	sp.clients = clients
+	sp.certs = clients.reloaders
 	if err != nil {
 		return cli.NewExitError(
 			fmt.Sprintf("Unable to connect to grpc client %q", err),
@@ -64,30 +140,129 @@ func RunServer(c *cli.Context) error {
 			2,
 		)
 	}
+	scopes, err := readScopePolicy(c)
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("Unable to read scope policy %q", err),
+			2,
+		)
+	}
 	jt, err := parseJwtKeys(c)
 	if err != nil {
 		return cli.NewExitError(fmt.Sprintf("Unable to parse keys %q", err), 2)
 	}
-	grpcS := grpc.NewServer(
-		grpc.ChainUnaryInterceptor(
-			grpc_ctxtags.UnaryServerInterceptor(),
-			grpc_logrus.UnaryServerInterceptor(getLogger(c)),
-		),
+	limits, err := getRateLimits(c, *jt, logger)
+	sp.limits = limits
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("Unable to set up rate limits %q", err),
+			2,
+		)
+	}
+	auditor, auditInterceptor, err := getAudit(c, logger)
+	sp.auditor = auditor
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("Unable to set up audit log %q", err),
+			2,
+		)
+	}
+	proxies, err := service.ParseTrustedProxies(
+		strings.Split(c.String("trusted-proxies"), ","),
 	)
-	srv, err := service.NewAuthService(&service.ServiceParams{
-		Repository:      conns.authRepo,
-		Publisher:       conns.publisher,
-		User:            clients.userClient,
-		Identity:        clients.identityClient,
-		JWTAuth:         *jt,
-		ProviderSecrets: *config,
-		Options:         getGrpcOpt(),
-	},
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("Unable to parse trusted proxies %q", err),
+			2,
+		)
+	}
+	creds, serverCerts, err := getServerCredentials(c, logger)
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("Unable to load server certificate %q", err),
+			2,
+		)
+	}
+	sp.certs = append(clients.reloaders, serverCerts)
+	interceptors := []grpc.UnaryServerInterceptor{
+		// the client address is resolved for all of the following ones
+		proxies.UnaryServerInterceptor(),
+		grpc_ctxtags.UnaryServerInterceptor(),
+		grpc_logrus.UnaryServerInterceptor(logger),
+		m.GRPCServer.UnaryServerInterceptor(),
+	}
+	// rejections of the rate limits are audited as well
+	if auditInterceptor != nil {
+		interceptors = append(interceptors, auditInterceptor)
+	}
+	if limits.interceptor != nil {
+		interceptors = append(interceptors, limits.interceptor)
+	}
+	grpcS := grpc.NewServer(
+		grpc.Creds(creds),
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(interceptors...),
 	)
+	sp.grpcS = grpcS
+	authS, clientTokens, err := registerServices(grpcS, &serviceParams{
+		conns:              conns,
+		clients:            clients,
+		secrets:            config,
+		jwtAuth:            jt,
+		logger:             logger,
+		topics:             getTopics(c),
+		role:               c.String("admin-role"),
+		metrics:            m,
+		lockout:            limits.lockout,
+		scopes:             scopes,
+		adminAudience:      c.String("admin-audience"),
+		maxClaimsSize:      c.Int("max-claims-size"),
+		permissionCacheTTL: c.Duration("permission-cache-ttl"),
+	})
 	if err != nil {
 		return cli.NewExitError(err.Error(), 2)
 	}
-	auth.RegisterAuthServiceServer(grpcS, srv)
+	m.GRPCServer.InitializeMetrics(grpcS)
+	monitor, err := health.NewMonitor(&health.MonitorParams{
+		Checks:   getHealthChecks(conns, clients),
+		Optional: getOptionalHealthChecks(conns),
+		Services: []string{
+			auth.AuthService_ServiceDesc.ServiceName,
+			authapi.SessionService_ServiceDesc.ServiceName,
+			authapi.AdminService_ServiceDesc.ServiceName,
+			authapi.ClientService_ServiceDesc.ServiceName,
+		},
+		Interval: c.Duration("health-interval"),
+		Timeout:  5 * time.Second,
+		Logger:   logger,
+	})
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("Unable to create health monitor %q", err),
+			2,
+		)
+	}
+	monitor.Register(grpcS)
+	monitor.Start()
+	sp.monitor = monitor
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	mux.Handle("/", monitor.Handler())
+	healthS := &http.Server{
+		Addr:    fmt.Sprintf(":%s", c.String("health-port")),
+		Handler: mux,
+	}
+	sp.healthS = healthS
+	go serveHealth(healthS, logger)
+	restS, err := getRestServer(c, authS, clientTokens, interceptors, serverCerts)
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("Unable to create rest server %q", err),
+			2,
+		)
+	}
+	sp.restS = restS
+	go serveRest(restS, logger)
 	reflection.Register(grpcS)
 	endP := fmt.Sprintf(":%s", c.String("port"))
 	lis, err := net.Listen("tcp", endP)
@@ -96,13 +271,202 @@ func RunServer(c *cli.Context) error {
 			fmt.Sprintf("failed to listen %s", err), 2,
 		)
 	}
-	log.Printf("starting grpc server on %s", endP)
-	if err := grpcS.Serve(lis); err != nil {
+	relay, err := outbox.NewRelay(&outbox.RelayParams{
+		Outbox:    conns.authRepo,
+		Publisher: conns.publisher,
+		Logger:    logger,
+		Interval:  c.Duration("outbox-interval"),
+		BatchSize: c.Int("outbox-batch"),
+	})
+	if err != nil {
+		return cli.NewExitError(
+			fmt.Sprintf("Unable to create outbox relay %q", err),
+			2,
+		)
+	}
+	// the relay only starts once the server is about to serve
+	relay.Start()
+	sp.relay = relay
+	ctx, stop := signal.NotifyContext(
+		context.Background(), syscall.SIGTERM, os.Interrupt,
+	)
+	defer stop()
+	errc := make(chan error, 1)
+	go func() {
+		log.Printf("starting grpc server on %s", endP)
+		errc <- grpcS.Serve(lis)
+	}()
+	select {
+	case err := <-errc:
 		return cli.NewExitError(err.Error(), 2)
+	case <-ctx.Done():
+		logger.Info("received signal, shutting down")
 	}
 	return nil
 }
 
+type shutdownParams struct {
+	grpcS   *grpc.Server
+	healthS *http.Server
+	restS   *http.Server
+	monitor *health.Monitor
+	relay   *outbox.Relay
+	conns   *Connections
+	clients *ClientsGRPC
+	tracing *tracing.Provider
+	certs   []*tlsconfig.Reloader
+	limits  *rateLimits
+	auditor *audit.Logger
+	timeout time.Duration
+	logger  *logrus.Entry
+}
+
+// shutdown stops taking new requests, waits for the requests in flight
+// and then releases all connections. The outbox is relayed one last time
+// before the publisher is closed. Only the parts that have been set up
+// are stopped, so it also cleans up after a failed setup.
+func shutdown(p *shutdownParams) {
+	if p.monitor != nil {
+		p.monitor.Shutdown()
+	}
+	if p.restS != nil {
+		stopRest(p.restS, p.timeout, p.logger)
+	}
+	if p.grpcS != nil {
+		gracefulStop(p.grpcS, p.timeout, p.logger)
+	}
+	if p.relay != nil {
+		p.relay.Stop()
+	}
+	if p.auditor != nil {
+		if err := p.auditor.Close(); err != nil {
+			p.logger.WithError(err).Error("error in closing audit log")
+		}
+	}
+	for _, r := range p.certs {
+		r.Stop()
+	}
+	if p.conns != nil {
+		if err := p.conns.publisher.Close(); err != nil {
+			p.logger.WithError(err).Error("error in closing publisher")
+		}
+		if err := p.conns.authRepo.Close(); err != nil {
+			p.logger.WithError(err).Error("error in closing repository")
+		}
+	}
+	if p.limits != nil && p.limits.client != nil {
+		if err := p.limits.client.Close(); err != nil {
+			p.logger.WithError(err).Error("error in closing rate limit connection")
+		}
+	}
+	if p.clients != nil {
+		for _, conn := range []*grpc.ClientConn{
+			p.clients.userConn, p.clients.identityConn,
+		} {
+			if conn == nil {
+				continue
+			}
+			if err := conn.Close(); err != nil {
+				p.logger.WithError(err).Error("error in closing grpc connection")
+			}
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	if err := p.tracing.Shutdown(ctx); err != nil {
+		p.logger.WithError(err).Error("error in exporting the remaining spans")
+	}
+	if p.healthS != nil {
+		if err := p.healthS.Shutdown(ctx); err != nil {
+			p.logger.WithError(err).Error("error in stopping health server")
+		}
+	}
+}
+
+// stopRest waits for the rest requests in flight up to the timeout
+func stopRest(restS *http.Server, timeout time.Duration, logger *logrus.Entry) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := restS.Shutdown(ctx); err != nil {
+		logger.WithError(err).Error("error in stopping rest server")
+	}
+}
+
+// gracefulStop waits for the requests in flight up to the timeout and
+// then cancels the remaining ones
+func gracefulStop(grpcS *grpc.Server, timeout time.Duration, logger *logrus.Entry) {
+	done := make(chan struct{})
+	go func() {
+		grpcS.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		logger.Warn("shutdown timed out, cancelling requests in flight")
+		grpcS.Stop()
+	}
+}
+
+// creates and registers all grpc services of the server, the auth service
+// and the client tokens are returned for the rest endpoints
+func registerServices(grpcS *grpc.Server, p *serviceParams) (auth.AuthServiceServer, *service.ClientTokens, error) {
+	ct, err := service.NewClientTokens(&service.ClientTokenParams{
+		Registry: p.conns.authRepo,
+		JWTAuth:  *p.jwtAuth,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	srv, err := service.NewAuthService(&service.ServiceParams{
+		Repository:         p.conns.authRepo,
+		Publisher:          p.conns.publisher,
+		User:               p.clients.userClient,
+		Role:               p.clients.roleClient,
+		Identity:           p.clients.identityClient,
+		JWTAuth:            *p.jwtAuth,
+		ProviderSecrets:    *p.secrets,
+		Options:            getGrpcOpt(p.topics),
+		Metrics:            p.metrics,
+		Lockout:            p.lockout,
+		ScopePolicy:        p.scopes,
+		Clients:            ct,
+		MaxClaimsSize:      p.maxClaimsSize,
+		PermissionCacheTTL: p.permissionCacheTTL,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	ssrv, err := service.NewSessionService(&service.SessionParams{
+		Repository: p.conns.authRepo,
+		Publisher:  p.conns.publisher,
+		JWTAuth:    *p.jwtAuth,
+		Topic:      p.topics["sessionRevoke"],
+		Metrics:    p.metrics,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	asrv, err := service.NewAdminService(&service.AdminParams{
+		Repository: p.conns.authRepo,
+		Publisher:  p.conns.publisher,
+		JWTAuth:    *p.jwtAuth,
+		Logger:     p.logger,
+		Role:       p.role,
+		Audience:   p.adminAudience,
+		Topic:      p.topics["adminAction"],
+		Metrics:    p.metrics,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	auth.RegisterAuthServiceServer(grpcS, srv)
+	authapi.RegisterSessionServiceServer(grpcS, ssrv)
+	authapi.RegisterAdminServiceServer(grpcS, asrv)
+	authapi.RegisterClientServiceServer(grpcS, service.NewClientService(ct))
+	return srv, ct, nil
+}
+
 // Reads the configuration file containing the various client secret keys
 // of the providers. The expected format will be ...
 //
@@ -122,6 +486,30 @@ func readSecretConfig(c *cli.Context) (*oauth.ProviderSecrets, error) {
 	return provider, nil
 }
 
+// Reads the optional policy of the audiences and scopes that the clients
+// may request. The expected format will be ...
+//
+//	{
+//		"<client id>": {
+//			"audiences": ["admin"],
+//			"scopes": ["read:order", "write:order"]
+//		}
+//	}
+func readScopePolicy(c *cli.Context) (service.ScopePolicy, error) {
+	policy := service.ScopePolicy{}
+	if len(c.String("scope-policy")) == 0 {
+		return policy, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(c.String("scope-policy"))
+	if err != nil {
+		return policy, err
+	}
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return policy, err
+	}
+	return policy, nil
+}
+
 // Reads the public and private keys from their respective files and
 // creates a new JWTAuth instance.
 func parseJwtKeys(c *cli.Context) (*jwtauth.JWTAuth, error) {
@@ -145,35 +533,349 @@ func parseJwtKeys(c *cli.Context) (*jwtauth.JWTAuth, error) {
 	return jwtauth.NewJwtAuth(jwt.SigningMethodRS512, pkey, pubkey), err
 }
 
-// get external connections to redis, nats
+// get external connections to the repository and the messaging server
 func getConnections(c *cli.Context) (*Connections, error) {
 	conn := &Connections{}
-	redisAddr := fmt.Sprintf(
-		"%s:%s",
-		c.String("redis-master-service-host"),
-		c.String("redis-master-service-port"),
+	repo, err := GetRepository(c)
+	if err != nil {
+		return conn, err
+	}
+	ms, err := getPublisher(c)
+	if err != nil {
+		repo.Close() //nolint:errcheck
+		return conn, fmt.Errorf("cannot connect to messaging server %s", err)
+	}
+	conn.authRepo = repo
+	conn.publisher = ms
+	return conn, nil
+}
+
+// get the checks of the dependencies the server needs to serve requests
+func getHealthChecks(conns *Connections, clients *ClientsGRPC) map[string]health.Check {
+	return map[string]health.Check{
+		"repository":   health.PingCheck(conns.authRepo),
+		"user-api":     health.ConnCheck(clients.userConn),
+		"identity-api": health.ConnCheck(clients.identityConn),
+	}
+}
+
+// get the checks of the dependencies that are only reported, the
+// messaging is only checked if the publisher is able to report its
+// reachability and an outage of it does not fail the requests
+func getOptionalHealthChecks(conns *Connections) map[string]health.Check {
+	checks := make(map[string]health.Check)
+	if p, ok := conns.publisher.(health.Pinger); ok {
+		checks["messaging"] = health.PingCheck(p)
+	}
+	return checks
+}
+
+// serve the http health endpoints
+func serveHealth(healthS *http.Server, logger *logrus.Entry) {
+	log.Printf("starting health server on %s", healthS.Addr)
+	err := healthS.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.WithError(err).Error("health server stopped")
+	}
+}
+
+// get the http server of the rest endpoints. The calls go through the
+// interceptors of the grpc server and are served with its certificate,
+// but browsers are not asked for client certificates.
+func getRestServer(c *cli.Context, srv auth.AuthServiceServer, ct *service.ClientTokens, interceptors []grpc.UnaryServerInterceptor, certs *tlsconfig.Reloader) (*http.Server, error) {
+	var origins []string
+	if len(c.String("cors-origins")) > 0 {
+		origins = strings.Split(c.String("cors-origins"), ",")
+	}
+	hp := &rest.HandlerParams{
+		Service:     srv,
+		Interceptor: grpc_middleware.ChainUnaryServer(interceptors...),
+		Origins:     origins,
+		Clients:     ct,
+	}
+	if c.Bool("session-cookie") {
+		ss, err := rest.ParseSameSite(c.String("cookie-same-site"))
+		if err != nil {
+			return nil, err
+		}
+		hp.Cookie = &rest.CookieParams{
+			Domain:   c.String("cookie-domain"),
+			SameSite: ss,
+		}
+	}
+	h, err := rest.NewHandler(hp)
+	if err != nil {
+		return nil, err
+	}
+	restS := &http.Server{
+		Addr:              fmt.Sprintf(":%s", c.String("rest-port")),
+		Handler:           otelhttp.NewHandler(h, "rest"),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if certs != nil {
+		restS.TLSConfig = certs.PublicServerConfig("h2", "http/1.1")
+	}
+	return restS, nil
+}
+
+func serveRest(restS *http.Server, logger *logrus.Entry) {
+	log.Printf("starting rest server on %s", restS.Addr)
+	var err error
+	if restS.TLSConfig != nil {
+		err = restS.ListenAndServeTLS("", "")
+	} else {
+		err = restS.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.WithError(err).Error("rest server stopped")
+	}
+}
+
+// get the publisher for the configured messaging backend
+func getPublisher(c *cli.Context) (message.Publisher, error) {
+	enc, err := cloudevent.NewEncoder(
+		c.String("event-source"), c.String("event-format"),
 	)
-	rrepo, err := redis.NewAuthRepo(redisAddr)
 	if err != nil {
-		return conn, fmt.Errorf(
+		return nil, err
+	}
+	switch c.String("messaging") {
+	case "none":
+		return noop.NewPublisher(), nil
+	case "kafka":
+		return kafka.NewPublisher(
+			strings.Split(c.String("kafka-brokers"), ","),
+			c.String("kafka-topic"),
+			enc,
+		), nil
+	case "jetstream":
+		// subjects of the stream, a subject might be shared by topics
+		var subjects []string
+		seen := make(map[string]bool)
+		for _, t := range getTopics(c) {
+			if !seen[t] {
+				seen[t] = true
+				subjects = append(subjects, t)
+			}
+		}
+		opts := jetstream.DefaultOptions(c.String("jetstream-stream"), subjects)
+		opts.BufferSize = c.Int("jetstream-buffer")
+		opts.DrainTimeout = c.Duration("shutdown-timeout")
+		return jetstream.NewPublisher(
+			c.String("nats-host"), c.String("nats-port"), enc, opts,
+			gnats.MaxReconnects(-1), gnats.ReconnectWait(2*time.Second),
+		)
+	default:
+		return nats.NewPublisher(
+			c.String("nats-host"), c.String("nats-port"), enc,
+			gnats.MaxReconnects(-1), gnats.ReconnectWait(2*time.Second),
+		)
+	}
+}
+
+// GetRepository returns the auth repository for the configured storage
+// backend
+func GetRepository(c *cli.Context) (repository.AuthRepository, error) {
+	if c.String("repository") == "postgres" {
+		prepo, err := postgres.NewAuthRepo(
+			postgresDSN(c), c.Duration("postgres-sweep-interval"),
+		)
+		if err != nil {
+			return prepo, fmt.Errorf(
+				"cannot connect to postgres auth repository %s",
+				err,
+			)
+		}
+		return prepo, nil
+	}
+	rrepo, err := redis.NewAuthRepo(redisAddress(c), c.String("redis-namespace"))
+	if err != nil {
+		return rrepo, fmt.Errorf(
 			"cannot connect to redis auth repository %s",
 			err,
 		)
 	}
-	ms, err := nats.NewPublisher(
-		c.String("nats-host"), c.String("nats-port"),
-		gnats.MaxReconnects(-1), gnats.ReconnectWait(2*time.Second),
+	return rrepo, nil
+}
+
+func redisAddress(c *cli.Context) string {
+	return fmt.Sprintf(
+		"%s:%s",
+		c.String("redis-master-service-host"),
+		c.String("redis-master-service-port"),
 	)
+}
+
+// get the audit log of the logins, refreshes and logouts along with the
+// interceptor that writes it, both are nil if the audit log is disabled
+func getAudit(c *cli.Context, logger *logrus.Entry) (*audit.Logger, grpc.UnaryServerInterceptor, error) {
+	if c.String("audit-sink") == "none" {
+		return nil, nil, nil
+	}
+	al, err := audit.NewLogger(&audit.LoggerParams{
+		Sink:          c.String("audit-sink"),
+		File:          c.String("audit-file"),
+		SyslogNetwork: c.String("audit-syslog-network"),
+		SyslogAddress: c.String("audit-syslog-address"),
+	})
 	if err != nil {
-		return conn, fmt.Errorf("cannot connect to messaging server %s", err)
+		return nil, nil, err
 	}
-	conn.authRepo = rrepo
-	conn.publisher = ms
-	return conn, nil
+	svc := auth.AuthService_ServiceDesc.ServiceName
+	interceptor, err := audit.UnaryServerInterceptor(&audit.InterceptorParams{
+		Logger: al,
+		Methods: []string{
+			fmt.Sprintf("/%s/Login", svc),
+			fmt.Sprintf("/%s/Relogin", svc),
+			fmt.Sprintf("/%s/GetRefreshToken", svc),
+			fmt.Sprintf("/%s/Logout", svc),
+		},
+		ClientInfo:  service.ClientInfo,
+		ErrorLogger: logger,
+	})
+	if err != nil {
+		al.Close() //nolint:errcheck
+		return nil, nil, err
+	}
+	return al, interceptor, nil
+}
+
+// rateLimits are the limits of the logins and refreshes
+type rateLimits struct {
+	client      *goredis.Client
+	lockout     *ratelimit.Lockout
+	interceptor grpc.UnaryServerInterceptor
+}
+
+// get the rate limits of the logins and refreshes along with the lockout
+// of the identities, nothing is limited unless enabled
+func getRateLimits(c *cli.Context, ja jwtauth.JWTAuth, logger *logrus.Entry) (*rateLimits, error) {
+	rl := &rateLimits{}
+	if !c.Bool("rate-limit") {
+		return rl, nil
+	}
+	client := goredis.NewClient(&goredis.Options{Addr: redisAddress(c)})
+	if err := client.Ping().Err(); err != nil {
+		client.Close() //nolint:errcheck
+		return rl, fmt.Errorf("error pinging redis %s", err)
+	}
+	lockout, err := ratelimit.NewLockout(&ratelimit.LockoutParams{
+		Client:    client,
+		Namespace: c.String("redis-namespace"),
+		Threshold: c.Int("lockout-threshold"),
+		Base:      c.Duration("lockout-base"),
+		Max:       c.Duration("lockout-max"),
+	})
+	if err != nil {
+		client.Close() //nolint:errcheck
+		return rl, err
+	}
+	svc := auth.AuthService_ServiceDesc.ServiceName
+	interceptor, err := ratelimit.UnaryServerInterceptor(&ratelimit.InterceptorParams{
+		Limiter: ratelimit.NewLimiter(client, c.String("redis-namespace")),
+		Methods: []string{
+			fmt.Sprintf("/%s/Login", svc),
+			fmt.Sprintf("/%s/Relogin", svc),
+			fmt.Sprintf("/%s/GetRefreshToken", svc),
+			fmt.Sprintf("/%s/IssueToken", authapi.ClientService_ServiceDesc.ServiceName),
+		},
+		IP:              ratelimit.Bucket{Rate: c.Float64("ip-rate"), Burst: c.Int("ip-burst")},
+		Identity:        ratelimit.Bucket{Rate: c.Float64("identity-rate"), Burst: c.Int("identity-burst")},
+		ClientIP:        service.ClientIP,
+		RequestIdentity: service.RequestIdentity(ja),
+		Logger:          logger,
+	})
+	if err != nil {
+		client.Close() //nolint:errcheck
+		return rl, err
+	}
+	rl.client, rl.lockout, rl.interceptor = client, lockout, interceptor
+	return rl, nil
+}
+
+func postgresDSN(c *cli.Context) string {
+	dsn := &url.URL{
+		Scheme: "postgres",
+		User: url.UserPassword(
+			c.String("postgres-user"),
+			c.String("postgres-password"),
+		),
+		Host: fmt.Sprintf(
+			"%s:%s",
+			c.String("postgres-host"),
+			c.String("postgres-port"),
+		),
+		Path:     c.String("postgres-database"),
+		RawQuery: url.Values{"sslmode": {c.String("postgres-sslmode")}}.Encode(),
+	}
+	return dsn.String()
+}
+
+// get the transport credentials of the grpc server, the server is
+// plaintext unless a certificate is given
+func getServerCredentials(c *cli.Context, logger *logrus.Entry) (credentials.TransportCredentials, *tlsconfig.Reloader, error) {
+	if len(c.String("tls-cert")) == 0 {
+		return insecure.NewCredentials(), nil, nil
+	}
+	r, err := tlsconfig.NewReloader(&tlsconfig.ReloaderParams{
+		Files: tlsconfig.Files{
+			Cert: c.String("tls-cert"),
+			Key:  c.String("tls-key"),
+			CA:   c.String("tls-client-ca"),
+		},
+		Interval: c.Duration("tls-reload-interval"),
+		Logger:   logger,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	r.Start()
+	return credentials.NewTLS(r.ServerConfig()), r, nil
+}
+
+// get the transport credentials for the grpc server of the given name,
+// the connection is plaintext unless a certificate authority or a client
+// certificate is given
+func getClientCredentials(c *cli.Context, name string, logger *logrus.Entry) (credentials.TransportCredentials, *tlsconfig.Reloader, error) {
+	files := tlsconfig.Files{
+		Cert: c.String(name + "-grpc-cert"),
+		Key:  c.String(name + "-grpc-key"),
+		CA:   c.String(name + "-grpc-ca"),
+	}
+	if len(files.Cert) == 0 && len(files.CA) == 0 {
+		return insecure.NewCredentials(), nil, nil
+	}
+	r, err := tlsconfig.NewReloader(&tlsconfig.ReloaderParams{
+		Files:    files,
+		Interval: c.Duration("tls-reload-interval"),
+		Logger:   logger,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	r.Start()
+	return credentials.NewTLS(r.ClientConfig()), r, nil
+}
+
+// get the options for dialing the grpc server of the given name, the
+// calls are instrumented with the client metrics
+func getDialOptions(c *cli.Context, name string, m *metrics.Metrics, clients *ClientsGRPC, logger *logrus.Entry) ([]grpc.DialOption, error) {
+	creds, r, err := getClientCredentials(c, name, logger)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load certificates for %s %s", name, err)
+	}
+	if r != nil {
+		clients.reloaders = append(clients.reloaders, r)
+	}
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithUnaryInterceptor(m.GRPCClient.UnaryClientInterceptor()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	}, nil
 }
 
 // connect to necessary grpc clients
-func connectToGRPC(c *cli.Context) (*ClientsGRPC, error) {
+func connectToGRPC(c *cli.Context, m *metrics.Metrics, logger *logrus.Entry) (*ClientsGRPC, error) {
 	clients := &ClientsGRPC{}
 	userAddr := fmt.Sprintf(
 		"%s:%s",
@@ -181,20 +883,28 @@ func connectToGRPC(c *cli.Context) (*ClientsGRPC, error) {
 		c.String("user-grpc-port"),
 	)
 	// establish grpc connections
-	creds := insecure.NewCredentials()
-	uconn, err := grpc.Dial(userAddr, grpc.WithTransportCredentials(creds))
+	uopts, err := getDialOptions(c, "user", m, clients, logger)
+	if err != nil {
+		return clients, err
+	}
+	uconn, err := grpc.Dial(userAddr, uopts...)
 	if err != nil {
 		return clients, fmt.Errorf(
 			"cannot connect to grpc user microservice %s",
 			err,
 		)
 	}
+	clients.userConn = uconn
 	idnAddr := fmt.Sprintf(
 		"%s:%s",
 		c.String("identity-grpc-host"),
 		c.String("identity-grpc-port"),
 	)
-	iconn, err := grpc.Dial(idnAddr, grpc.WithTransportCredentials(creds))
+	iopts, err := getDialOptions(c, "identity", m, clients, logger)
+	if err != nil {
+		return clients, err
+	}
+	iconn, err := grpc.Dial(idnAddr, iopts...)
 	if err != nil {
 		return clients, fmt.Errorf(
 			"cannot connect to grpc identity microservice %s",
@@ -202,16 +912,28 @@ func connectToGRPC(c *cli.Context) (*ClientsGRPC, error) {
 		)
 	}
 	clients.userClient = user.NewUserServiceClient(uconn)
+	clients.roleClient = user.NewRoleServiceClient(uconn)
 	clients.identityClient = identity.NewIdentityServiceClient(iconn)
+	clients.identityConn = iconn
 	return clients, nil
 }
 
+// get the subjects for publishing messages
+func getTopics(c *cli.Context) map[string]string {
+	return map[string]string{
+		"tokenCreate":   c.String("login-topic"),
+		"tokenRefresh":  c.String("refresh-topic"),
+		"authFailure":   c.String("failure-topic"),
+		"logout":        c.String("logout-topic"),
+		"sessionRevoke": c.String("revoke-topic"),
+		"adminAction":   c.String("admin-topic"),
+	}
+}
+
 // get grpc topics options
-func getGrpcOpt() []aphgrpc.Option {
+func getGrpcOpt(topics map[string]string) []aphgrpc.Option {
 	return []aphgrpc.Option{
-		aphgrpc.TopicsOption(map[string]string{
-			"tokenCreate": "AuthService.Create",
-		}),
+		aphgrpc.TopicsOption(topics),
 	}
 }
 
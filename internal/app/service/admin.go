@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dictyBase/aphgrpc"
+	"github.com/dictyBase/modware-auth/internal/authapi"
+	"github.com/dictyBase/modware-auth/internal/jwtauth"
+	"github.com/dictyBase/modware-auth/internal/message"
+	"github.com/dictyBase/modware-auth/internal/metrics"
+	"github.com/dictyBase/modware-auth/internal/repository"
+	"github.com/dictyBase/modware-auth/internal/tracing"
+	"github.com/go-playground/validator/v10"
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// AdminService is the container for the administrative actions on the
+// accounts of users
+type AdminService struct {
+	authapi.UnimplementedAdminServiceServer
+	repo      repository.AuthRepository
+	publisher message.Publisher
+	jwtAuth   jwtauth.JWTAuth
+	logger    *logrus.Entry
+	role      string
+	audience  string
+	topic     string
+	metrics   *metrics.Metrics
+}
+
+// AdminParams are the attributes that are required for creating a new AdminService
+type AdminParams struct {
+	Repository repository.AuthRepository `validate:"required"`
+	Publisher  message.Publisher         `validate:"required"`
+	JWTAuth    jwtauth.JWTAuth           `validate:"required"`
+	Logger     *logrus.Entry             `validate:"required"`
+	// Role that is required for using the service
+	Role string `validate:"required"`
+	// Audience of the access tokens that are accepted by the service
+	Audience string `validate:"required"`
+	// Topic for publishing the actions
+	Topic string `validate:"required"`
+	// Metrics records the failed verifications, optional
+	Metrics *metrics.Metrics
+}
+
+// NewAdminService is the constructor for creating a new instance of AdminService
+func NewAdminService(srvP *AdminParams) (*AdminService, error) {
+	if err := validator.New().Struct(srvP); err != nil {
+		return &AdminService{}, err
+	}
+	return &AdminService{
+		repo:      srvP.Repository,
+		publisher: srvP.Publisher,
+		jwtAuth:   srvP.JWTAuth,
+		logger:    srvP.Logger,
+		role:      srvP.Role,
+		audience:  srvP.Audience,
+		topic:     srvP.Topic,
+		metrics:   srvP.Metrics,
+	}, nil
+}
+
+func (s *AdminService) RevokeUserSessions(ctx context.Context, r *authapi.UserIdRequest) (*authapi.RevokedSessions, error) {
+	rs := &authapi.RevokedSessions{}
+	c, err := s.authorize(ctx)
+	if err != nil {
+		return rs, err
+	}
+	n, err := tracing.WithContext(ctx, s.repo).DeleteUserSessions(r.UserId)
+	if err != nil {
+		return rs, aphgrpc.HandleDeleteError(ctx, err)
+	}
+	rs.Count = int64(n)
+	e := newAdminEvent(authapi.AuthEvent_SESSIONS_REVOKED, r.UserId, c)
+	e.RevokedSessions = rs.Count
+	return rs, s.record(ctx, e)
+}
+
+func (s *AdminService) LockUser(ctx context.Context, r *authapi.LockUserRequest) (*empty.Empty, error) {
+	e := &empty.Empty{}
+	c, err := s.authorize(ctx)
+	if err != nil {
+		return e, err
+	}
+	var d time.Duration
+	if r.Duration != nil {
+		if err := r.Duration.CheckValid(); err != nil {
+			return e, aphgrpc.HandleInvalidParamError(ctx, err)
+		}
+		d = r.Duration.AsDuration()
+		if d <= 0 {
+			return e, aphgrpc.HandleInvalidParamError(
+				ctx, fmt.Errorf("lock duration %s is not positive", d),
+			)
+		}
+	}
+	if err := tracing.WithContext(ctx, s.repo).LockUser(r.UserId, d); err != nil {
+		return e, aphgrpc.HandleInsertError(ctx, err)
+	}
+	ev := newAdminEvent(authapi.AuthEvent_ACCOUNT_LOCKED, r.UserId, c)
+	ev.Reason = r.Reason
+	if d > 0 {
+		ev.LockedUntil = timestamppb.New(time.Now().Add(d))
+	}
+	return e, s.record(ctx, ev)
+}
+
+func (s *AdminService) UnlockUser(ctx context.Context, r *authapi.UserIdRequest) (*empty.Empty, error) {
+	e := &empty.Empty{}
+	c, err := s.authorize(ctx)
+	if err != nil {
+		return e, err
+	}
+	if err := tracing.WithContext(ctx, s.repo).UnlockUser(r.UserId); err != nil {
+		return e, aphgrpc.HandleDeleteError(ctx, err)
+	}
+	return e, s.record(
+		ctx, newAdminEvent(authapi.AuthEvent_ACCOUNT_UNLOCKED, r.UserId, c),
+	)
+}
+
+// authorize authenticates the request and checks for the admin role
+func (s *AdminService) authorize(ctx context.Context) (*AccessTokenClaims, error) {
+	c, err := authenticate(ctx, s.jwtAuth, s.metrics, s.audience)
+	if err != nil {
+		return c, err
+	}
+	if !c.HasRole(s.role) {
+		return c, handlePermissionError(
+			ctx, fmt.Errorf("user %d does not have the %s role", c.UserID, s.role),
+		)
+	}
+	return c, nil
+}
+
+// record logs and publishes the event of the action
+func (s *AdminService) record(ctx context.Context, e *authapi.AuthEvent) error {
+	s.logger.WithFields(logrus.Fields{
+		"event":            e.Type.String(),
+		"user_id":          e.UserId,
+		"admin_id":         e.ActorId,
+		"reason":           e.Reason,
+		"revoked_sessions": e.RevokedSessions,
+	}).Warn("admin action")
+	if err := message.PublishContext(ctx, s.publisher, s.topic, e); err != nil {
+		return aphgrpc.HandleMessagingPubError(ctx, err)
+	}
+	return nil
+}
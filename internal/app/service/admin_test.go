@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/dictyBase/modware-auth/internal/authapi"
+	"github.com/dictyBase/modware-auth/internal/message/recording"
+	"github.com/dictyBase/modware-auth/internal/repository"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func newTestAdminService(t *testing.T) (*AdminService, *recording.Publisher) {
+	t.Helper()
+	log := logrus.New()
+	log.Out = io.Discard
+	pub := recording.NewPublisher()
+	srv, err := NewAdminService(&AdminParams{
+		Repository: newTestRepo(t),
+		Publisher:  pub,
+		JWTAuth:    *newTestJwtAuth(t),
+		Logger:     logrus.NewEntry(log),
+		Role:       "admin",
+		Audience:   "admin",
+		Topic:      "AuthService.Admin",
+	})
+	if err != nil {
+		t.Fatalf("error in creating admin service %s", err)
+	}
+	return srv, pub
+}
+
+// adminContext returns an incoming context carrying an access token of the
+// admin audience for the session with the given roles
+func adminContext(t *testing.T, srv *AdminService, sess *repository.Session, roles ...string) context.Context {
+	t.Helper()
+	return grantContext(t, &srv.jwtAuth, sess, grant{audience: srv.audience}, roles...)
+}
+
+func TestAdminServiceRole(t *testing.T) {
+	assert := assert.New(t)
+	srv, pub := newTestAdminService(t)
+	ctx := adminContext(t, srv, newSession(context.Background(), 7, "google"), "curator")
+	_, err := srv.LockUser(ctx, &authapi.LockUserRequest{UserId: 8})
+	assert.Equal(
+		codes.PermissionDenied,
+		status.Code(err),
+		"should reject user without admin role",
+	)
+	assert.Empty(pub.Events(), "should not publish rejected actions")
+}
+
+func TestAdminServiceAudience(t *testing.T) {
+	assert := assert.New(t)
+	srv, _ := newTestAdminService(t)
+	sess := newSession(context.Background(), 7, "google")
+	ctx := bearerContext(t, &srv.jwtAuth, sess, "admin")
+	_, err := srv.UnlockUser(ctx, &authapi.UserIdRequest{UserId: 8})
+	assert.Equal(
+		codes.Unauthenticated,
+		status.Code(err),
+		"should reject token of the default audience",
+	)
+	ctx = grantContext(t, &srv.jwtAuth, sess, grant{audience: "admin"}, "admin")
+	_, err = srv.UnlockUser(ctx, &authapi.UserIdRequest{UserId: 8})
+	assert.NoError(err, "should accept token of the admin audience")
+}
+
+func TestAdminServiceLock(t *testing.T) {
+	assert := assert.New(t)
+	srv, pub := newTestAdminService(t)
+	ctx := adminContext(t, srv, newSession(context.Background(), 7, "google"), "admin")
+	_, err := srv.LockUser(ctx, &authapi.LockUserRequest{
+		UserId:   8,
+		Duration: durationpb.New(time.Hour),
+		Reason:   "compromised",
+	})
+	assert.NoError(err, "error in locking user")
+	locked, err := srv.repo.IsUserLocked(8)
+	assert.NoError(err, "error in checking lock")
+	assert.True(locked, "should lock the user")
+	_, err = srv.UnlockUser(ctx, &authapi.UserIdRequest{UserId: 8})
+	assert.NoError(err, "error in unlocking user")
+	locked, err = srv.repo.IsUserLocked(8)
+	assert.NoError(err, "error in checking lock")
+	assert.False(locked, "should unlock the user")
+	events := pub.Events()
+	assert.Len(events, 2, "should publish every action")
+	assert.Equal(authapi.AuthEvent_ACCOUNT_LOCKED, events[0].Type)
+	assert.Equal(int64(8), events[0].UserId, "should record the user")
+	assert.Equal(int64(7), events[0].ActorId, "should record the admin")
+	assert.NotNil(events[0].LockedUntil, "should record end of the lock")
+	assert.Equal(authapi.AuthEvent_ACCOUNT_UNLOCKED, events[1].Type)
+}
+
+func TestAdminServiceRevoke(t *testing.T) {
+	assert := assert.New(t)
+	srv, pub := newTestAdminService(t)
+	storeSessions(
+		t, srv.repo,
+		newSession(context.Background(), 8, "google"),
+		newSession(context.Background(), 8, "orcid"),
+	)
+	ctx := adminContext(t, srv, newSession(context.Background(), 7, "google"), "admin")
+	rs, err := srv.RevokeUserSessions(ctx, &authapi.UserIdRequest{UserId: 8})
+	assert.NoError(err, "error in revoking sessions")
+	assert.Equal(int64(2), rs.Count, "should revoke all sessions of the user")
+	events := pub.Events()
+	assert.Len(events, 1, "should publish the action")
+	assert.Equal(authapi.AuthEvent_SESSIONS_REVOKED, events[0].Type)
+	assert.Equal(int64(2), events[0].RevokedSessions)
+}
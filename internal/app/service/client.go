@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dictyBase/aphgrpc"
+	"github.com/dictyBase/modware-auth/internal/authapi"
+	"github.com/dictyBase/modware-auth/internal/jwtauth"
+	"github.com/dictyBase/modware-auth/internal/repository"
+	"github.com/go-playground/validator/v10"
+	"github.com/golang-jwt/jwt"
+	"github.com/rs/xid"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+var (
+	// ErrInvalidClient is returned for unknown clients and wrong secrets
+	ErrInvalidClient = errors.New("client authentication failed")
+	// ErrInvalidScope is returned for scopes the client may not request
+	ErrInvalidScope = errors.New("scope is not allowed for the client")
+)
+
+// unknownClientHash is compared with the secrets of unknown clients, so
+// that they are rejected in about the same time as wrong secrets
+const unknownClientHash = "$2a$10$CS30PLP7cTgcKxIQq4KFvuFad43Vl9ADsg4OJCXGa0QPWApXUicGq"
+
+// ClientTokenClaims are the claims of the access token of a client, the
+// subject is the id of the client
+type ClientTokenClaims struct {
+	// Scope is the space separated list of the granted scopes
+	Scope string `json:"scope"`
+	// Standard JWT claims
+	jwt.StandardClaims
+}
+
+// ClientToken is an access token that is issued to a client
+type ClientToken struct {
+	Token     string
+	Scopes    []string
+	ExpiresIn time.Duration
+}
+
+// ClientTokenParams are the attributes that are required for creating
+// ClientTokens
+type ClientTokenParams struct {
+	Registry repository.ClientRegistry `validate:"required"`
+	JWTAuth  jwtauth.JWTAuth           `validate:"required"`
+}
+
+// ClientTokens issues access tokens to confidential clients
+type ClientTokens struct {
+	registry repository.ClientRegistry
+	jwtAuth  jwtauth.JWTAuth
+}
+
+// NewClientTokens is the constructor of ClientTokens
+func NewClientTokens(p *ClientTokenParams) (*ClientTokens, error) {
+	if err := validator.New().Struct(p); err != nil {
+		return nil, err
+	}
+	return &ClientTokens{registry: p.Registry, jwtAuth: p.JWTAuth}, nil
+}
+
+// Issue verifies the credentials of the client and returns an access
+// token with the requested scopes, or with all scopes of the client if
+// none are requested. The token lives for the TTL of the client, by
+// default as long as the access token of a login.
+func (ct *ClientTokens) Issue(ctx context.Context, id, secret string, scopes []string) (*ClientToken, error) {
+	c, err := ct.verify(id, secret)
+	if err != nil {
+		return nil, err
+	}
+	if len(scopes) == 0 {
+		scopes = c.Scopes
+	}
+	for _, sc := range scopes {
+		if !contains(c.Scopes, sc) {
+			return nil, fmt.Errorf("%w %s", ErrInvalidScope, sc)
+		}
+	}
+	ttl := c.TTL
+	if ttl <= 0 {
+		ttl = time.Minute * jwtExpirationTimeInMins
+	}
+	now := time.Now()
+	tkn, err := ct.jwtAuth.Encode(ClientTokenClaims{
+		Scope: strings.Join(scopes, " "),
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    "dictyBase",
+			Subject:   c.ID,
+			ExpiresAt: now.Add(ttl).Unix(),
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			Id:        xid.New().String(),
+			Audience:  "client",
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error in encoding client token %s", err)
+	}
+	return &ClientToken{Token: tkn, Scopes: scopes, ExpiresIn: ttl}, nil
+}
+
+func (ct *ClientTokens) verify(id, secret string) (*repository.Client, error) {
+	if id == "" || secret == "" {
+		return nil, ErrInvalidClient
+	}
+	c, err := ct.registry.GetClient(id)
+	if err != nil {
+		if !errors.Is(err, repository.ErrClientNotFound) {
+			return nil, err
+		}
+		bcrypt.CompareHashAndPassword([]byte(unknownClientHash), []byte(secret)) //nolint:errcheck
+		return nil, ErrInvalidClient
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(c.SecretHash), []byte(secret)); err != nil {
+		return nil, ErrInvalidClient
+	}
+	return c, nil
+}
+
+// ClientService issues access tokens to confidential clients over grpc
+type ClientService struct {
+	authapi.UnimplementedClientServiceServer
+	tokens *ClientTokens
+}
+
+// NewClientService is the constructor for creating a new instance of
+// ClientService
+func NewClientService(ct *ClientTokens) *ClientService {
+	return &ClientService{tokens: ct}
+}
+
+func (s *ClientService) IssueToken(ctx context.Context, r *authapi.ClientCredentials) (*authapi.ClientToken, error) {
+	tkn := &authapi.ClientToken{}
+	ct, err := s.tokens.Issue(ctx, r.ClientId, r.ClientSecret, r.Scopes)
+	switch {
+	case errors.Is(err, ErrInvalidClient):
+		return tkn, aphgrpc.HandleAuthenticationError(ctx, err)
+	case errors.Is(err, ErrInvalidScope):
+		return tkn, handlePermissionError(ctx, err)
+	case err != nil:
+		return tkn, aphgrpc.HandleGetError(ctx, err)
+	}
+	tkn.Token = ct.Token
+	tkn.Scopes = ct.Scopes
+	tkn.ExpiresIn = durationpb.New(ct.ExpiresIn)
+	return tkn, nil
+}
+
+func contains(values []string, v string) bool {
+	for _, val := range values {
+		if val == v {
+			return true
+		}
+	}
+	return false
+}
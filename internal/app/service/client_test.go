@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dictyBase/modware-auth/internal/authapi"
+	"github.com/dictyBase/modware-auth/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type clientRegistry map[string]*repository.Client
+
+func (r clientRegistry) GetClient(id string) (*repository.Client, error) {
+	if c, ok := r[id]; ok {
+		return c, nil
+	}
+	return nil, repository.ErrClientNotFound
+}
+
+func newTestClientRegistry(t *testing.T) clientRegistry {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("error in hashing secret %s", err)
+	}
+	return clientRegistry{"loader": &repository.Client{
+		ID:         "loader",
+		SecretHash: string(hash),
+		Scopes:     []string{"read:stock", "write:stock"},
+		TTL:        time.Hour,
+	}}
+}
+
+func TestClientTokensIssue(t *testing.T) {
+	assert := assert.New(t)
+	ja := newTestJwtAuth(t)
+	ct, err := NewClientTokens(&ClientTokenParams{
+		Registry: newTestClientRegistry(t),
+		JWTAuth:  *ja,
+	})
+	assert.NoError(err, "error in creating client tokens")
+	tkn, err := ct.Issue(context.Background(), "loader", "s3cret", []string{"read:stock"})
+	assert.NoError(err, "error in issuing token")
+	assert.Equal(time.Hour, tkn.ExpiresIn, "should use ttl of client")
+	claims := &ClientTokenClaims{}
+	_, err = ja.VerifyClaims(tkn.Token, claims)
+	assert.NoError(err, "should sign token")
+	assert.Equal("loader", claims.Subject, "should issue token for client")
+	assert.Equal("read:stock", claims.Scope, "should grant requested scope")
+	tkn, err = ct.Issue(context.Background(), "loader", "s3cret", nil)
+	assert.NoError(err, "error in issuing token")
+	assert.Equal([]string{"read:stock", "write:stock"}, tkn.Scopes, "should grant all scopes by default")
+	_, err = ct.Issue(context.Background(), "loader", "s3cret", []string{"admin"})
+	assert.ErrorIs(err, ErrInvalidScope, "should reject scope of other clients")
+	_, err = ct.Issue(context.Background(), "loader", "guess", nil)
+	assert.ErrorIs(err, ErrInvalidClient, "should reject wrong secret")
+	_, err = ct.Issue(context.Background(), "stranger", "s3cret", nil)
+	assert.ErrorIs(err, ErrInvalidClient, "should reject unknown client")
+}
+
+func TestClientServiceIssueToken(t *testing.T) {
+	assert := assert.New(t)
+	ct, err := NewClientTokens(&ClientTokenParams{
+		Registry: newTestClientRegistry(t),
+		JWTAuth:  *newTestJwtAuth(t),
+	})
+	assert.NoError(err, "error in creating client tokens")
+	s := NewClientService(ct)
+	tkn, err := s.IssueToken(context.Background(), &authapi.ClientCredentials{
+		ClientId: "loader", ClientSecret: "s3cret", Scopes: []string{"write:stock"},
+	})
+	assert.NoError(err, "error in issuing token")
+	assert.NotEmpty(tkn.Token, "should issue token")
+	assert.Equal([]string{"write:stock"}, tkn.Scopes, "should grant requested scope")
+	assert.Equal(time.Hour, tkn.ExpiresIn.AsDuration(), "should use ttl of client")
+	_, err = s.IssueToken(context.Background(), &authapi.ClientCredentials{
+		ClientId: "loader", ClientSecret: "guess",
+	})
+	assert.Equal(codes.Unauthenticated, status.Code(err), "should reject wrong secret")
+	_, err = s.IssueToken(context.Background(), &authapi.ClientCredentials{
+		ClientId: "loader", ClientSecret: "s3cret", Scopes: []string{"admin"},
+	})
+	assert.Equal(codes.PermissionDenied, status.Code(err), "should reject scope of other clients")
+}
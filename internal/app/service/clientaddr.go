@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+type clientAddrKey struct{}
+
+// TrustedProxies are the networks of the proxies whose x-forwarded-for
+// metadata is trusted for the address of the client
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses the addresses and the CIDR networks of the
+// trusted proxies
+func ParseTrustedProxies(proxies []string) (TrustedProxies, error) {
+	var tp TrustedProxies
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if len(p) == 0 {
+			continue
+		}
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return tp, fmt.Errorf("invalid proxy address %s", p)
+			}
+			tp = append(tp, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return tp, fmt.Errorf("invalid proxy network %s", p)
+		}
+		tp = append(tp, n)
+	}
+	return tp, nil
+}
+
+func (tp TrustedProxies) trusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range tp {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// UnaryServerInterceptor resolves the address of the client for the
+// handlers and the interceptors that follow it
+func (tp TrustedProxies) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		return handler(context.WithValue(ctx, clientAddrKey{}, tp.clientAddr(ctx)), req)
+	}
+}
+
+// clientAddr returns the address of the peer, unless it is a trusted
+// proxy. The x-forwarded-for hops are then followed from the nearest one
+// up to the first address that is not a trusted proxy.
+func (tp TrustedProxies) clientAddr(ctx context.Context) string {
+	addr := peerAddr(ctx)
+	if !tp.trusted(addr) {
+		return addr
+	}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return addr
+	}
+	var hops []string
+	for _, v := range md.Get("x-forwarded-for") {
+		for _, h := range strings.Split(v, ",") {
+			if h = strings.TrimSpace(h); len(h) > 0 {
+				hops = append(hops, h)
+			}
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr = hops[i]
+		if !tp.trusted(addr) {
+			break
+		}
+	}
+	return addr
+}
+
+// peerAddr returns the host of the address of the peer of the request
+func peerAddr(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	addr := p.Addr.String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func proxiedContext(peerAddr string, forwarded ...string) context.Context {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP(peerAddr), Port: 4242},
+	})
+	md := metadata.MD{}
+	if len(forwarded) > 0 {
+		md.Set("x-forwarded-for", forwarded...)
+	}
+	return metadata.NewIncomingContext(ctx, md)
+}
+
+func TestTrustedProxies(t *testing.T) {
+	assert := assert.New(t)
+	tp, err := ParseTrustedProxies([]string{"10.0.0.0/8", " 192.168.1.1", ""})
+	assert.NoError(err, "error in parsing trusted proxies")
+	_, err = ParseTrustedProxies([]string{"proxy.dicty.org"})
+	assert.Error(err, "should reject invalid proxy")
+	cases := map[string]struct {
+		ctx  context.Context
+		addr string
+	}{
+		"untrusted peer": {
+			proxiedContext("203.0.113.9", "198.51.100.1"), "203.0.113.9",
+		},
+		"trusted peer": {
+			proxiedContext("10.0.0.2", "198.51.100.1"), "198.51.100.1",
+		},
+		"spoofed hop": {
+			proxiedContext("10.0.0.2", "1.2.3.4, 198.51.100.1"), "198.51.100.1",
+		},
+		"chained proxies": {
+			proxiedContext("10.0.0.2", "198.51.100.1", "192.168.1.1"), "198.51.100.1",
+		},
+		"only proxies": {
+			proxiedContext("10.0.0.2", "10.1.1.1"), "10.1.1.1",
+		},
+		"no header": {
+			proxiedContext("10.0.0.2"), "10.0.0.2",
+		},
+	}
+	for name, tc := range cases {
+		var ip string
+		_, err := tp.UnaryServerInterceptor()(
+			tc.ctx, nil, &grpc.UnaryServerInfo{},
+			func(ctx context.Context, _ interface{}) (interface{}, error) {
+				ip = ClientIP(ctx)
+				return nil, nil
+			},
+		)
+		assert.NoError(err, "error in intercepting %s", name)
+		assert.Equal(tc.addr, ip, "should resolve client address for %s", name)
+	}
+	assert.Equal(
+		"203.0.113.9",
+		ClientIP(proxiedContext("203.0.113.9", "198.51.100.1")),
+		"should use peer address without interceptor",
+	)
+}
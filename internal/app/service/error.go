@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+
+	"github.com/dictyBase/aphgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// ErrPermission represents the lack of a required role
+	ErrPermission = metadata.Pairs(aphgrpc.MetaKey, "Insufficient permission")
+	// ErrAccountLocked represents an account locked by an administrator
+	ErrAccountLocked = metadata.Pairs(aphgrpc.MetaKey, "Account is locked")
+	// ErrUnavailable represents a dependency that cannot be reached
+	ErrUnavailable = metadata.Pairs(aphgrpc.MetaKey, "Service is unavailable")
+)
+
+func handlePermissionError(ctx context.Context, err error) error {
+	grpc.SetTrailer(ctx, ErrPermission) //nolint:errcheck
+	return status.Error(codes.PermissionDenied, err.Error())
+}
+
+func handleUnavailableError(ctx context.Context, err error) error {
+	grpc.SetTrailer(ctx, ErrUnavailable) //nolint:errcheck
+	return status.Error(codes.Unavailable, err.Error())
+}
+
+func handleAccountLockedError(ctx context.Context, err error) error {
+	grpc.SetTrailer(ctx, ErrAccountLocked) //nolint:errcheck
+	return status.Error(codes.PermissionDenied, err.Error())
+}
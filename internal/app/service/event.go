@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/dictyBase/modware-auth/internal/authapi"
+	"github.com/dictyBase/modware-auth/internal/repository"
+	"github.com/rs/xid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func newEvent(t authapi.AuthEvent_Type) *authapi.AuthEvent {
+	return &authapi.AuthEvent{
+		Id:   xid.New().String(),
+		Type: t,
+		Time: timestamppb.Now(),
+	}
+}
+
+// newSessionEvent returns an event for the session of the user, the
+// user is the actor of the event
+func newSessionEvent(t authapi.AuthEvent_Type, sess *repository.Session) *authapi.AuthEvent {
+	e := newEvent(t)
+	e.UserId = sess.UserID
+	e.ActorId = sess.UserID
+	e.Provider = sess.Provider
+	e.SessionId = sess.ID
+	e.ClientIp = sess.ClientIP
+	return e
+}
+
+// newTokenEvent returns the event for issuing the tokens of the session
+func newTokenEvent(
+	t authapi.AuthEvent_Type,
+	sess *repository.Session,
+	at AccessTokenClaims,
+	rt RefreshTokenClaims,
+) *authapi.AuthEvent {
+	e := newSessionEvent(t, sess)
+	e.Jti = at.Id
+	e.RefreshJti = rt.Id
+	e.ClientId = rt.ClientID
+	e.Audience = at.Audience
+	e.Scopes = strings.Fields(at.Scope)
+	e.ExpiresAt = timestamppb.New(time.Unix(rt.ExpiresAt, 0))
+	return e
+}
+
+// newFailureEvent returns the event for a failed login or refresh, the
+// identity is only given as its digest
+func newFailureEvent(
+	ctx context.Context,
+	t authapi.AuthEvent_Type,
+	f authapi.AuthEvent_Failure,
+	tp *tokenParams,
+) *authapi.AuthEvent {
+	e := newEvent(t)
+	if tp.session != nil {
+		e = newSessionEvent(t, tp.session)
+	}
+	e.Failure = f
+	e.Provider = tp.provider
+	e.ClientId = tp.grant.clientID
+	e.Audience = tp.grant.audience
+	e.Scopes = tp.grant.scopes
+	if tp.identity != "" {
+		e.IdentityDigest = repository.Digest(tp.identity)
+	}
+	e.ClientIp, _ = ClientInfo(ctx)
+	return e
+}
+
+// newAdminEvent returns an event for the action of an administrator on
+// the account of the user
+func newAdminEvent(t authapi.AuthEvent_Type, userID int64, admin *AccessTokenClaims) *authapi.AuthEvent {
+	e := newEvent(t)
+	e.UserId = userID
+	e.ActorId = admin.UserID
+	return e
+}
@@ -2,7 +2,9 @@ package service
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/dictyBase/aphgrpc"
 	"github.com/dictyBase/go-genproto/dictybaseapis/auth"
 	"github.com/dictyBase/modware-auth/internal/oauth"
 	"github.com/dictyBase/modware-auth/internal/user"
@@ -43,6 +45,18 @@ func getProviderLogin(ctx context.Context, p *ProviderLogin) (*user.NormalizedUs
 		}
 		return li, nil
 	default:
-		return u, nil
+		return u, aphgrpc.HandleInvalidParamError(
+			ctx, fmt.Errorf("provider %s is not supported", provider),
+		)
+	}
+}
+
+// supportedProvider reports whether the login with provider is supported
+func supportedProvider(provider string) bool {
+	switch provider {
+	case "orcid", "google", "linkedin":
+		return true
+	default:
+		return false
 	}
 }
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dictyBase/aphgrpc"
+	"github.com/dictyBase/go-genproto/dictybaseapis/api/jsonapi"
+	"github.com/dictyBase/go-genproto/dictybaseapis/user"
+)
+
+// authorization is the roles of a user along with the permissions of the
+// roles, a permission is given as permission:resource such as write:stock
+type authorization struct {
+	roles       []string
+	permissions []string
+	// omitted is set when the permissions are dropped for the size of the
+	// claims
+	omitted bool
+}
+
+// permissionCache keeps the permissions of the roles for a while, so that
+// logins and refreshes do not look them up for every token
+type permissionCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[int64]*cachedPermissions
+}
+
+type cachedPermissions struct {
+	permissions []string
+	expires     time.Time
+}
+
+// newPermissionCache returns a cache that keeps the permissions for the
+// ttl, nothing is cached for a ttl of zero
+func newPermissionCache(ttl time.Duration) *permissionCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &permissionCache{ttl: ttl, entries: make(map[int64]*cachedPermissions)}
+}
+
+func (pc *permissionCache) get(roleID int64) ([]string, bool) {
+	if pc == nil {
+		return nil, false
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	e, ok := pc.entries[roleID]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expires) {
+		delete(pc.entries, roleID)
+		return nil, false
+	}
+	return e.permissions, true
+}
+
+func (pc *permissionCache) set(roleID int64, permissions []string) {
+	if pc == nil {
+		return
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.entries[roleID] = &cachedPermissions{
+		permissions: permissions,
+		expires:     time.Now().Add(pc.ttl),
+	}
+}
+
+// getAuthorization returns the roles and the permissions of the user from
+// the user service, the permissions are dropped if the claims of both
+// exceed the maximum size. The cached permissions of the roles are only
+// used if cached is set, otherwise they are looked up again.
+func (s *AuthService) getAuthorization(ctx context.Context, userID int64, cached bool) (*authorization, error) {
+	a := &authorization{}
+	roles, err := s.getRoles(ctx, userID)
+	if err != nil {
+		return a, err
+	}
+	for _, r := range roles {
+		a.roles = append(a.roles, r.Attributes.Role)
+	}
+	if err := s.getPermissions(ctx, roles, a, cached); err != nil {
+		return a, aphgrpc.HandleGetError(ctx, err)
+	}
+	a.limit(s.maxClaimsSize)
+	return a, nil
+}
+
+// getPermissions adds the distinct permissions of the roles to the
+// authorization. The permissions that are not taken from the cache are
+// looked up concurrently, the lookups are given up once the permissions
+// cannot fit in the maximum size of the claims.
+func (s *AuthService) getPermissions(ctx context.Context, roles []*user.RoleData, a *authorization, cached bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type lookup struct {
+		permissions []string
+		err         error
+	}
+	// buffered for every role, so that lookups that are given up do not
+	// block
+	results := make(chan lookup, len(roles))
+	for _, r := range roles {
+		if perms, ok := s.permissions.get(r.Id); cached && ok {
+			results <- lookup{permissions: perms}
+			continue
+		}
+		go func(roleID int64) {
+			perms, err := s.lookupPermissions(ctx, roleID)
+			results <- lookup{permissions: perms, err: err}
+		}(r.Id)
+	}
+	size := a.minSize()
+	seen := make(map[string]bool)
+	for range roles {
+		l := <-results
+		if l.err != nil {
+			return l.err
+		}
+		for _, p := range l.permissions {
+			if !seen[p] {
+				seen[p] = true
+				a.permissions = append(a.permissions, p)
+				// the quoted permission along with its separator
+				size += len(p) + 3
+			}
+		}
+		if s.maxClaimsSize > 0 && len(a.permissions) > 0 && size > s.maxClaimsSize {
+			a.permissions = nil
+			a.omitted = true
+			return nil
+		}
+	}
+	sort.Strings(a.permissions)
+	return nil
+}
+
+// lookupPermissions returns the permissions of the role from the role
+// service and caches them
+func (s *AuthService) lookupPermissions(ctx context.Context, roleID int64) ([]string, error) {
+	pc, err := s.role.GetRelatedPermissions(ctx, &jsonapi.RelationshipRequest{Id: roleID})
+	if err != nil {
+		return nil, err
+	}
+	perms := make([]string, 0, len(pc.Data))
+	for _, p := range pc.Data {
+		perm := p.Attributes.Permission
+		if len(p.Attributes.Resource) > 0 {
+			perm = perm + ":" + p.Attributes.Resource
+		}
+		perms = append(perms, perm)
+	}
+	s.permissions.set(roleID, perms)
+	return perms, nil
+}
+
+// minSize returns the size of the encoded roles without any permission,
+// less the one byte that the first permission does not need for its
+// separator
+func (a *authorization) minSize() int {
+	data, err := json.Marshal(struct {
+		Roles       []string
+		Permissions []string
+	}{a.roles, []string{}})
+	if err != nil {
+		return 0
+	}
+	return len(data) - 1
+}
+
+// limit drops the permissions if the encoded roles and permissions exceed
+// the given size, the roles are always kept. Nothing is dropped for a size
+// of zero.
+func (a *authorization) limit(size int) {
+	if size <= 0 || len(a.permissions) == 0 {
+		return
+	}
+	data, err := json.Marshal(struct {
+		Roles       []string
+		Permissions []string
+	}{a.roles, a.permissions})
+	if err == nil && len(data) <= size {
+		return
+	}
+	a.permissions = nil
+	a.omitted = true
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dictyBase/go-genproto/dictybaseapis/auth"
+	"github.com/dictyBase/go-genproto/dictybaseapis/user"
+	"github.com/dictyBase/modware-auth/internal/message/recording"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRole(id int64, name string) *user.RoleData {
+	return &user.RoleData{Id: id, Attributes: &user.RoleAttributes{Role: name}}
+}
+
+func newTestPermission(permission, resource string) *user.PermissionData {
+	return &user.PermissionData{Attributes: &user.PermissionAttributes{
+		Permission: permission, Resource: resource,
+	}}
+}
+
+func TestAuthServicePermissions(t *testing.T) {
+	assert := assert.New(t)
+	ja, repo := newTestJwtAuth(t), newTestRepo(t)
+	srv := newTestAuthService(t, ja, repo, recording.NewPublisher())
+	srv.user = &userClient{roles: []*user.RoleData{
+		newTestRole(1, "curator"), newTestRole(2, "stock-manager"),
+	}}
+	rc := &roleClient{permissions: map[int64][]*user.PermissionData{
+		1: {newTestPermission("write", "stock"), newTestPermission("read", "order")},
+		2: {newTestPermission("write", "stock"), newTestPermission("admin", "")},
+	}}
+	srv.role = rc
+	tkn := storeRefreshToken(t, ja, repo, newSession(context.Background(), 7, "google"))
+	tkns, err := srv.GetRefreshToken(context.Background(), &auth.NewToken{RefreshToken: tkn})
+	assert.NoError(err, "error in refreshing token")
+	c := &AccessTokenClaims{}
+	_, err = ja.VerifyClaims(tkns.Token, c)
+	assert.NoError(err, "error in verifying access token")
+	assert.Equal("7", c.Subject, "should issue token for the user")
+	assert.Equal([]string{"curator", "stock-manager"}, c.Roles, "should embed roles")
+	assert.Equal([]string{"admin", "read:order", "write:stock"}, c.Permissions, "should embed distinct permissions of the roles")
+	assert.True(c.HasPermission("write", "stock"), "should have permission on resource")
+	assert.True(c.HasPermission("admin", ""), "should have permission without resource")
+	assert.False(c.HasPermission("write", "order"), "should not have permission of other resource")
+	assert.False(c.PermissionsOmitted, "should not omit permissions")
+	srv.maxClaimsSize = 64
+	tkns, err = srv.GetRefreshToken(context.Background(), &auth.NewToken{RefreshToken: tkns.RefreshToken})
+	assert.NoError(err, "error in refreshing token")
+	c = &AccessTokenClaims{}
+	_, err = ja.VerifyClaims(tkns.Token, c)
+	assert.NoError(err, "error in verifying access token")
+	assert.Equal([]string{"curator", "stock-manager"}, c.Roles, "should keep roles above the size")
+	assert.Empty(c.Permissions, "should omit permissions above the size")
+	assert.True(c.PermissionsOmitted, "should flag omitted permissions")
+}
+
+func TestAuthServicePermissionCache(t *testing.T) {
+	assert := assert.New(t)
+	ja, repo := newTestJwtAuth(t), newTestRepo(t)
+	srv := newTestAuthService(t, ja, repo, recording.NewPublisher())
+	srv.permissions = newPermissionCache(time.Hour)
+	srv.user = &userClient{roles: []*user.RoleData{
+		newTestRole(1, "curator"), newTestRole(2, "stock-manager"),
+	}}
+	rc := &roleClient{permissions: map[int64][]*user.PermissionData{
+		1: {newTestPermission("write", "stock")},
+		2: {newTestPermission("read", "order")},
+	}}
+	srv.role = rc
+	ctx := context.Background()
+	a, err := srv.getAuthorization(ctx, 7, true)
+	assert.NoError(err, "error in getting authorization")
+	assert.Equal([]string{"read:order", "write:stock"}, a.permissions, "should look up permissions of the roles")
+	assert.Equal(int32(2), atomic.LoadInt32(&rc.calls), "should look up permissions of every role")
+	rc.permissions[2] = nil
+	a, err = srv.getAuthorization(ctx, 7, true)
+	assert.NoError(err, "error in getting authorization")
+	assert.Equal(int32(2), atomic.LoadInt32(&rc.calls), "should not look up cached permissions for a login")
+	assert.Equal([]string{"read:order", "write:stock"}, a.permissions, "should use cached permissions for a login")
+	// refreshes and relogins, which refresh the session of the refresh
+	// token, always look up the permissions
+	tkn := storeRefreshToken(t, ja, repo, newSession(ctx, 7, "google"))
+	tkns, err := srv.GetRefreshToken(ctx, &auth.NewToken{RefreshToken: tkn})
+	assert.NoError(err, "error in refreshing token")
+	assert.Equal(int32(4), atomic.LoadInt32(&rc.calls), "should look up permissions for a refresh")
+	c := &AccessTokenClaims{}
+	_, err = ja.VerifyClaims(tkns.Token, c)
+	assert.NoError(err, "error in verifying access token")
+	assert.Equal([]string{"write:stock"}, c.Permissions, "should drop revoked permission at refresh")
+	a, err = srv.getAuthorization(ctx, 7, true)
+	assert.NoError(err, "error in getting authorization")
+	assert.Equal([]string{"write:stock"}, a.permissions, "should cache permissions of the last lookup")
+	srv.permissions.entries[1].expires = time.Now().Add(-time.Second)
+	_, err = srv.getAuthorization(ctx, 7, true)
+	assert.NoError(err, "error in getting authorization")
+	assert.Equal(int32(5), atomic.LoadInt32(&rc.calls), "should look up expired permissions")
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dictyBase/aphgrpc"
+	"github.com/dictyBase/modware-auth/internal/authapi"
+	"google.golang.org/grpc/metadata"
+)
+
+// DefaultAudience is the audience of the access tokens for which no
+// audience is requested, every client may request it
+const DefaultAudience = "user"
+
+const (
+	// AudienceMetadata is the metadata key of the requested audience
+	AudienceMetadata = "x-auth-audience"
+	// ScopeMetadata is the metadata key of the space separated list of the
+	// requested scopes
+	ScopeMetadata = "x-auth-scope"
+	// ClientIDMetadata is the metadata key of the id of the client that
+	// requests an audience or scopes
+	ClientIDMetadata = "x-auth-client-id"
+	// ClientSecretMetadata is the metadata key of the secret of the client
+	// that requests an audience or scopes
+	ClientSecretMetadata = "x-auth-client-secret"
+)
+
+// ClientScopes are the audiences and the scopes that a client may request
+// besides the default audience
+type ClientScopes struct {
+	Audiences []string `json:"audiences"`
+	Scopes    []string `json:"scopes"`
+}
+
+// ScopePolicy maps the ids of the registered clients to their allowed
+// audiences and scopes
+type ScopePolicy map[string]*ClientScopes
+
+// Allow returns an error unless the client may request the audience and
+// all of the scopes
+func (p ScopePolicy) Allow(clientID, audience string, scopes []string) error {
+	cs := p[clientID]
+	if cs == nil {
+		cs = &ClientScopes{}
+	}
+	if audience != DefaultAudience && !contains(cs.Audiences, audience) {
+		return fmt.Errorf("audience %s is not allowed for client %q", audience, clientID)
+	}
+	for _, sc := range scopes {
+		if !contains(cs.Scopes, sc) {
+			return fmt.Errorf("scope %s is not allowed for client %q", sc, clientID)
+		}
+	}
+	return nil
+}
+
+// grant is the audience and the scopes of the tokens of a client, they
+// are carried by the refresh token and kept by its refreshes unless others
+// are requested
+type grant struct {
+	clientID string
+	audience string
+	scopes   []string
+}
+
+// isDefault reports whether the grant is the default audience without any
+// scope, which needs no authenticated client
+func (g grant) isDefault() bool {
+	return g.audience == DefaultAudience && len(g.scopes) == 0
+}
+
+// requestGrant replaces the audience and the scopes of the grant if either
+// is requested in the metadata of the request, the audience defaults to
+// DefaultAudience and the scopes to none
+func requestGrant(ctx context.Context, g *grant) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return
+	}
+	aud, sc := md.Get(AudienceMetadata), md.Get(ScopeMetadata)
+	if len(aud) == 0 && len(sc) == 0 {
+		return
+	}
+	g.audience, g.scopes = DefaultAudience, nil
+	if len(aud) > 0 && len(strings.TrimSpace(aud[0])) > 0 {
+		g.audience = strings.TrimSpace(aud[0])
+	}
+	for _, v := range sc {
+		g.scopes = append(g.scopes, strings.Fields(v)...)
+	}
+}
+
+// authorizeGrant sets the audience and the scopes of the tokens from the
+// request and checks them against the policy of the client. Any other than
+// the default grant requires the client to authenticate with its id and
+// secret in the metadata, a grant kept from the refresh token only by the
+// client it was issued to.
+func (s *AuthService) authorizeGrant(ctx context.Context, tp *tokenParams) error {
+	if len(tp.grant.audience) == 0 {
+		tp.grant.audience = DefaultAudience
+	}
+	issued := tp.grant
+	requestGrant(ctx, &tp.grant)
+	if tp.grant.isDefault() {
+		return nil
+	}
+	id, err := s.requestClient(ctx)
+	if err == nil && !issued.isDefault() && id != issued.clientID {
+		err = fmt.Errorf("%w, the grant is issued to %q", ErrInvalidClient, issued.clientID)
+	}
+	switch {
+	case errors.Is(err, ErrInvalidClient):
+		s.publishFailure(ctx, tp, authapi.AuthEvent_FAILURE_INVALID_CLIENT)
+		return aphgrpc.HandleAuthenticationError(ctx, err)
+	case err != nil:
+		return aphgrpc.HandleGetError(ctx, err)
+	}
+	tp.grant.clientID = id
+	if err := s.scopes.Allow(tp.grant.clientID, tp.grant.audience, tp.grant.scopes); err != nil {
+		s.publishFailure(ctx, tp, authapi.AuthEvent_FAILURE_SCOPE_NOT_ALLOWED)
+		return handlePermissionError(ctx, err)
+	}
+	return nil
+}
+
+// requestClient verifies the id and the secret of the client given in the
+// metadata of the request and returns the id
+func (s *AuthService) requestClient(ctx context.Context) (string, error) {
+	if s.clients == nil {
+		return "", ErrInvalidClient
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	id, secret := md.Get(ClientIDMetadata), md.Get(ClientSecretMetadata)
+	if len(id) == 0 || len(secret) == 0 {
+		return "", ErrInvalidClient
+	}
+	c, err := s.clients.verify(id[0], secret[0])
+	if err != nil {
+		return "", err
+	}
+	return c.ID, nil
+}
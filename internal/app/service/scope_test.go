@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dictyBase/go-genproto/dictybaseapis/auth"
+	"github.com/dictyBase/modware-auth/internal/authapi"
+	"github.com/dictyBase/modware-auth/internal/jwtauth"
+	"github.com/dictyBase/modware-auth/internal/message/recording"
+	"github.com/dictyBase/modware-auth/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+var testScopePolicy = ScopePolicy{
+	"stock-center": &ClientScopes{
+		Audiences: []string{"admin"},
+		Scopes:    []string{"read:order", "write:order"},
+	},
+}
+
+func TestScopePolicyAllow(t *testing.T) {
+	assert := assert.New(t)
+	assert.NoError(testScopePolicy.Allow("stock-center", "admin", []string{"write:order"}), "should allow audience and scope of client")
+	assert.NoError(testScopePolicy.Allow("frontpage", DefaultAudience, nil), "should allow default audience to any client")
+	assert.Error(testScopePolicy.Allow("frontpage", "admin", nil), "should reject audience of other client")
+	assert.Error(testScopePolicy.Allow("frontpage", DefaultAudience, []string{"read:order"}), "should reject scope of other client")
+	assert.Error(testScopePolicy.Allow("stock-center", "curator", nil), "should reject unknown audience")
+	assert.NoError(ScopePolicy(nil).Allow("stock-center", DefaultAudience, nil), "should allow default audience without policy")
+}
+
+// newTestScopeService returns an auth service with the test scope policy,
+// its clients authenticate with the secret s3cret
+func newTestScopeService(t *testing.T, ja *jwtauth.JWTAuth, repo repository.AuthRepository, pub *recording.Publisher) *AuthService {
+	t.Helper()
+	srv := newTestAuthService(t, ja, repo, pub)
+	srv.scopes = testScopePolicy
+	reg := newTestClientRegistry(t)
+	reg["stock-center"] = &repository.Client{
+		ID:         "stock-center",
+		SecretHash: reg["loader"].SecretHash,
+	}
+	ct, err := NewClientTokens(&ClientTokenParams{Registry: reg, JWTAuth: *ja})
+	if err != nil {
+		t.Fatalf("error in creating client tokens %s", err)
+	}
+	srv.clients = ct
+	return srv
+}
+
+// clientContext returns an incoming context with the credentials of the
+// client and the other metadata
+func clientContext(id string, kv ...string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		append([]string{ClientIDMetadata, id, ClientSecretMetadata, "s3cret"}, kv...)...,
+	))
+}
+
+func TestAuthServiceRefreshGrant(t *testing.T) {
+	assert := assert.New(t)
+	ja, repo, pub := newTestJwtAuth(t), newTestRepo(t), recording.NewPublisher()
+	srv := newTestScopeService(t, ja, repo, pub)
+	sess := newSession(context.Background(), 7, "google")
+	rt, err := ja.Encode(generateRefreshTokenClaims("jo@dicty.org", sess.Provider, sess.ID, grant{
+		clientID: "stock-center", audience: "admin", scopes: []string{"write:order"},
+	}))
+	assert.NoError(err, "error in encoding refresh token")
+	assert.NoError(repo.SetSession(sess, rt, time.Hour), "error in storing session")
+	_, err = srv.GetRefreshToken(context.Background(), &auth.NewToken{RefreshToken: rt})
+	assert.Equal(codes.Unauthenticated, status.Code(err), "should reject grant without client credentials")
+	events := pub.Events()
+	assert.Equal(authapi.AuthEvent_FAILURE_INVALID_CLIENT, events[len(events)-1].Failure, "should classify missing client credentials")
+	_, err = srv.GetRefreshToken(clientContext("loader"), &auth.NewToken{RefreshToken: rt})
+	assert.Equal(codes.Unauthenticated, status.Code(err), "should reject grant of other client")
+	tkn, err := srv.GetRefreshToken(clientContext("stock-center"), &auth.NewToken{RefreshToken: rt})
+	assert.NoError(err, "error in refreshing token")
+	c := &AccessTokenClaims{}
+	_, err = ja.VerifyAudience(tkn.Token, c, "admin")
+	assert.NoError(err, "should keep audience of refresh token")
+	assert.True(c.HasScope("write:order"), "should keep scope of refresh token")
+	pending, err := repo.PendingEvents(1)
+	assert.NoError(err, "error in getting pending events")
+	e := &authapi.AuthEvent{}
+	assert.NoError(proto.Unmarshal(pending[0].Payload, e), "error in decoding event")
+	assert.Equal("stock-center", e.ClientId, "should publish client")
+	assert.Equal("admin", e.Audience, "should publish audience")
+	assert.Equal([]string{"write:order"}, e.Scopes, "should publish scopes")
+	ctx := clientContext("stock-center",
+		AudienceMetadata, DefaultAudience,
+		ScopeMetadata, "read:order",
+	)
+	tkn, err = srv.GetRefreshToken(ctx, &auth.NewToken{RefreshToken: tkn.RefreshToken})
+	assert.NoError(err, "error in refreshing token")
+	c = &AccessTokenClaims{}
+	_, err = ja.VerifyAudience(tkn.Token, c, DefaultAudience)
+	assert.NoError(err, "should issue requested audience")
+	assert.Equal("read:order", c.Scope, "should issue requested scope")
+	_, err = ja.VerifyAudience(tkn.Token, &AccessTokenClaims{}, "admin")
+	assert.Error(err, "should not verify token for other audience")
+	ctx = clientContext("stock-center", AudienceMetadata, "curator")
+	_, err = srv.GetRefreshToken(ctx, &auth.NewToken{RefreshToken: tkn.RefreshToken})
+	assert.Equal(codes.PermissionDenied, status.Code(err), "should reject audience that is not allowed")
+	events = pub.Events()
+	assert.Equal(authapi.AuthEvent_FAILURE_SCOPE_NOT_ALLOWED, events[len(events)-1].Failure, "should classify audience that is not allowed")
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		AudienceMetadata, DefaultAudience,
+	))
+	tkn, err = srv.GetRefreshToken(ctx, &auth.NewToken{RefreshToken: tkn.RefreshToken})
+	assert.NoError(err, "should issue default audience without client credentials")
+	c = &AccessTokenClaims{}
+	_, err = ja.VerifyAudience(tkn.Token, c, DefaultAudience)
+	assert.NoError(err, "should issue default audience")
+	assert.Empty(c.Scope, "should issue no scope")
+}
+
+func TestAuthServiceLoginGrant(t *testing.T) {
+	assert := assert.New(t)
+	pub := recording.NewPublisher()
+	srv := newTestScopeService(t, newTestJwtAuth(t), newTestRepo(t), pub)
+	login := &auth.NewLogin{
+		ClientId: "stock-center", State: "state", Code: "code", Scopes: "email",
+		RedirectUrl: "http://localhost", Provider: "google",
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		AudienceMetadata, "admin",
+	))
+	_, err := srv.Login(ctx, login)
+	assert.Equal(codes.Unauthenticated, status.Code(err), "should reject claimed client before the provider login")
+	assert.Equal(authapi.AuthEvent_FAILURE_INVALID_CLIENT, pub.Events()[0].Failure, "should classify claimed client")
+	assert.Equal("admin", pub.Events()[0].Audience, "should publish requested audience")
+	_, err = srv.Login(clientContext("loader", AudienceMetadata, "admin"), login)
+	assert.Equal(codes.PermissionDenied, status.Code(err), "should reject audience before the provider login")
+	assert.Equal(authapi.AuthEvent_FAILURE_SCOPE_NOT_ALLOWED, pub.Events()[1].Failure, "should classify audience that is not allowed")
+	assert.Equal("loader", pub.Events()[1].ClientId, "should publish authenticated client")
+}
@@ -2,7 +2,10 @@ package service
 
 import (
 	"context"
+	"crypto/subtle"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -13,12 +16,25 @@ import (
 	"github.com/dictyBase/go-genproto/dictybaseapis/identity"
 	"github.com/dictyBase/go-genproto/dictybaseapis/user"
 
+	"github.com/dictyBase/modware-auth/internal/audit"
+	"github.com/dictyBase/modware-auth/internal/authapi"
 	"github.com/dictyBase/modware-auth/internal/jwtauth"
 	"github.com/dictyBase/modware-auth/internal/message"
+	"github.com/dictyBase/modware-auth/internal/message/outbox"
+	"github.com/dictyBase/modware-auth/internal/metrics"
 	"github.com/dictyBase/modware-auth/internal/oauth"
+	"github.com/dictyBase/modware-auth/internal/ratelimit"
 	"github.com/dictyBase/modware-auth/internal/repository"
-	"github.com/golang-jwt/jwt"
+	"github.com/dictyBase/modware-auth/internal/tracing"
 	"github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	outcomeInvalidToken    = "invalid_token"
+	outcomeSessionNotFound = "session_not_found"
+	outcomeError           = "error"
 )
 
 const (
@@ -26,6 +42,9 @@ const (
 	refreshTokenExpirationTimeInMins = 60 * 720 // 30 days
 )
 
+// AccessTokenLifetime is how long the issued access tokens are valid
+const AccessTokenLifetime = time.Minute * jwtExpirationTimeInMins
+
 // AuthService is the container for managing auth service definitions
 type AuthService struct {
 	auth.UnimplementedAuthServiceServer
@@ -34,8 +53,15 @@ type AuthService struct {
 	publisher       message.Publisher
 	identity        identity.IdentityServiceClient
 	user            user.UserServiceClient
+	role            user.RoleServiceClient
 	jwtAuth         jwtauth.JWTAuth
 	providerSecrets oauth.ProviderSecrets
+	metrics         *metrics.Metrics
+	lockout         *ratelimit.Lockout
+	scopes          ScopePolicy
+	clients         *ClientTokens
+	permissions     *permissionCache
+	maxClaimsSize   int
 }
 
 // ServiceParams are the attributes that are required for creating a new AuthService
@@ -43,15 +69,38 @@ type ServiceParams struct {
 	Repository      repository.AuthRepository      `validate:"required"`
 	Publisher       message.Publisher              `validate:"required"`
 	User            user.UserServiceClient         `validate:"required"`
+	Role            user.RoleServiceClient         `validate:"required"`
 	Identity        identity.IdentityServiceClient `validate:"required"`
 	JWTAuth         jwtauth.JWTAuth                `validate:"required"`
 	ProviderSecrets oauth.ProviderSecrets          `validate:"required"`
 	Options         []aphgrpc.Option               `validate:"required"`
+	// Metrics records the outcomes, no metrics are recorded if it is nil
+	Metrics *metrics.Metrics
+	// Lockout locks the refreshes of identities after repeated failures,
+	// optional
+	Lockout *ratelimit.Lockout
+	// ScopePolicy gives the audiences and scopes the clients may request,
+	// only the default audience is allowed without it
+	ScopePolicy ScopePolicy
+	// Clients authenticates the clients that request other than the
+	// default audience, such requests are rejected without it
+	Clients *ClientTokens
+	// MaxClaimsSize is the size in bytes of the encoded roles and
+	// permissions above which the permissions are left out of the access
+	// token, they are never left out if it is zero
+	MaxClaimsSize int
+	// PermissionCacheTTL is how long the permissions of the roles are
+	// cached, they are looked up for every token if it is zero
+	PermissionCacheTTL time.Duration
 }
 
 type tokenParams struct {
 	identity string
 	provider string
+	session  *repository.Session
+	// login is set for a new session, otherwise the session is refreshed
+	login bool
+	grant grant
 }
 
 type userData struct {
@@ -79,32 +128,54 @@ func NewAuthService(srvP *ServiceParams) (*AuthService, error) {
 		repo:            srvP.Repository,
 		publisher:       srvP.Publisher,
 		user:            srvP.User,
+		role:            srvP.Role,
 		identity:        srvP.Identity,
 		jwtAuth:         srvP.JWTAuth,
 		providerSecrets: srvP.ProviderSecrets,
+		metrics:         srvP.Metrics,
+		lockout:         srvP.Lockout,
+		scopes:          srvP.ScopePolicy,
+		clients:         srvP.Clients,
+		permissions:     newPermissionCache(srvP.PermissionCacheTTL),
+		maxClaimsSize:   srvP.MaxClaimsSize,
 	}, nil
 }
 
 func (s *AuthService) Login(ctx context.Context, l *auth.NewLogin) (*auth.Auth, error) {
 	a := &auth.Auth{}
+	tp := &tokenParams{
+		provider: l.Provider,
+		login:    true,
+		grant:    grant{clientID: l.ClientId},
+	}
 	if err := l.Validate(); err != nil {
+		s.publishFailure(ctx, tp, authapi.AuthEvent_FAILURE_INVALID_REQUEST)
 		return a, aphgrpc.HandleInvalidParamError(ctx, err)
 	}
-	provider := l.Provider
+	if !supportedProvider(tp.provider) {
+		s.publishFailure(ctx, tp, authapi.AuthEvent_FAILURE_UNSUPPORTED_PROVIDER)
+		return a, aphgrpc.HandleInvalidParamError(
+			ctx, fmt.Errorf("provider %s is not supported", tp.provider),
+		)
+	}
+	if err := s.authorizeGrant(ctx, tp); err != nil {
+		return a, err
+	}
 	// log in to provider and get user data
+	start := time.Now()
 	u, err := getProviderLogin(ctx, &ProviderLogin{
-		provider: provider, login: l, providerSecrets: s.providerSecrets,
+		provider: tp.provider, login: l, providerSecrets: s.providerSecrets,
 	})
+	s.metrics.ObserveProvider(tp.provider, start)
 	if err != nil {
+		s.publishFailure(ctx, tp, authapi.AuthEvent_FAILURE_PROVIDER_LOGIN)
 		return a, err
 	}
-	id := u.Email
-	if provider == "orcid" {
-		id = u.ID
+	tp.identity = u.Email
+	if tp.provider == "orcid" {
+		tp.identity = u.ID
 	}
-	a, err = s.createTokens(ctx, &tokenParams{
-		identity: id, provider: provider,
-	})
+	a, err = s.createTokens(ctx, tp)
 	if err != nil {
 		return a, err
 	}
@@ -114,6 +185,7 @@ func (s *AuthService) Login(ctx context.Context, l *auth.NewLogin) (*auth.Auth,
 func (s *AuthService) Relogin(ctx context.Context, l *auth.NewRelogin) (*auth.Auth, error) {
 	a := &auth.Auth{}
 	if err := l.Validate(); err != nil {
+		s.publishFailure(ctx, &tokenParams{}, authapi.AuthEvent_FAILURE_INVALID_REQUEST)
 		return a, aphgrpc.HandleInvalidParamError(ctx, err)
 	}
 	v, err := s.validateTokens(ctx, &auth.NewToken{
@@ -122,9 +194,7 @@ func (s *AuthService) Relogin(ctx context.Context, l *auth.NewRelogin) (*auth.Au
 	if err != nil {
 		return a, err
 	}
-	a, err = s.createTokens(ctx, &tokenParams{
-		identity: v.identity, provider: v.provider,
-	})
+	a, err = s.createTokens(ctx, v)
 	if err != nil {
 		return a, err
 	}
@@ -134,15 +204,14 @@ func (s *AuthService) Relogin(ctx context.Context, l *auth.NewRelogin) (*auth.Au
 func (s *AuthService) GetRefreshToken(ctx context.Context, t *auth.NewToken) (*auth.Token, error) {
 	tkns := &auth.Token{}
 	if err := t.Validate(); err != nil {
+		s.publishFailure(ctx, &tokenParams{}, authapi.AuthEvent_FAILURE_INVALID_REQUEST)
 		return tkns, aphgrpc.HandleInvalidParamError(ctx, err)
 	}
 	v, err := s.validateTokens(ctx, t)
 	if err != nil {
 		return tkns, err
 	}
-	tkns, err = s.generateAndStoreTokens(ctx, &tokenParams{
-		identity: v.identity, provider: v.provider,
-	})
+	tkns, err = s.generateAndStoreTokens(ctx, v)
 	if err != nil {
 		return tkns, err
 	}
@@ -154,15 +223,51 @@ func (s *AuthService) Logout(ctx context.Context, t *auth.NewRefreshToken) (*emp
 	if err := t.Validate(); err != nil {
 		return e, aphgrpc.HandleInvalidParamError(ctx, err)
 	}
-	r, err := s.jwtAuth.Verify(t.RefreshToken)
+	c, err := s.verifyRefreshToken(t.RefreshToken)
 	if err != nil {
+		s.recordLogout(ctx, outcomeInvalidToken)
 		return e, aphgrpc.HandleAuthenticationError(ctx, err)
 	}
-	// get the claims from decoded refresh token
-	c := r.Claims.(jwt.MapClaims)
-	identityStr := fmt.Sprintf("%v", c["Identity"])
-	if err := s.repo.DeleteToken(identityStr); err != nil {
-		return e, aphgrpc.HandleNotFoundError(ctx, err)
+	audit.FromContext(ctx).SetIdentity(c.Identity, c.Provider)
+	// only the latest refresh token of the session may end it
+	if err := s.matchRefreshToken(ctx, c.SessionID, t.RefreshToken); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrTokenNotFound):
+			s.recordLogout(ctx, outcomeSessionNotFound)
+			return e, aphgrpc.HandleNotFoundError(ctx, err)
+		case errors.Is(err, errTokenMismatch):
+			s.recordLogout(ctx, outcomeInvalidToken)
+			return e, aphgrpc.HandleAuthenticationError(ctx, err)
+		}
+		s.recordLogout(ctx, outcomeError)
+		return e, aphgrpc.HandleGetError(ctx, err)
+	}
+	sess, err := tracing.WithContext(ctx, s.repo).GetSession(c.SessionID)
+	if err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			s.recordLogout(ctx, outcomeSessionNotFound)
+			return e, aphgrpc.HandleNotFoundError(ctx, err)
+		}
+		s.recordLogout(ctx, outcomeError)
+		return e, aphgrpc.HandleGetError(ctx, err)
+	}
+	audit.FromContext(ctx).SetSession(sess.UserID, sess.ID)
+	if err := tracing.WithContext(ctx, s.repo).DeleteSession(c.SessionID); err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			s.recordLogout(ctx, outcomeSessionNotFound)
+			return e, aphgrpc.HandleNotFoundError(ctx, err)
+		}
+		s.recordLogout(ctx, outcomeError)
+		return e, aphgrpc.HandleDeleteError(ctx, err)
+	}
+	s.recordLogout(ctx, metrics.OutcomeSuccess)
+	if err := message.PublishContext(
+		ctx,
+		s.publisher,
+		s.Topics["logout"],
+		newSessionEvent(authapi.AuthEvent_LOGOUT, sess),
+	); err != nil {
+		return e, aphgrpc.HandleMessagingPubError(ctx, err)
 	}
 	return e, nil
 }
@@ -175,12 +280,14 @@ func (s *AuthService) getUserAndIdentity(ctx context.Context, gt *tokenParams) (
 		Provider:   gt.provider,
 	})
 	if err != nil {
+		s.publishFailure(ctx, gt, authapi.AuthEvent_FAILURE_IDENTITY_NOT_FOUND)
 		return d, aphgrpc.HandleNotFoundError(ctx, err)
 	}
 	// get user data
 	uid := idn.Data.Attributes.UserId
 	ud, err := s.user.GetUser(ctx, &jsonapi.GetRequest{Id: uid})
 	if err != nil {
+		s.publishFailure(ctx, gt, authapi.AuthEvent_FAILURE_USER_NOT_FOUND)
 		return d, aphgrpc.HandleNotFoundError(ctx, err)
 	}
 	d.identity = idn
@@ -189,26 +296,92 @@ func (s *AuthService) getUserAndIdentity(ctx context.Context, gt *tokenParams) (
 }
 
 func (s *AuthService) generateAndStoreTokens(ctx context.Context, gt *tokenParams) (*auth.Token, error) {
+	tkns := &auth.Token{}
+	// locked accounts can neither login nor refresh their tokens
+	if err := s.checkLock(ctx, gt.session.UserID); err != nil {
+		if status.Code(err) == codes.PermissionDenied {
+			s.publishFailure(ctx, gt, authapi.AuthEvent_FAILURE_ACCOUNT_LOCKED)
+		}
+		return tkns, err
+	}
+	// roles and permissions are resolved again for every token, only a
+	// new login may use the cached permissions of the roles
+	authz, err := s.getAuthorization(ctx, gt.session.UserID, gt.login)
+	if err != nil {
+		return tkns, err
+	}
+	// generate new claims
+	gt.session.RefreshedAt = time.Now()
+	jwtClaims := generateAccessTokenClaims(gt.session, authz, gt.grant)
+	refTknClaims := generateRefreshTokenClaims(
+		gt.identity, gt.provider, gt.session.ID, gt.grant,
+	)
 	// generate tokens
-	tkns, err := s.generateBothTokens(ctx, gt)
+	tkns, err = s.generateBothTokens(ctx, jwtClaims, refTknClaims)
 	if err != nil {
 		return tkns, err
 	}
-	// store refresh token in repository
-	if err := s.repo.SetToken(gt.identity, tkns.RefreshToken, time.Minute*refreshTokenExpirationTimeInMins); err != nil {
-		return tkns, aphgrpc.HandleInsertError(ctx, err)
+	et, topic := authapi.AuthEvent_TOKEN_REFRESHED, s.Topics["tokenRefresh"]
+	if gt.login {
+		et, topic = authapi.AuthEvent_LOGIN_SUCCEEDED, s.Topics["tokenCreate"]
 	}
-	if err := s.publisher.PublishTokens(s.Topics["tokenCreate"], tkns); err != nil {
+	oe, err := outbox.NewEvent(
+		ctx, topic, newTokenEvent(et, gt.session, jwtClaims, refTknClaims),
+	)
+	if err != nil {
+		return tkns, aphgrpc.HandleError(ctx, err)
+	}
+	// store the session along with its refresh token in repository, the
+	// event is relayed to the publisher from the outbox. A refresh only
+	// updates a session that has not been revoked meanwhile.
+	store := tracing.WithContext(ctx, s.repo).RefreshSession
+	if gt.login {
+		store = tracing.WithContext(ctx, s.repo).SetSession
+	}
+	if err := store(
+		gt.session,
+		tkns.RefreshToken,
+		time.Minute*refreshTokenExpirationTimeInMins,
+		oe,
+	); err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			s.publishFailure(ctx, gt, authapi.AuthEvent_FAILURE_SESSION_NOT_FOUND)
+			return &auth.Token{}, aphgrpc.HandleNotFoundError(ctx, err)
+		}
 		return tkns, aphgrpc.HandleInsertError(ctx, err)
 	}
+	s.recordOutcome(ctx, gt, metrics.OutcomeSuccess)
 	return tkns, nil
 }
 
-func (s *AuthService) generateBothTokens(ctx context.Context, gt *tokenParams) (*auth.Token, error) {
+func (s *AuthService) checkLock(ctx context.Context, userID int64) error {
+	locked, err := tracing.WithContext(ctx, s.repo).IsUserLocked(userID)
+	if err != nil {
+		return aphgrpc.HandleGetError(ctx, err)
+	}
+	if locked {
+		return handleAccountLockedError(
+			ctx, fmt.Errorf("account of user %d is locked", userID),
+		)
+	}
+	return nil
+}
+
+// getRoles returns the roles of the user from the user service
+func (s *AuthService) getRoles(ctx context.Context, userID int64) ([]*user.RoleData, error) {
+	rc, err := s.user.GetRelatedRoles(ctx, &jsonapi.RelationshipRequest{Id: userID})
+	if err != nil {
+		return nil, aphgrpc.HandleGetError(ctx, err)
+	}
+	return rc.Data, nil
+}
+
+func (s *AuthService) generateBothTokens(
+	ctx context.Context,
+	jwtClaims AccessTokenClaims,
+	refTknClaims RefreshTokenClaims,
+) (*auth.Token, error) {
 	tkns := &auth.Token{}
-	// generate new claims
-	jwtClaims := generateStandardClaims(jwtExpirationTimeInMins)
-	refTknClaims := generateRefreshTokenClaims(gt.identity, gt.provider)
 	// generate new JWT and refresh token to send back
 	tknStr, err := s.jwtAuth.Encode(jwtClaims)
 	if err != nil {
@@ -223,54 +396,121 @@ func (s *AuthService) generateBothTokens(ctx context.Context, gt *tokenParams) (
 	return tkns, nil
 }
 
-func (s *AuthService) verifyTokens(ctx context.Context, t *auth.NewToken) (*jwt.Token, error) {
-	tkn := &jwt.Token{}
+func (s *AuthService) verifyTokens(ctx context.Context, t *auth.NewToken) (*RefreshTokenClaims, error) {
 	// if jwt exists, verify it is valid
 	if t.Token != "" {
 		_, err := s.jwtAuth.Verify(t.Token)
 		if err != nil {
-			return tkn, aphgrpc.HandleAuthenticationError(ctx, err)
+			s.metrics.VerificationFailure(err)
+			return &RefreshTokenClaims{}, aphgrpc.HandleAuthenticationError(ctx, err)
 		}
 	}
 	// verify refresh token
-	r, err := s.jwtAuth.Verify(t.RefreshToken)
+	c, err := s.verifyRefreshToken(t.RefreshToken)
 	if err != nil {
-		return tkn, aphgrpc.HandleAuthenticationError(ctx, err)
+		return c, aphgrpc.HandleAuthenticationError(ctx, err)
 	}
-	return r, nil
+	return c, nil
+}
+
+// verifyRefreshToken verifies the signature and the type of the refresh
+// token and returns its claims
+func (s *AuthService) verifyRefreshToken(tkn string) (*RefreshTokenClaims, error) {
+	c := &RefreshTokenClaims{}
+	_, err := s.jwtAuth.VerifyClaims(tkn, c)
+	if err == nil {
+		err = c.checkType()
+	}
+	if err != nil {
+		s.metrics.VerificationFailure(err)
+	}
+	return c, err
+}
+
+// matchRefreshToken compares the refresh token with the digest stored for
+// its session, a replaced token gives errTokenMismatch
+func (s *AuthService) matchRefreshToken(ctx context.Context, sessionID, tkn string) error {
+	d, err := tracing.WithContext(ctx, s.repo).GetToken(sessionID)
+	if err != nil {
+		return err
+	}
+	rd := repository.Digest(tkn)
+	if subtle.ConstantTimeCompare([]byte(d), []byte(rd)) != 1 {
+		return errTokenMismatch
+	}
+	return nil
 }
 
 func (s *AuthService) validateTokens(ctx context.Context, t *auth.NewToken) (*tokenParams, error) {
 	tkn := &tokenParams{}
-	r, err := s.verifyTokens(ctx, t)
+	// get the claims from decoded refresh token
+	c, err := s.verifyTokens(ctx, t)
 	if err != nil {
+		s.publishFailure(ctx, tkn, authapi.AuthEvent_FAILURE_INVALID_TOKEN)
 		return tkn, err
 	}
-	// get the claims from decoded refresh token
-	c := r.Claims.(jwt.MapClaims)
-	identityStr := fmt.Sprintf("%v", c["Identity"])
-	provider := fmt.Sprintf("%v", c["Provider"])
-	// verify existence of refresh token in repository
-	h, err := s.repo.HasToken(identityStr)
+	tkn.identity, tkn.provider = c.Identity, c.Provider
+	// the lockout is keyed by the digest of the identity, the refreshes
+	// are refused while it cannot be checked
+	d, err := s.lockout.Locked(repository.Digest(c.Identity))
 	if err != nil {
+		return tkn, handleUnavailableError(ctx, err)
+	}
+	if d > 0 {
+		s.publishFailure(ctx, tkn, authapi.AuthEvent_FAILURE_LOCKED_OUT)
+		return tkn, ratelimit.Exhausted(
+			ctx, d, fmt.Errorf("refresh of %s is locked for %s", c.Identity, d),
+		)
+	}
+	// verify refresh token against the digest stored for its session
+	if err := s.matchRefreshToken(ctx, c.SessionID, t.RefreshToken); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrTokenNotFound):
+			s.refreshFailure(ctx, tkn)
+			return tkn, aphgrpc.HandleNotFoundError(
+				ctx, fmt.Errorf("refresh token %s not found", c.Identity),
+			)
+		case errors.Is(err, errTokenMismatch):
+			s.refreshFailure(ctx, tkn)
+			return tkn, aphgrpc.HandleAuthenticationError(
+				ctx, fmt.Errorf("refresh token %s does not match", c.Identity),
+			)
+		}
 		return tkn, aphgrpc.HandleGetError(ctx, err)
 	}
-	if !h {
-		return tkn, aphgrpc.HandleNotFoundError(ctx, fmt.Errorf("refresh token %s not found", identityStr))
+	sess, err := tracing.WithContext(ctx, s.repo).GetSession(c.SessionID)
+	if err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			s.refreshFailure(ctx, tkn)
+			return tkn, aphgrpc.HandleNotFoundError(ctx, err)
+		}
+		return tkn, aphgrpc.HandleGetError(ctx, err)
 	}
-	tkn = &tokenParams{
-		identity: identityStr,
-		provider: provider,
+	tkn.session = sess
+	s.lockout.Reset(repository.Digest(c.Identity)) //nolint:errcheck
+	tkn.grant = c.grant()
+	if err := s.authorizeGrant(ctx, tkn); err != nil {
+		return tkn, err
 	}
 	return tkn, nil
 }
 
+// refreshFailure publishes the refresh with a revoked or replaced token,
+// which counts towards the lockout of its identity
+func (s *AuthService) refreshFailure(ctx context.Context, tkn *tokenParams) {
+	s.publishFailure(ctx, tkn, authapi.AuthEvent_FAILURE_SESSION_NOT_FOUND)
+	s.lockout.Failure(repository.Digest(tkn.identity)) //nolint:errcheck
+}
+
 func (s *AuthService) createTokens(ctx context.Context, tp *tokenParams) (*auth.Auth, error) {
 	a := &auth.Auth{}
 	d, err := s.getUserAndIdentity(ctx, tp)
 	if err != nil {
 		return a, err
 	}
+	if tp.login {
+		tp.session = newSession(ctx, d.user.Data.Id, tp.provider)
+	}
 	tkns, err := s.generateAndStoreTokens(ctx, tp)
 	if err != nil {
 		return a, err
@@ -283,3 +523,86 @@ func (s *AuthService) createTokens(ctx context.Context, tp *tokenParams) (*auth.
 	}
 	return a, nil
 }
+
+// publishFailure publishes the failed login or refresh. An error in
+// publishing is dropped in favor of the failure that is returned to the
+// client.
+func (s *AuthService) publishFailure(
+	ctx context.Context,
+	tp *tokenParams,
+	f authapi.AuthEvent_Failure,
+) {
+	s.recordOutcome(ctx, tp, failureOutcome(f))
+	message.PublishContext( //nolint:errcheck
+		ctx,
+		s.publisher,
+		s.Topics["authFailure"],
+		newFailureEvent(ctx, tp.failureType(), f, tp),
+	)
+}
+
+func (tp *tokenParams) failureType() authapi.AuthEvent_Type {
+	if tp.login {
+		return authapi.AuthEvent_LOGIN_FAILED
+	}
+	return authapi.AuthEvent_REFRESH_FAILED
+}
+
+// recordOutcome counts the login or refresh and completes its audit
+// record, the provider of a login is only used as label if it is
+// supported to bound the number of series
+func (s *AuthService) recordOutcome(ctx context.Context, tp *tokenParams, outcome string) {
+	rec := audit.FromContext(ctx)
+	rec.SetIdentity(tp.identity, tp.provider)
+	if tp.session != nil {
+		rec.SetSession(tp.session.UserID, tp.session.ID)
+	}
+	if outcome != metrics.OutcomeSuccess {
+		rec.SetFailure(outcome)
+	}
+	if !tp.login {
+		s.metrics.Refresh(outcome)
+		return
+	}
+	provider := tp.provider
+	if !supportedProvider(provider) {
+		provider = "unknown"
+	}
+	s.metrics.Login(provider, outcome)
+}
+
+// recordLogout counts the logout and sets the outcome of its audit record
+func (s *AuthService) recordLogout(ctx context.Context, outcome string) {
+	s.metrics.Logout(outcome)
+	if outcome != metrics.OutcomeSuccess {
+		audit.FromContext(ctx).SetFailure(outcome)
+	}
+}
+
+// failureOutcome returns the metric label of the failure, such as
+// provider_login for FAILURE_PROVIDER_LOGIN
+func failureOutcome(f authapi.AuthEvent_Failure) string {
+	return strings.ToLower(strings.TrimPrefix(f.String(), "FAILURE_"))
+}
+
+// RequestIdentity returns the function that gives the identity of the
+// verified refresh token of a Relogin or GetRefreshToken request, the
+// identity is empty for any other request
+func RequestIdentity(ja jwtauth.JWTAuth) func(context.Context, interface{}) string {
+	return func(_ context.Context, req interface{}) string {
+		var tkn string
+		switch r := req.(type) {
+		case *auth.NewRelogin:
+			tkn = r.RefreshToken
+		case *auth.NewToken:
+			tkn = r.RefreshToken
+		default:
+			return ""
+		}
+		c := &RefreshTokenClaims{}
+		if _, err := ja.VerifyClaims(tkn, c); err != nil || c.checkType() != nil {
+			return ""
+		}
+		return c.Identity
+	}
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dictyBase/aphgrpc"
+	"github.com/dictyBase/go-genproto/dictybaseapis/api/jsonapi"
+	"github.com/dictyBase/go-genproto/dictybaseapis/auth"
+	"github.com/dictyBase/go-genproto/dictybaseapis/identity"
+	"github.com/dictyBase/go-genproto/dictybaseapis/user"
+	"github.com/dictyBase/modware-auth/internal/audit"
+	"github.com/dictyBase/modware-auth/internal/authapi"
+	"github.com/dictyBase/modware-auth/internal/jwtauth"
+	"github.com/dictyBase/modware-auth/internal/message/recording"
+	"github.com/dictyBase/modware-auth/internal/oauth"
+	"github.com/dictyBase/modware-auth/internal/ratelimit"
+	"github.com/dictyBase/modware-auth/internal/repository"
+	goredis "github.com/go-redis/redis/v7"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var testTopics = map[string]string{
+	"tokenCreate":  "AuthService.Create",
+	"tokenRefresh": "AuthService.Refresh",
+	"authFailure":  "AuthService.Failure",
+	"logout":       "AuthService.Logout",
+}
+
+// the tests below only reach the roles and permissions of the user service
+// and never the identity service
+type userClient struct {
+	user.UserServiceClient
+	roles []*user.RoleData
+}
+
+func (u *userClient) GetRelatedRoles(ctx context.Context, r *jsonapi.RelationshipRequest, opts ...grpc.CallOption) (*user.RoleCollection, error) {
+	return &user.RoleCollection{Data: u.roles}, nil
+}
+
+type roleClient struct {
+	user.RoleServiceClient
+	// permissions of the roles by their id
+	permissions map[int64][]*user.PermissionData
+	// calls counts the lookups of the permissions
+	calls int32
+}
+
+func (rc *roleClient) GetRelatedPermissions(ctx context.Context, r *jsonapi.RelationshipRequest, opts ...grpc.CallOption) (*user.PermissionCollection, error) {
+	atomic.AddInt32(&rc.calls, 1)
+	return &user.PermissionCollection{Data: rc.permissions[r.Id]}, nil
+}
+
+type identityClient struct {
+	identity.IdentityServiceClient
+}
+
+func newTestAuthService(t *testing.T, ja *jwtauth.JWTAuth, repo repository.AuthRepository, pub *recording.Publisher) *AuthService {
+	t.Helper()
+	srv, err := NewAuthService(&ServiceParams{
+		Repository:      repo,
+		Publisher:       pub,
+		User:            &userClient{},
+		Role:            &roleClient{},
+		Identity:        &identityClient{},
+		JWTAuth:         *ja,
+		ProviderSecrets: oauth.ProviderSecrets{},
+		Options:         []aphgrpc.Option{aphgrpc.TopicsOption(testTopics)},
+	})
+	if err != nil {
+		t.Fatalf("error in creating auth service %s", err)
+	}
+	return srv
+}
+
+// storeRefreshToken stores the session along with a refresh token for it
+func storeRefreshToken(t *testing.T, ja *jwtauth.JWTAuth, repo repository.AuthRepository, sess *repository.Session) string {
+	t.Helper()
+	tkn, err := ja.Encode(generateRefreshTokenClaims("jo@dicty.org", sess.Provider, sess.ID, grant{audience: DefaultAudience}))
+	if err != nil {
+		t.Fatalf("error in encoding refresh token %s", err)
+	}
+	if err := repo.SetSession(sess, tkn, time.Hour); err != nil {
+		t.Fatalf("error in storing session %s", err)
+	}
+	return tkn
+}
+
+func TestAuthServiceLoginFailure(t *testing.T) {
+	assert := assert.New(t)
+	pub := recording.NewPublisher()
+	srv := newTestAuthService(t, newTestJwtAuth(t), newTestRepo(t), pub)
+	_, err := srv.Login(context.Background(), &auth.NewLogin{
+		ClientId: "client", State: "state", Code: "code", Scopes: "email",
+		RedirectUrl: "http://localhost", Provider: "myspace",
+	})
+	assert.Equal(codes.InvalidArgument, status.Code(err), "should reject unknown provider")
+	assert.Equal([]string{"AuthService.Failure"}, pub.Subjects(), "should publish on failure subject")
+	events := pub.Events()
+	assert.Equal(authapi.AuthEvent_LOGIN_FAILED, events[0].Type, "should publish failed login")
+	assert.Equal(
+		authapi.AuthEvent_FAILURE_UNSUPPORTED_PROVIDER,
+		events[0].Failure,
+		"should publish the class of failure",
+	)
+	assert.Equal("myspace", events[0].Provider, "should publish the provider")
+	pub.Fail(errors.New("nats: connection closed"))
+	_, err = srv.Login(context.Background(), &auth.NewLogin{})
+	assert.Equal(
+		codes.InvalidArgument,
+		status.Code(err),
+		"should return the failure instead of the publishing error",
+	)
+}
+
+func TestAuthServiceRefreshFailure(t *testing.T) {
+	assert := assert.New(t)
+	ja, repo, pub := newTestJwtAuth(t), newTestRepo(t), recording.NewPublisher()
+	srv := newTestAuthService(t, ja, repo, pub)
+	_, err := srv.GetRefreshToken(context.Background(), &auth.NewToken{RefreshToken: "garbage"})
+	assert.Equal(codes.Unauthenticated, status.Code(err), "should reject malformed token")
+	sess := newSession(context.Background(), 7, "google")
+	tkn := storeRefreshToken(t, ja, repo, sess)
+	assert.NoError(repo.DeleteSession(sess.ID), "error in deleting session")
+	_, err = srv.GetRefreshToken(context.Background(), &auth.NewToken{RefreshToken: tkn})
+	assert.Equal(codes.NotFound, status.Code(err), "should reject token of revoked session")
+	events := pub.Events()
+	assert.Len(events, 2, "should publish every failure")
+	for _, e := range events {
+		assert.Equal(authapi.AuthEvent_REFRESH_FAILED, e.Type, "should publish failed refresh")
+	}
+	assert.Equal(authapi.AuthEvent_FAILURE_INVALID_TOKEN, events[0].Failure, "should classify malformed token")
+	assert.Equal(authapi.AuthEvent_FAILURE_SESSION_NOT_FOUND, events[1].Failure, "should classify revoked session")
+	assert.Equal(repository.Digest("jo@dicty.org"), events[1].IdentityDigest, "should publish digest of identity")
+}
+
+func TestAuthServiceLogout(t *testing.T) {
+	assert := assert.New(t)
+	ja, repo, pub := newTestJwtAuth(t), newTestRepo(t), recording.NewPublisher()
+	srv := newTestAuthService(t, ja, repo, pub)
+	sess := newSession(context.Background(), 7, "google")
+	tkn := storeRefreshToken(t, ja, repo, sess)
+	_, err := srv.Logout(context.Background(), &auth.NewRefreshToken{RefreshToken: tkn})
+	assert.NoError(err, "error in logging out")
+	assert.Equal([]string{"AuthService.Logout"}, pub.Subjects(), "should publish on logout subject")
+	events := pub.Events()
+	assert.Equal(authapi.AuthEvent_LOGOUT, events[0].Type, "should publish logout")
+	assert.Equal(sess.ID, events[0].SessionId, "should publish the session")
+	assert.Equal(int64(7), events[0].UserId, "should publish the user")
+	_, err = repo.GetSession(sess.ID)
+	assert.ErrorIs(err, repository.ErrSessionNotFound, "should remove session")
+}
+
+func TestAuthServiceLogoutToken(t *testing.T) {
+	assert := assert.New(t)
+	ja, repo, pub := newTestJwtAuth(t), newTestRepo(t), recording.NewPublisher()
+	srv := newTestAuthService(t, ja, repo, pub)
+	sess := newSession(context.Background(), 7, "google")
+	old := storeRefreshToken(t, ja, repo, sess)
+	// rotate the refresh token of the session
+	storeRefreshToken(t, ja, repo, sess)
+	_, err := srv.Logout(context.Background(), &auth.NewRefreshToken{RefreshToken: old})
+	assert.Equal(codes.Unauthenticated, status.Code(err), "should reject replaced refresh token")
+	access, err := ja.Encode(generateAccessTokenClaims(sess, &authorization{}, grant{audience: DefaultAudience}))
+	assert.NoError(err, "error in encoding access token")
+	_, err = srv.Logout(context.Background(), &auth.NewRefreshToken{RefreshToken: access})
+	assert.Equal(codes.Unauthenticated, status.Code(err), "should reject access token")
+	_, err = srv.GetRefreshToken(context.Background(), &auth.NewToken{RefreshToken: access})
+	assert.Equal(codes.Unauthenticated, status.Code(err), "should not refresh with access token")
+	_, err = repo.GetSession(sess.ID)
+	assert.NoError(err, "should keep session")
+	for _, s := range pub.Subjects() {
+		assert.NotEqual("AuthService.Logout", s, "should not publish logout")
+	}
+}
+
+func TestAuthServiceRefreshLockout(t *testing.T) {
+	assert := assert.New(t)
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("unable to start miniredis %s", err)
+	}
+	defer mr.Close()
+	client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	lockout, err := ratelimit.NewLockout(&ratelimit.LockoutParams{
+		Client: client, Namespace: "modware-auth-test",
+		Threshold: 2, Base: time.Minute, Max: time.Hour,
+	})
+	assert.NoError(err, "error in creating lockout")
+	ja, repo, pub := newTestJwtAuth(t), newTestRepo(t), recording.NewPublisher()
+	srv := newTestAuthService(t, ja, repo, pub)
+	srv.lockout = lockout
+	sess := newSession(context.Background(), 7, "google")
+	tkn := storeRefreshToken(t, ja, repo, sess)
+	assert.NoError(repo.DeleteSession(sess.ID), "error in deleting session")
+	for i := 0; i < 2; i++ {
+		_, err = srv.GetRefreshToken(context.Background(), &auth.NewToken{RefreshToken: tkn})
+		assert.Equal(codes.NotFound, status.Code(err), "should reject token of revoked session")
+	}
+	_, err = srv.GetRefreshToken(context.Background(), &auth.NewToken{RefreshToken: tkn})
+	assert.Equal(codes.ResourceExhausted, status.Code(err), "should lock out identity after repeated failures")
+	events := pub.Events()
+	assert.Equal(authapi.AuthEvent_FAILURE_LOCKED_OUT, events[2].Failure, "should classify locked out refresh")
+	_, err = srv.GetRefreshToken(context.Background(), &auth.NewToken{RefreshToken: "garbage"})
+	assert.Equal(codes.Unauthenticated, status.Code(err), "should not lock out unverified tokens")
+	assert.Equal("jo@dicty.org", RequestIdentity(*ja)(context.Background(), &auth.NewRelogin{RefreshToken: tkn}), "should return identity of verified token")
+	assert.Empty(RequestIdentity(*ja)(context.Background(), &auth.NewRelogin{RefreshToken: "garbage"}), "should not return identity of invalid token")
+	for _, k := range mr.Keys() {
+		assert.NotContains(k, "jo@dicty.org", "should not keep raw identity in lockout keys")
+	}
+	mr.Close()
+	_, err = srv.GetRefreshToken(context.Background(), &auth.NewToken{RefreshToken: tkn})
+	assert.Equal(codes.Unavailable, status.Code(err), "should refuse refresh while lockout is unreachable")
+}
+
+func TestAuthServiceAudit(t *testing.T) {
+	assert := assert.New(t)
+	ja, repo, pub := newTestJwtAuth(t), newTestRepo(t), recording.NewPublisher()
+	srv := newTestAuthService(t, ja, repo, pub)
+	sess := newSession(context.Background(), 7, "google")
+	tkn := storeRefreshToken(t, ja, repo, sess)
+	rec := &audit.Record{}
+	_, err := srv.Logout(audit.NewContext(context.Background(), rec), &auth.NewRefreshToken{RefreshToken: tkn})
+	assert.NoError(err, "error in logging out")
+	assert.Equal(int64(7), rec.UserID, "should audit the user")
+	assert.Equal(sess.ID, rec.SessionID, "should audit the session")
+	assert.Equal(repository.Digest("jo@dicty.org"), rec.IdentityDigest, "should audit digest of identity")
+	rec = &audit.Record{}
+	_, err = srv.GetRefreshToken(audit.NewContext(context.Background(), rec), &auth.NewToken{RefreshToken: tkn})
+	assert.Equal(codes.NotFound, status.Code(err), "should reject token of revoked session")
+	assert.Equal("google", rec.Provider, "should audit the provider")
+	assert.Equal(repository.Digest("jo@dicty.org"), rec.IdentityDigest, "should audit digest of identity")
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/dictyBase/aphgrpc"
+	"github.com/dictyBase/modware-auth/internal/authapi"
+	"github.com/dictyBase/modware-auth/internal/jwtauth"
+	"github.com/dictyBase/modware-auth/internal/message"
+	"github.com/dictyBase/modware-auth/internal/metrics"
+	"github.com/dictyBase/modware-auth/internal/repository"
+	"github.com/dictyBase/modware-auth/internal/tracing"
+	"github.com/go-playground/validator/v10"
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/rs/xid"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// SessionService is the container for managing the login sessions of
+// the authenticated user
+type SessionService struct {
+	authapi.UnimplementedSessionServiceServer
+	repo      repository.AuthRepository
+	publisher message.Publisher
+	jwtAuth   jwtauth.JWTAuth
+	topic     string
+	metrics   *metrics.Metrics
+}
+
+// SessionParams are the attributes that are required for creating a new SessionService
+type SessionParams struct {
+	Repository repository.AuthRepository `validate:"required"`
+	Publisher  message.Publisher         `validate:"required"`
+	JWTAuth    jwtauth.JWTAuth           `validate:"required"`
+	// Topic for publishing the revocations
+	Topic string `validate:"required"`
+	// Metrics records the failed verifications, optional
+	Metrics *metrics.Metrics
+}
+
+// NewSessionService is the constructor for creating a new instance of SessionService
+func NewSessionService(srvP *SessionParams) (*SessionService, error) {
+	if err := validator.New().Struct(srvP); err != nil {
+		return &SessionService{}, err
+	}
+	return &SessionService{
+		repo:      srvP.Repository,
+		publisher: srvP.Publisher,
+		jwtAuth:   srvP.JWTAuth,
+		topic:     srvP.Topic,
+		metrics:   srvP.Metrics,
+	}, nil
+}
+
+func (s *SessionService) ListSessions(ctx context.Context, e *empty.Empty) (*authapi.SessionCollection, error) {
+	sc := &authapi.SessionCollection{}
+	c, err := authenticate(ctx, s.jwtAuth, s.metrics, "")
+	if err != nil {
+		return sc, err
+	}
+	sl, err := tracing.WithContext(ctx, s.repo).ListSessions(c.UserID)
+	if err != nil {
+		return sc, aphgrpc.HandleGetError(ctx, err)
+	}
+	for _, sess := range sl {
+		ps := sessionToProto(sess)
+		ps.Current = sess.ID == c.SessionID
+		sc.Sessions = append(sc.Sessions, ps)
+	}
+	return sc, nil
+}
+
+func (s *SessionService) RevokeSession(ctx context.Context, r *authapi.SessionIdRequest) (*empty.Empty, error) {
+	e := &empty.Empty{}
+	c, err := authenticate(ctx, s.jwtAuth, s.metrics, "")
+	if err != nil {
+		return e, err
+	}
+	sess, err := tracing.WithContext(ctx, s.repo).GetSession(r.Id)
+	if err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			return e, aphgrpc.HandleNotFoundError(ctx, err)
+		}
+		return e, aphgrpc.HandleGetError(ctx, err)
+	}
+	// sessions of other users are reported as missing
+	if sess.UserID != c.UserID {
+		return e, aphgrpc.HandleNotFoundError(ctx, repository.ErrSessionNotFound)
+	}
+	if err := tracing.WithContext(ctx, s.repo).DeleteSession(r.Id); err != nil {
+		return e, aphgrpc.HandleDeleteError(ctx, err)
+	}
+	ev := newSessionEvent(authapi.AuthEvent_SESSIONS_REVOKED, sess)
+	ev.RevokedSessions = 1
+	return e, s.publish(ctx, ev)
+}
+
+func (s *SessionService) RevokeAllSessions(ctx context.Context, e *empty.Empty) (*authapi.RevokedSessions, error) {
+	rs := &authapi.RevokedSessions{}
+	c, err := authenticate(ctx, s.jwtAuth, s.metrics, "")
+	if err != nil {
+		return rs, err
+	}
+	n, err := tracing.WithContext(ctx, s.repo).DeleteUserSessions(c.UserID)
+	if err != nil {
+		return rs, aphgrpc.HandleDeleteError(ctx, err)
+	}
+	rs.Count = int64(n)
+	ev := newEvent(authapi.AuthEvent_SESSIONS_REVOKED)
+	ev.UserId = c.UserID
+	ev.ActorId = c.UserID
+	ev.RevokedSessions = rs.Count
+	return rs, s.publish(ctx, ev)
+}
+
+func (s *SessionService) publish(ctx context.Context, e *authapi.AuthEvent) error {
+	if err := message.PublishContext(ctx, s.publisher, s.topic, e); err != nil {
+		return aphgrpc.HandleMessagingPubError(ctx, err)
+	}
+	return nil
+}
+
+// authenticate verifies the bearer access token given in the authorization
+// metadata of the request and returns its claims, the token has to be
+// issued for the audience unless it is empty
+func authenticate(ctx context.Context, ja jwtauth.JWTAuth, m *metrics.Metrics, audience string) (*AccessTokenClaims, error) {
+	c := &AccessTokenClaims{}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return c, aphgrpc.HandleAuthenticationError(ctx, jwtauth.ErrNoTokenFound)
+	}
+	var tkn string
+	for _, v := range md.Get("authorization") {
+		if strings.HasPrefix(strings.ToLower(v), "bearer ") {
+			tkn = strings.TrimSpace(v[len("bearer "):])
+			break
+		}
+	}
+	if len(tkn) == 0 {
+		m.VerificationFailure(jwtauth.ErrNoTokenFound)
+		return c, aphgrpc.HandleAuthenticationError(ctx, jwtauth.ErrNoTokenFound)
+	}
+	var err error
+	if len(audience) > 0 {
+		_, err = ja.VerifyAudience(tkn, c, audience)
+	} else {
+		_, err = ja.VerifyClaims(tkn, c)
+	}
+	if err == nil {
+		err = c.checkType()
+	}
+	if err != nil {
+		m.VerificationFailure(err)
+		return c, aphgrpc.HandleAuthenticationError(ctx, err)
+	}
+	return c, nil
+}
+
+// newSession creates the session for a new login of the user
+func newSession(ctx context.Context, userID int64, provider string) *repository.Session {
+	now := time.Now()
+	ip, ua := ClientInfo(ctx)
+	return &repository.Session{
+		ID:          xid.New().String(),
+		UserID:      userID,
+		Provider:    provider,
+		ClientIP:    ip,
+		UserAgent:   ua,
+		CreatedAt:   now,
+		RefreshedAt: now,
+	}
+}
+
+// ClientIP returns the address of the client of the request
+func ClientIP(ctx context.Context) string {
+	ip, _ := ClientInfo(ctx)
+	return ip
+}
+
+// ClientInfo returns the address and the user agent of the client. The
+// address is the one resolved by the interceptor of the TrustedProxies,
+// otherwise it is the address of the peer.
+func ClientInfo(ctx context.Context) (string, string) {
+	ip, ok := ctx.Value(clientAddrKey{}).(string)
+	if !ok {
+		ip = peerAddr(ctx)
+	}
+	var ua string
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ip, ua
+	}
+	if agent := md.Get("user-agent"); len(agent) > 0 {
+		ua = agent[0]
+	}
+	return ip, ua
+}
+
+func sessionToProto(sess *repository.Session) *authapi.Session {
+	return &authapi.Session{
+		Id:          sess.ID,
+		UserId:      sess.UserID,
+		Provider:    sess.Provider,
+		ClientIp:    sess.ClientIP,
+		UserAgent:   sess.UserAgent,
+		CreatedAt:   timestamppb.New(sess.CreatedAt),
+		RefreshedAt: timestamppb.New(sess.RefreshedAt),
+	}
+}
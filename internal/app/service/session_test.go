@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dictyBase/modware-auth/internal/authapi"
+	"github.com/dictyBase/modware-auth/internal/jwtauth"
+	"github.com/dictyBase/modware-auth/internal/message/recording"
+	"github.com/dictyBase/modware-auth/internal/repository"
+	"github.com/dictyBase/modware-auth/internal/repository/redis"
+	"github.com/golang-jwt/jwt"
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func newTestJwtAuth(t *testing.T) *jwtauth.JWTAuth {
+	t.Helper()
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error in generating key %s", err)
+	}
+	return jwtauth.NewJwtAuth(jwt.SigningMethodRS512, private, &private.PublicKey)
+}
+
+func newTestRepo(t *testing.T) repository.AuthRepository {
+	t.Helper()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("unable to start miniredis %s", err)
+	}
+	t.Cleanup(mr.Close)
+	repo, err := redis.NewAuthRepo(mr.Addr(), "modware-auth-test")
+	if err != nil {
+		t.Fatalf("error connecting to redis %s", err)
+	}
+	t.Cleanup(func() { repo.Close() }) //nolint:errcheck
+	return repo
+}
+
+// bearerContext returns an incoming context carrying an access token for
+// the session with the given roles
+func bearerContext(t *testing.T, ja *jwtauth.JWTAuth, sess *repository.Session, roles ...string) context.Context {
+	t.Helper()
+	return grantContext(t, ja, sess, grant{audience: DefaultAudience}, roles...)
+}
+
+// grantContext returns an incoming context carrying an access token of the
+// grant for the session with the given roles
+func grantContext(t *testing.T, ja *jwtauth.JWTAuth, sess *repository.Session, g grant, roles ...string) context.Context {
+	t.Helper()
+	tkn, err := ja.Encode(generateAccessTokenClaims(sess, &authorization{roles: roles}, g))
+	if err != nil {
+		t.Fatalf("error in encoding access token %s", err)
+	}
+	return metadata.NewIncomingContext(
+		context.Background(),
+		metadata.Pairs("authorization", fmt.Sprintf("Bearer %s", tkn)),
+	)
+}
+
+func storeSessions(t *testing.T, repo repository.AuthRepository, sessions ...*repository.Session) {
+	t.Helper()
+	for _, sess := range sessions {
+		if err := repo.SetSession(sess, sess.ID, time.Hour); err != nil {
+			t.Fatalf("error in storing session %s", err)
+		}
+	}
+}
+
+func TestSessionServiceList(t *testing.T) {
+	assert := assert.New(t)
+	ja, repo := newTestJwtAuth(t), newTestRepo(t)
+	current := newSession(context.Background(), 7, "google")
+	other := newSession(context.Background(), 7, "orcid")
+	storeSessions(t, repo, current, other, newSession(context.Background(), 8, "google"))
+	srv, err := NewSessionService(&SessionParams{
+		Repository: repo,
+		Publisher:  recording.NewPublisher(),
+		JWTAuth:    *ja,
+		Topic:      "AuthService.Revoke",
+	})
+	assert.NoError(err, "error in creating session service")
+	sc, err := srv.ListSessions(bearerContext(t, ja, current), &empty.Empty{})
+	assert.NoError(err, "error in listing sessions")
+	assert.Len(sc.Sessions, 2, "should list the sessions of the user")
+	for _, s := range sc.Sessions {
+		assert.Equal(s.Id == current.ID, s.Current, "should flag current session")
+	}
+	_, err = srv.ListSessions(context.Background(), &empty.Empty{})
+	assert.Equal(
+		codes.Unauthenticated,
+		status.Code(err),
+		"should reject request without access token",
+	)
+	claims := generateAccessTokenClaims(current, &authorization{}, grant{audience: DefaultAudience})
+	claims.TokenType = ""
+	tkn, err := ja.Encode(claims)
+	assert.NoError(err, "error in encoding access token")
+	_, err = srv.ListSessions(
+		metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+tkn)),
+		&empty.Empty{},
+	)
+	assert.Equal(
+		codes.Unauthenticated,
+		status.Code(err),
+		"should reject access token without type",
+	)
+}
+
+func TestSessionServiceRevoke(t *testing.T) {
+	assert := assert.New(t)
+	ja, repo := newTestJwtAuth(t), newTestRepo(t)
+	current := newSession(context.Background(), 7, "google")
+	other := newSession(context.Background(), 7, "orcid")
+	foreign := newSession(context.Background(), 8, "google")
+	storeSessions(t, repo, current, other, foreign)
+	pub := recording.NewPublisher()
+	srv, err := NewSessionService(&SessionParams{
+		Repository: repo,
+		Publisher:  pub,
+		JWTAuth:    *ja,
+		Topic:      "AuthService.Revoke",
+	})
+	assert.NoError(err, "error in creating session service")
+	ctx := bearerContext(t, ja, current)
+	_, err = srv.RevokeSession(ctx, &authapi.SessionIdRequest{Id: foreign.ID})
+	assert.Equal(
+		codes.NotFound,
+		status.Code(err),
+		"should not revoke session of another user",
+	)
+	_, err = srv.RevokeSession(ctx, &authapi.SessionIdRequest{Id: other.ID})
+	assert.NoError(err, "error in revoking session")
+	_, err = repo.GetSession(other.ID)
+	assert.ErrorIs(err, repository.ErrSessionNotFound, "should delete session")
+	rs, err := srv.RevokeAllSessions(ctx, &empty.Empty{})
+	assert.NoError(err, "error in revoking all sessions")
+	assert.Equal(int64(1), rs.Count, "should revoke remaining session")
+	_, err = repo.GetSession(foreign.ID)
+	assert.NoError(err, "should keep sessions of other users")
+	events := pub.Events()
+	assert.Len(events, 2, "should publish every revocation")
+	for _, e := range events {
+		assert.Equal(authapi.AuthEvent_SESSIONS_REVOKED, e.Type)
+		assert.Equal(current.UserID, e.UserId, "should record the user")
+	}
+}
@@ -1,38 +1,160 @@
 package service
 
 import (
+	"errors"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/dictyBase/modware-auth/internal/repository"
 	"github.com/golang-jwt/jwt"
 	"github.com/rs/xid"
 )
 
+const (
+	// accessTokenType is the type claim of the access tokens
+	accessTokenType = "access"
+	// refreshTokenType is the type claim of the refresh tokens
+	refreshTokenType = "refresh"
+	// refreshTokenSubject is the subject of the refresh tokens, which
+	// identify the user by their identity
+	refreshTokenSubject = "dictyBase login token"
+)
+
+// errTokenType is returned for a token that is signed by the service but
+// is of another type, such as an access token given as refresh token
+var errTokenType = errors.New("token is not of the expected type")
+
+// errTokenMismatch is returned for a refresh token that has been replaced
+// by a refresh of its session
+var errTokenMismatch = errors.New("refresh token has been replaced")
+
 type RefreshTokenClaims struct {
+	// TokenType tells the refresh tokens apart from the access tokens that
+	// are signed with the same key
+	TokenType string `json:",omitempty"`
 	// Identity is used as an identifier for a user's identity data
 	// (it is an ID for orcid, an email for others)
 	Identity string
 	// Provider is the login provider
 	Provider string
+	// SessionID identifies the login session of the token
+	SessionID string
+	// ClientID is the application that requested the tokens
+	ClientID string `json:",omitempty"`
+	// Scope is the space separated list of the scopes of the access
+	// tokens, their audience is the audience of the refresh token
+	Scope string `json:",omitempty"`
+	// Standard JWT claims
+	jwt.StandardClaims
+}
+
+type AccessTokenClaims struct {
+	// TokenType tells the access tokens apart from the refresh tokens that
+	// are signed with the same key
+	TokenType string `json:",omitempty"`
+	// UserID is the id of the user in the user service
+	UserID int64
+	// SessionID identifies the login session the token was issued for
+	SessionID string
+	// Roles are the names of the roles of the user
+	Roles []string
+	// Permissions are the permissions of the roles, given as
+	// permission:resource
+	Permissions []string `json:",omitempty"`
+	// PermissionsOmitted is set when the permissions are left out for the
+	// size of the token, they have to be looked up in the user service
+	PermissionsOmitted bool `json:",omitempty"`
+	// Scope is the space separated list of the granted scopes
+	Scope string `json:"scope,omitempty"`
 	// Standard JWT claims
 	jwt.StandardClaims
 }
 
-func generateStandardClaims(expirationMinutes time.Duration) jwt.StandardClaims {
+func generateStandardClaims(expirationMinutes time.Duration, subject, audience string) jwt.StandardClaims {
 	return jwt.StandardClaims{
 		Issuer:    "dictyBase",
-		Subject:   "dictyBase login token",
+		Subject:   subject,
 		ExpiresAt: time.Now().Add(time.Minute * expirationMinutes).Unix(),
 		IssuedAt:  time.Now().Unix(),
 		NotBefore: time.Now().Unix(),
 		Id:        xid.New().String(),
-		Audience:  "user",
+		Audience:  audience,
+	}
+}
+
+func generateAccessTokenClaims(sess *repository.Session, a *authorization, g grant) AccessTokenClaims {
+	return AccessTokenClaims{
+		TokenType:          accessTokenType,
+		UserID:             sess.UserID,
+		SessionID:          sess.ID,
+		Roles:              a.roles,
+		Permissions:        a.permissions,
+		PermissionsOmitted: a.omitted,
+		Scope:              strings.Join(g.scopes, " "),
+		StandardClaims:     generateStandardClaims(jwtExpirationTimeInMins, strconv.FormatInt(sess.UserID, 10), g.audience),
+	}
+}
+
+// checkType returns an error unless the claims are of an access token
+func (c *AccessTokenClaims) checkType() error {
+	if c.TokenType == accessTokenType {
+		return nil
 	}
+	return errTokenType
 }
 
-func generateRefreshTokenClaims(identity string, provider string) RefreshTokenClaims {
+// HasRole reports whether the claims include the given role
+func (c *AccessTokenClaims) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission reports whether the claims include the permission on the
+// resource, such as write on stock
+func (c *AccessTokenClaims) HasPermission(permission, resource string) bool {
+	if len(resource) > 0 {
+		permission = permission + ":" + resource
+	}
+	return contains(c.Permissions, permission)
+}
+
+// HasScope reports whether the claims include the given scope
+func (c *AccessTokenClaims) HasScope(scope string) bool {
+	return contains(strings.Fields(c.Scope), scope)
+}
+
+func generateRefreshTokenClaims(identity, provider, sessionID string, g grant) RefreshTokenClaims {
 	return RefreshTokenClaims{
-		identity,
-		provider,
-		generateStandardClaims(refreshTokenExpirationTimeInMins),
+		TokenType:      refreshTokenType,
+		Identity:       identity,
+		Provider:       provider,
+		SessionID:      sessionID,
+		ClientID:       g.clientID,
+		Scope:          strings.Join(g.scopes, " "),
+		StandardClaims: generateStandardClaims(refreshTokenExpirationTimeInMins, refreshTokenSubject, g.audience),
+	}
+}
+
+// checkType returns an error unless the claims are of a refresh token,
+// the tokens issued before the type claim are told apart by their identity
+func (c *RefreshTokenClaims) checkType() error {
+	if c.TokenType == refreshTokenType || (len(c.TokenType) == 0 && len(c.Identity) > 0) {
+		return nil
+	}
+	return errTokenType
+}
+
+// grant returns the audience and the scopes that the claims carry for the
+// access tokens
+func (c *RefreshTokenClaims) grant() grant {
+	return grant{
+		clientID: c.ClientID,
+		audience: c.Audience,
+		scopes:   strings.Fields(c.Scope),
 	}
 }
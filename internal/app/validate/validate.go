@@ -8,19 +8,164 @@ import (
 
 // ServerArgs validates that the necessary flags are not missing
 func ServerArgs(c *cli.Context) error {
-	for _, p := range []string{
+	args := []string{
 		"user-grpc-host",
 		"user-grpc-port",
 		"identity-grpc-host",
 		"identity-grpc-port",
-		"nats-host",
-		"nats-port",
-		"redis-master-service-host",
-		"redis-master-service-port",
 		"config",
 		"pkey",
 		"prkey",
-	} {
+		"admin-role",
+		"admin-audience",
+	}
+	rargs, err := repositoryArgs(c)
+	if err != nil {
+		return err
+	}
+	args = append(args, rargs...)
+	switch c.String("messaging") {
+	case "nats", "jetstream":
+		args = append(args, "nats-host", "nats-port")
+	case "kafka":
+		args = append(args, "kafka-brokers")
+	case "none":
+	default:
+		return cli.NewExitError(
+			fmt.Sprintf("unsupported messaging %s", c.String("messaging")),
+			2,
+		)
+	}
+	switch c.String("event-format") {
+	case "protobuf", "json":
+	default:
+		return cli.NewExitError(
+			fmt.Sprintf("unsupported event format %s", c.String("event-format")),
+			2,
+		)
+	}
+	switch c.String("trace-exporter") {
+	case "otlp":
+		args = append(args, "otlp-endpoint")
+	case "stdout", "none":
+	default:
+		return cli.NewExitError(
+			fmt.Sprintf("unsupported trace exporter %s", c.String("trace-exporter")),
+			2,
+		)
+	}
+	if r := c.Float64("trace-sample-ratio"); r < 0 || r > 1 {
+		return cli.NewExitError(
+			fmt.Sprintf("trace sample ratio %v is not between 0 and 1", r),
+			2,
+		)
+	}
+	switch c.String("audit-sink") {
+	case "file":
+		args = append(args, "audit-file")
+	case "syslog":
+		if len(c.String("audit-syslog-network")) > 0 {
+			args = append(args, "audit-syslog-address")
+		}
+	case "stdout", "none":
+	default:
+		return cli.NewExitError(
+			fmt.Sprintf("unsupported audit sink %s", c.String("audit-sink")),
+			2,
+		)
+	}
+	switch c.String("cookie-same-site") {
+	case "strict", "lax", "none":
+	default:
+		return cli.NewExitError(
+			fmt.Sprintf("unsupported same site attribute %s", c.String("cookie-same-site")),
+			2,
+		)
+	}
+	if c.Int("max-claims-size") < 0 {
+		return cli.NewExitError("max-claims-size is negative", 2)
+	}
+	if c.Duration("permission-cache-ttl") < 0 {
+		return cli.NewExitError("permission-cache-ttl is negative", 2)
+	}
+	if err := tlsArgs(c); err != nil {
+		return err
+	}
+	if c.Bool("rate-limit") {
+		if c.Duration("lockout-max") < c.Duration("lockout-base") {
+			return cli.NewExitError("lockout-max is shorter than lockout-base", 2)
+		}
+		if c.String("repository") != "redis" {
+			args = append(args,
+				"redis-master-service-host",
+				"redis-master-service-port",
+			)
+		}
+	}
+	return requiredArgs(c, args)
+}
+
+// ClientArgs validates the flags of the commands that manage the clients
+func ClientArgs(c *cli.Context) error {
+	args, err := repositoryArgs(c)
+	if err != nil {
+		return err
+	}
+	if c.Command.Name != "list" {
+		args = append(args, "id")
+	}
+	return requiredArgs(c, args)
+}
+
+// repositoryArgs returns the flags that are required by the repository
+func repositoryArgs(c *cli.Context) ([]string, error) {
+	switch c.String("repository") {
+	case "redis":
+		return []string{
+			"redis-master-service-host",
+			"redis-master-service-port",
+		}, nil
+	case "postgres":
+		if c.Duration("postgres-sweep-interval") <= 0 {
+			return nil, cli.NewExitError("postgres-sweep-interval is not positive", 2)
+		}
+		return []string{
+			"postgres-host",
+			"postgres-port",
+			"postgres-user",
+			"postgres-database",
+		}, nil
+	default:
+		return nil, cli.NewExitError(
+			fmt.Sprintf("unsupported repository %s", c.String("repository")),
+			2,
+		)
+	}
+}
+
+// tlsArgs validates that certificates are given along with their keys
+func tlsArgs(c *cli.Context) error {
+	pairs := [][2]string{
+		{"tls-cert", "tls-key"},
+		{"user-grpc-cert", "user-grpc-key"},
+		{"identity-grpc-cert", "identity-grpc-key"},
+	}
+	for _, p := range pairs {
+		if (len(c.String(p[0])) == 0) != (len(c.String(p[1])) == 0) {
+			return cli.NewExitError(
+				fmt.Sprintf("arguments %s and %s are required together", p[0], p[1]),
+				2,
+			)
+		}
+	}
+	if len(c.String("tls-client-ca")) > 0 && len(c.String("tls-cert")) == 0 {
+		return cli.NewExitError("argument tls-client-ca requires tls-cert", 2)
+	}
+	return nil
+}
+
+func requiredArgs(c *cli.Context, args []string) error {
+	for _, p := range args {
 		if len(c.String(p)) == 0 {
 			return cli.NewExitError(
 				fmt.Sprintf("argument %s is missing", p),
@@ -0,0 +1,157 @@
+// Package audit writes one structured record for every authentication
+// decision of the server to a dedicated sink. Records never contain any
+// token, identities are only written as their digest.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/syslog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dictyBase/modware-auth/internal/repository"
+	"github.com/go-playground/validator/v10"
+)
+
+// OutcomeSuccess is the outcome of an allowed request
+const OutcomeSuccess = "success"
+
+// Record is the audit record of a request
+type Record struct {
+	Time           time.Time `json:"time"`
+	Method         string    `json:"method"`
+	Outcome        string    `json:"outcome"`
+	Code           string    `json:"code"`
+	DurationMs     int64     `json:"duration_ms"`
+	UserID         int64     `json:"user_id,omitempty"`
+	SessionID      string    `json:"session_id,omitempty"`
+	IdentityDigest string    `json:"identity_digest,omitempty"`
+	Provider       string    `json:"provider,omitempty"`
+	ClientIP       string    `json:"client_ip,omitempty"`
+	UserAgent      string    `json:"user_agent,omitempty"`
+	// failure is the class of the failure as set by the service
+	failure string
+	mu      sync.Mutex
+}
+
+type recordKey struct{}
+
+// NewContext returns the context carrying the record of the request
+func NewContext(ctx context.Context, r *Record) context.Context {
+	return context.WithValue(ctx, recordKey{}, r)
+}
+
+// FromContext returns the record of the request, it is nil if the request
+// is not audited. All methods of the record are safe to call on nil.
+func FromContext(ctx context.Context) *Record {
+	r, _ := ctx.Value(recordKey{}).(*Record)
+	return r
+}
+
+// SetIdentity sets the identity of the request, only its digest is kept
+func (r *Record) SetIdentity(identity, provider string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if identity != "" {
+		r.IdentityDigest = repository.Digest(identity)
+	}
+	if provider != "" {
+		r.Provider = provider
+	}
+}
+
+// SetSession sets the user and the session of the request
+func (r *Record) SetSession(userID int64, sessionID string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.UserID, r.SessionID = userID, sessionID
+}
+
+// SetFailure sets the class of the failure of the request, it is used as
+// outcome of the record
+func (r *Record) SetFailure(failure string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failure = failure
+}
+
+// LoggerParams are the attributes that are required for creating a new
+// Logger
+type LoggerParams struct {
+	// Sink is either of stdout, file or syslog
+	Sink string `validate:"required,oneof=stdout file syslog"`
+	// File is the path of the file sink, records are appended
+	File string `validate:"required_if=Sink file"`
+	// SyslogNetwork and SyslogAddress locate the syslog server, the local
+	// server is used if both are empty
+	SyslogNetwork string
+	SyslogAddress string
+}
+
+// Logger writes the records as JSON, one record per line
+type Logger struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewLogger is the constructor for creating a new instance of Logger
+func NewLogger(p *LoggerParams) (*Logger, error) {
+	if err := validator.New().Struct(p); err != nil {
+		return &Logger{}, err
+	}
+	switch p.Sink {
+	case "file":
+		f, err := os.OpenFile(p.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return &Logger{}, fmt.Errorf("error in opening audit log %s", err)
+		}
+		return &Logger{w: f, closer: f}, nil
+	case "syslog":
+		w, err := syslog.Dial(
+			p.SyslogNetwork, p.SyslogAddress,
+			syslog.LOG_INFO|syslog.LOG_AUTH, "modware-auth",
+		)
+		if err != nil {
+			return &Logger{}, fmt.Errorf("error in connecting to syslog %s", err)
+		}
+		return &Logger{w: w, closer: w}, nil
+	default:
+		return &Logger{w: os.Stdout}, nil
+	}
+}
+
+// Log writes the record
+func (l *Logger) Log(r *Record) error {
+	r.mu.Lock()
+	b, err := json.Marshal(r)
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.w.Write(append(b, '\n'))
+	return err
+}
+
+// Close closes the sink
+func (l *Logger) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dictyBase/modware-auth/internal/repository"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTestInterceptor(t *testing.T, buf *bytes.Buffer) grpc.UnaryServerInterceptor {
+	t.Helper()
+	interceptor, err := UnaryServerInterceptor(&InterceptorParams{
+		Logger:  &Logger{w: buf},
+		Methods: []string{"/dictybase.auth.AuthService/Relogin", "/dictybase.auth.AuthService/Login"},
+		ClientInfo: func(context.Context) (string, string) {
+			return "10.0.0.1", "curl/8.0"
+		},
+		ErrorLogger: logrus.NewEntry(logrus.New()),
+	})
+	if err != nil {
+		t.Fatalf("error in creating interceptor %s", err)
+	}
+	return interceptor
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	interceptor := newTestInterceptor(t, &buf)
+	_, err := interceptor(
+		context.Background(),
+		"refresh-token-value",
+		&grpc.UnaryServerInfo{FullMethod: "/dictybase.auth.AuthService/Relogin"},
+		func(ctx context.Context, _ interface{}) (interface{}, error) {
+			r := FromContext(ctx)
+			r.SetIdentity("jo@dicty.org", "google")
+			r.SetSession(7, "session")
+			r.SetFailure("session_not_found")
+			return nil, status.Error(codes.NotFound, "refresh token jo@dicty.org not found")
+		},
+	)
+	assert.Equal(codes.NotFound, status.Code(err), "should return error of handler")
+	line := buf.String()
+	assert.NotContains(line, "jo@dicty.org", "should not log the identity")
+	assert.NotContains(line, "refresh-token-value", "should not log the request")
+	r := &Record{}
+	assert.NoError(json.Unmarshal([]byte(line), r), "error in decoding record")
+	assert.Equal("Relogin", r.Method, "should log the method")
+	assert.Equal("session_not_found", r.Outcome, "should log the failure as outcome")
+	assert.Equal("NotFound", r.Code, "should log the status code")
+	assert.Equal(repository.Digest("jo@dicty.org"), r.IdentityDigest, "should log digest of identity")
+	assert.Equal("google", r.Provider, "should log the provider")
+	assert.Equal(int64(7), r.UserID, "should log the user")
+	assert.Equal("10.0.0.1", r.ClientIP, "should log the client address")
+	assert.Equal("curl/8.0", r.UserAgent, "should log the user agent")
+	buf.Reset()
+	_, err = interceptor(
+		context.Background(), nil,
+		&grpc.UnaryServerInfo{FullMethod: "/dictybase.auth.AuthService/Login"},
+		func(context.Context, interface{}) (interface{}, error) {
+			return nil, status.Error(codes.ResourceExhausted, "too many requests")
+		},
+	)
+	assert.Error(err, "should return error of handler")
+	assert.Contains(buf.String(), `"outcome":"rate_limited"`, "should derive outcome from code")
+	buf.Reset()
+	_, err = interceptor(
+		context.Background(), nil,
+		&grpc.UnaryServerInfo{FullMethod: "/dictybase.auth.AuthService/GetJwks"},
+		func(ctx context.Context, _ interface{}) (interface{}, error) {
+			assert.Nil(FromContext(ctx), "should not audit other methods")
+			return nil, errors.New("not audited")
+		},
+	)
+	assert.Error(err, "should return error of handler")
+	assert.Empty(buf.String(), "should not log other methods")
+}
+
+func TestFileLogger(t *testing.T) {
+	assert := assert.New(t)
+	name := filepath.Join(t.TempDir(), "audit.log")
+	l, err := NewLogger(&LoggerParams{Sink: "file", File: name})
+	assert.NoError(err, "error in creating logger")
+	for _, m := range []string{"Login", "Logout"} {
+		assert.NoError(l.Log(&Record{Method: m, Outcome: OutcomeSuccess}), "error in writing record")
+	}
+	assert.NoError(l.Close(), "error in closing logger")
+	b, err := os.ReadFile(name)
+	assert.NoError(err, "error in reading audit log")
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	assert.Len(lines, 2, "should write one line per record")
+	_, err = NewLogger(&LoggerParams{Sink: "file"})
+	assert.Error(err, "should require file of file sink")
+}
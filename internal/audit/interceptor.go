@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"context"
+	"path"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// InterceptorParams are the attributes that are required for creating
+// the audit interceptor
+type InterceptorParams struct {
+	Logger *Logger `validate:"required"`
+	// Methods are the full names of the audited methods
+	Methods []string `validate:"required,min=1"`
+	// ClientInfo returns the address and the user agent of the client
+	ClientInfo func(ctx context.Context) (string, string) `validate:"required"`
+	// ErrorLogger logs the records that cannot be written
+	ErrorLogger *logrus.Entry `validate:"required"`
+}
+
+// UnaryServerInterceptor returns the interceptor that writes the record
+// of every request to the audited methods. The record is passed to the
+// handler in the context, so the service is able to complete it.
+func UnaryServerInterceptor(p *InterceptorParams) (grpc.UnaryServerInterceptor, error) {
+	if err := validator.New().Struct(p); err != nil {
+		return nil, err
+	}
+	methods := make(map[string]bool, len(p.Methods))
+	for _, m := range p.Methods {
+		methods[m] = true
+	}
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if !methods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		start := time.Now()
+		r := &Record{Method: path.Base(info.FullMethod)}
+		r.ClientIP, r.UserAgent = p.ClientInfo(ctx)
+		resp, err := handler(NewContext(ctx, r), req)
+		r.mu.Lock()
+		r.Time = start.UTC()
+		r.DurationMs = time.Since(start).Milliseconds()
+		code := status.Code(err)
+		r.Code = code.String()
+		r.Outcome = outcome(code, r.failure)
+		r.mu.Unlock()
+		if lerr := p.Logger.Log(r); lerr != nil {
+			p.ErrorLogger.WithError(lerr).Error("error in writing audit record")
+		}
+		return resp, err
+	}, nil
+}
+
+// outcome is the failure class set by the service, otherwise it is
+// derived from the status code
+func outcome(code codes.Code, failure string) string {
+	switch {
+	case failure != "":
+		return failure
+	case code == codes.OK:
+		return OutcomeSuccess
+	case code == codes.ResourceExhausted:
+		return "rate_limited"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,548 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.33.0
+// 	protoc        (unknown)
+// source: authapi/event.proto
+
+package authapi
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type AuthEvent_Type int32
+
+const (
+	AuthEvent_TYPE_UNSPECIFIED AuthEvent_Type = 0
+	AuthEvent_LOGIN_SUCCEEDED  AuthEvent_Type = 1
+	AuthEvent_LOGIN_FAILED     AuthEvent_Type = 2
+	AuthEvent_TOKEN_REFRESHED  AuthEvent_Type = 3
+	AuthEvent_LOGOUT           AuthEvent_Type = 4
+	AuthEvent_SESSIONS_REVOKED AuthEvent_Type = 5
+	AuthEvent_ACCOUNT_LOCKED   AuthEvent_Type = 6
+	AuthEvent_ACCOUNT_UNLOCKED AuthEvent_Type = 7
+	AuthEvent_REFRESH_FAILED   AuthEvent_Type = 8
+)
+
+// Enum value maps for AuthEvent_Type.
+var (
+	AuthEvent_Type_name = map[int32]string{
+		0: "TYPE_UNSPECIFIED",
+		1: "LOGIN_SUCCEEDED",
+		2: "LOGIN_FAILED",
+		3: "TOKEN_REFRESHED",
+		4: "LOGOUT",
+		5: "SESSIONS_REVOKED",
+		6: "ACCOUNT_LOCKED",
+		7: "ACCOUNT_UNLOCKED",
+		8: "REFRESH_FAILED",
+	}
+	AuthEvent_Type_value = map[string]int32{
+		"TYPE_UNSPECIFIED": 0,
+		"LOGIN_SUCCEEDED":  1,
+		"LOGIN_FAILED":     2,
+		"TOKEN_REFRESHED":  3,
+		"LOGOUT":           4,
+		"SESSIONS_REVOKED": 5,
+		"ACCOUNT_LOCKED":   6,
+		"ACCOUNT_UNLOCKED": 7,
+		"REFRESH_FAILED":   8,
+	}
+)
+
+func (x AuthEvent_Type) Enum() *AuthEvent_Type {
+	p := new(AuthEvent_Type)
+	*p = x
+	return p
+}
+
+func (x AuthEvent_Type) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AuthEvent_Type) Descriptor() protoreflect.EnumDescriptor {
+	return file_authapi_event_proto_enumTypes[0].Descriptor()
+}
+
+func (AuthEvent_Type) Type() protoreflect.EnumType {
+	return &file_authapi_event_proto_enumTypes[0]
+}
+
+func (x AuthEvent_Type) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AuthEvent_Type.Descriptor instead.
+func (AuthEvent_Type) EnumDescriptor() ([]byte, []int) {
+	return file_authapi_event_proto_rawDescGZIP(), []int{0, 0}
+}
+
+// class of a failed login or refresh
+type AuthEvent_Failure int32
+
+const (
+	AuthEvent_FAILURE_UNSPECIFIED AuthEvent_Failure = 0
+	// malformed request
+	AuthEvent_FAILURE_INVALID_REQUEST AuthEvent_Failure = 1
+	// provider is not supported
+	AuthEvent_FAILURE_UNSUPPORTED_PROVIDER AuthEvent_Failure = 2
+	// code exchange or user retrieval with the provider failed
+	AuthEvent_FAILURE_PROVIDER_LOGIN AuthEvent_Failure = 3
+	// identity is not known to the identity service
+	AuthEvent_FAILURE_IDENTITY_NOT_FOUND AuthEvent_Failure = 4
+	// user of the identity is not known to the user service
+	AuthEvent_FAILURE_USER_NOT_FOUND AuthEvent_Failure = 5
+	// account is locked by an administrator
+	AuthEvent_FAILURE_ACCOUNT_LOCKED AuthEvent_Failure = 6
+	// refresh token is malformed, expired or not signed by this server
+	AuthEvent_FAILURE_INVALID_TOKEN AuthEvent_Failure = 7
+	// refresh token or its session is revoked or replaced
+	AuthEvent_FAILURE_SESSION_NOT_FOUND AuthEvent_Failure = 8
+	// refreshes of the identity are locked after repeated failures
+	AuthEvent_FAILURE_LOCKED_OUT AuthEvent_Failure = 9
+	// requested audience or scope is not allowed for the client
+	AuthEvent_FAILURE_SCOPE_NOT_ALLOWED AuthEvent_Failure = 10
+	// client of a requested audience or scope failed to authenticate
+	AuthEvent_FAILURE_INVALID_CLIENT AuthEvent_Failure = 11
+)
+
+// Enum value maps for AuthEvent_Failure.
+var (
+	AuthEvent_Failure_name = map[int32]string{
+		0:  "FAILURE_UNSPECIFIED",
+		1:  "FAILURE_INVALID_REQUEST",
+		2:  "FAILURE_UNSUPPORTED_PROVIDER",
+		3:  "FAILURE_PROVIDER_LOGIN",
+		4:  "FAILURE_IDENTITY_NOT_FOUND",
+		5:  "FAILURE_USER_NOT_FOUND",
+		6:  "FAILURE_ACCOUNT_LOCKED",
+		7:  "FAILURE_INVALID_TOKEN",
+		8:  "FAILURE_SESSION_NOT_FOUND",
+		9:  "FAILURE_LOCKED_OUT",
+		10: "FAILURE_SCOPE_NOT_ALLOWED",
+		11: "FAILURE_INVALID_CLIENT",
+	}
+	AuthEvent_Failure_value = map[string]int32{
+		"FAILURE_UNSPECIFIED":          0,
+		"FAILURE_INVALID_REQUEST":      1,
+		"FAILURE_UNSUPPORTED_PROVIDER": 2,
+		"FAILURE_PROVIDER_LOGIN":       3,
+		"FAILURE_IDENTITY_NOT_FOUND":   4,
+		"FAILURE_USER_NOT_FOUND":       5,
+		"FAILURE_ACCOUNT_LOCKED":       6,
+		"FAILURE_INVALID_TOKEN":        7,
+		"FAILURE_SESSION_NOT_FOUND":    8,
+		"FAILURE_LOCKED_OUT":           9,
+		"FAILURE_SCOPE_NOT_ALLOWED":    10,
+		"FAILURE_INVALID_CLIENT":       11,
+	}
+)
+
+func (x AuthEvent_Failure) Enum() *AuthEvent_Failure {
+	p := new(AuthEvent_Failure)
+	*p = x
+	return p
+}
+
+func (x AuthEvent_Failure) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AuthEvent_Failure) Descriptor() protoreflect.EnumDescriptor {
+	return file_authapi_event_proto_enumTypes[1].Descriptor()
+}
+
+func (AuthEvent_Failure) Type() protoreflect.EnumType {
+	return &file_authapi_event_proto_enumTypes[1]
+}
+
+func (x AuthEvent_Failure) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AuthEvent_Failure.Descriptor instead.
+func (AuthEvent_Failure) EnumDescriptor() ([]byte, []int) {
+	return file_authapi_event_proto_rawDescGZIP(), []int{0, 1}
+}
+
+// AuthEvent is published for every authentication decision. It carries
+// only the metadata of the decision and never any token.
+type AuthEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// unique identifier of the event
+	Id     string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type   AuthEvent_Type         `protobuf:"varint,2,opt,name=type,proto3,enum=authapi.AuthEvent_Type" json:"type,omitempty"`
+	Time   *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=time,proto3" json:"time,omitempty"`
+	UserId int64                  `protobuf:"varint,4,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// login provider
+	Provider  string `protobuf:"bytes,5,opt,name=provider,proto3" json:"provider,omitempty"`
+	SessionId string `protobuf:"bytes,6,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// jti of the issued access token
+	Jti string `protobuf:"bytes,7,opt,name=jti,proto3" json:"jti,omitempty"`
+	// jti of the issued refresh token
+	RefreshJti string `protobuf:"bytes,8,opt,name=refresh_jti,json=refreshJti,proto3" json:"refresh_jti,omitempty"`
+	// expiration of the issued refresh token
+	ExpiresAt *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	// reason of a failure or of an administrative action
+	Reason string `protobuf:"bytes,10,opt,name=reason,proto3" json:"reason,omitempty"`
+	// user who performed the action, differs from user_id for actions of
+	// an administrator
+	ActorId int64 `protobuf:"varint,11,opt,name=actor_id,json=actorId,proto3" json:"actor_id,omitempty"`
+	// number of revoked sessions
+	RevokedSessions int64 `protobuf:"varint,12,opt,name=revoked_sessions,json=revokedSessions,proto3" json:"revoked_sessions,omitempty"`
+	// end of an account lock, absent for locks without an end
+	LockedUntil *timestamppb.Timestamp `protobuf:"bytes,13,opt,name=locked_until,json=lockedUntil,proto3" json:"locked_until,omitempty"`
+	// address of the client
+	ClientIp string `protobuf:"bytes,14,opt,name=client_ip,json=clientIp,proto3" json:"client_ip,omitempty"`
+	// SHA-256 digest of the identity used for login or refresh
+	IdentityDigest string            `protobuf:"bytes,15,opt,name=identity_digest,json=identityDigest,proto3" json:"identity_digest,omitempty"`
+	Failure        AuthEvent_Failure `protobuf:"varint,16,opt,name=failure,proto3,enum=authapi.AuthEvent_Failure" json:"failure,omitempty"`
+	// application that requested the tokens
+	ClientId string `protobuf:"bytes,17,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	// audience of the issued access token
+	Audience string `protobuf:"bytes,18,opt,name=audience,proto3" json:"audience,omitempty"`
+	// scopes granted to the issued access token
+	Scopes []string `protobuf:"bytes,19,rep,name=scopes,proto3" json:"scopes,omitempty"`
+}
+
+func (x *AuthEvent) Reset() {
+	*x = AuthEvent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_authapi_event_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AuthEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuthEvent) ProtoMessage() {}
+
+func (x *AuthEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_authapi_event_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuthEvent.ProtoReflect.Descriptor instead.
+func (*AuthEvent) Descriptor() ([]byte, []int) {
+	return file_authapi_event_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *AuthEvent) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *AuthEvent) GetType() AuthEvent_Type {
+	if x != nil {
+		return x.Type
+	}
+	return AuthEvent_TYPE_UNSPECIFIED
+}
+
+func (x *AuthEvent) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *AuthEvent) GetUserId() int64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *AuthEvent) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *AuthEvent) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *AuthEvent) GetJti() string {
+	if x != nil {
+		return x.Jti
+	}
+	return ""
+}
+
+func (x *AuthEvent) GetRefreshJti() string {
+	if x != nil {
+		return x.RefreshJti
+	}
+	return ""
+}
+
+func (x *AuthEvent) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+func (x *AuthEvent) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *AuthEvent) GetActorId() int64 {
+	if x != nil {
+		return x.ActorId
+	}
+	return 0
+}
+
+func (x *AuthEvent) GetRevokedSessions() int64 {
+	if x != nil {
+		return x.RevokedSessions
+	}
+	return 0
+}
+
+func (x *AuthEvent) GetLockedUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LockedUntil
+	}
+	return nil
+}
+
+func (x *AuthEvent) GetClientIp() string {
+	if x != nil {
+		return x.ClientIp
+	}
+	return ""
+}
+
+func (x *AuthEvent) GetIdentityDigest() string {
+	if x != nil {
+		return x.IdentityDigest
+	}
+	return ""
+}
+
+func (x *AuthEvent) GetFailure() AuthEvent_Failure {
+	if x != nil {
+		return x.Failure
+	}
+	return AuthEvent_FAILURE_UNSPECIFIED
+}
+
+func (x *AuthEvent) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *AuthEvent) GetAudience() string {
+	if x != nil {
+		return x.Audience
+	}
+	return ""
+}
+
+func (x *AuthEvent) GetScopes() []string {
+	if x != nil {
+		return x.Scopes
+	}
+	return nil
+}
+
+var File_authapi_event_proto protoreflect.FileDescriptor
+
+var file_authapi_event_proto_rawDesc = []byte{
+	0x0a, 0x13, 0x61, 0x75, 0x74, 0x68, 0x61, 0x70, 0x69, 0x2f, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x07, 0x61, 0x75, 0x74, 0x68, 0x61, 0x70, 0x69, 0x1a, 0x1f,
+	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f,
+	0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22,
+	0xc4, 0x09, 0x0a, 0x09, 0x41, 0x75, 0x74, 0x68, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x0e, 0x0a,
+	0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x2b, 0x0a,
+	0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x17, 0x2e, 0x61, 0x75,
+	0x74, 0x68, 0x61, 0x70, 0x69, 0x2e, 0x41, 0x75, 0x74, 0x68, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x2e,
+	0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x2e, 0x0a, 0x04, 0x74, 0x69,
+	0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c,
+	0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73,
+	0x74, 0x61, 0x6d, 0x70, 0x52, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x12, 0x17, 0x0a, 0x07, 0x75, 0x73,
+	0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x75, 0x73, 0x65,
+	0x72, 0x49, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x76, 0x69, 0x64, 0x65, 0x72, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x76, 0x69, 0x64, 0x65, 0x72, 0x12,
+	0x1d, 0x0a, 0x0a, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x06, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x09, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x49, 0x64, 0x12, 0x10,
+	0x0a, 0x03, 0x6a, 0x74, 0x69, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6a, 0x74, 0x69,
+	0x12, 0x1f, 0x0a, 0x0b, 0x72, 0x65, 0x66, 0x72, 0x65, 0x73, 0x68, 0x5f, 0x6a, 0x74, 0x69, 0x18,
+	0x08, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x72, 0x65, 0x66, 0x72, 0x65, 0x73, 0x68, 0x4a, 0x74,
+	0x69, 0x12, 0x39, 0x0a, 0x0a, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x5f, 0x61, 0x74, 0x18,
+	0x09, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d,
+	0x70, 0x52, 0x09, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x41, 0x74, 0x12, 0x16, 0x0a, 0x06,
+	0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65,
+	0x61, 0x73, 0x6f, 0x6e, 0x12, 0x19, 0x0a, 0x08, 0x61, 0x63, 0x74, 0x6f, 0x72, 0x5f, 0x69, 0x64,
+	0x18, 0x0b, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x61, 0x63, 0x74, 0x6f, 0x72, 0x49, 0x64, 0x12,
+	0x29, 0x0a, 0x10, 0x72, 0x65, 0x76, 0x6f, 0x6b, 0x65, 0x64, 0x5f, 0x73, 0x65, 0x73, 0x73, 0x69,
+	0x6f, 0x6e, 0x73, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0f, 0x72, 0x65, 0x76, 0x6f, 0x6b,
+	0x65, 0x64, 0x53, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x3d, 0x0a, 0x0c, 0x6c, 0x6f,
+	0x63, 0x6b, 0x65, 0x64, 0x5f, 0x75, 0x6e, 0x74, 0x69, 0x6c, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62,
+	0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0b, 0x6c, 0x6f,
+	0x63, 0x6b, 0x65, 0x64, 0x55, 0x6e, 0x74, 0x69, 0x6c, 0x12, 0x1b, 0x0a, 0x09, 0x63, 0x6c, 0x69,
+	0x65, 0x6e, 0x74, 0x5f, 0x69, 0x70, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x63, 0x6c,
+	0x69, 0x65, 0x6e, 0x74, 0x49, 0x70, 0x12, 0x27, 0x0a, 0x0f, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69,
+	0x74, 0x79, 0x5f, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0e, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x44, 0x69, 0x67, 0x65, 0x73, 0x74, 0x12,
+	0x34, 0x0a, 0x07, 0x66, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x18, 0x10, 0x20, 0x01, 0x28, 0x0e,
+	0x32, 0x1a, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x61, 0x70, 0x69, 0x2e, 0x41, 0x75, 0x74, 0x68, 0x45,
+	0x76, 0x65, 0x6e, 0x74, 0x2e, 0x46, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x52, 0x07, 0x66, 0x61,
+	0x69, 0x6c, 0x75, 0x72, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f,
+	0x69, 0x64, 0x18, 0x11, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74,
+	0x49, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x61, 0x75, 0x64, 0x69, 0x65, 0x6e, 0x63, 0x65, 0x18, 0x12,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x61, 0x75, 0x64, 0x69, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x16,
+	0x0a, 0x06, 0x73, 0x63, 0x6f, 0x70, 0x65, 0x73, 0x18, 0x13, 0x20, 0x03, 0x28, 0x09, 0x52, 0x06,
+	0x73, 0x63, 0x6f, 0x70, 0x65, 0x73, 0x22, 0xb8, 0x01, 0x0a, 0x04, 0x54, 0x79, 0x70, 0x65, 0x12,
+	0x14, 0x0a, 0x10, 0x54, 0x59, 0x50, 0x45, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46,
+	0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x13, 0x0a, 0x0f, 0x4c, 0x4f, 0x47, 0x49, 0x4e, 0x5f, 0x53,
+	0x55, 0x43, 0x43, 0x45, 0x45, 0x44, 0x45, 0x44, 0x10, 0x01, 0x12, 0x10, 0x0a, 0x0c, 0x4c, 0x4f,
+	0x47, 0x49, 0x4e, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x02, 0x12, 0x13, 0x0a, 0x0f,
+	0x54, 0x4f, 0x4b, 0x45, 0x4e, 0x5f, 0x52, 0x45, 0x46, 0x52, 0x45, 0x53, 0x48, 0x45, 0x44, 0x10,
+	0x03, 0x12, 0x0a, 0x0a, 0x06, 0x4c, 0x4f, 0x47, 0x4f, 0x55, 0x54, 0x10, 0x04, 0x12, 0x14, 0x0a,
+	0x10, 0x53, 0x45, 0x53, 0x53, 0x49, 0x4f, 0x4e, 0x53, 0x5f, 0x52, 0x45, 0x56, 0x4f, 0x4b, 0x45,
+	0x44, 0x10, 0x05, 0x12, 0x12, 0x0a, 0x0e, 0x41, 0x43, 0x43, 0x4f, 0x55, 0x4e, 0x54, 0x5f, 0x4c,
+	0x4f, 0x43, 0x4b, 0x45, 0x44, 0x10, 0x06, 0x12, 0x14, 0x0a, 0x10, 0x41, 0x43, 0x43, 0x4f, 0x55,
+	0x4e, 0x54, 0x5f, 0x55, 0x4e, 0x4c, 0x4f, 0x43, 0x4b, 0x45, 0x44, 0x10, 0x07, 0x12, 0x12, 0x0a,
+	0x0e, 0x52, 0x45, 0x46, 0x52, 0x45, 0x53, 0x48, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10,
+	0x08, 0x22, 0xe2, 0x02, 0x0a, 0x07, 0x46, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x12, 0x17, 0x0a,
+	0x13, 0x46, 0x41, 0x49, 0x4c, 0x55, 0x52, 0x45, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49,
+	0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x1b, 0x0a, 0x17, 0x46, 0x41, 0x49, 0x4c, 0x55, 0x52,
+	0x45, 0x5f, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x5f, 0x52, 0x45, 0x51, 0x55, 0x45, 0x53,
+	0x54, 0x10, 0x01, 0x12, 0x20, 0x0a, 0x1c, 0x46, 0x41, 0x49, 0x4c, 0x55, 0x52, 0x45, 0x5f, 0x55,
+	0x4e, 0x53, 0x55, 0x50, 0x50, 0x4f, 0x52, 0x54, 0x45, 0x44, 0x5f, 0x50, 0x52, 0x4f, 0x56, 0x49,
+	0x44, 0x45, 0x52, 0x10, 0x02, 0x12, 0x1a, 0x0a, 0x16, 0x46, 0x41, 0x49, 0x4c, 0x55, 0x52, 0x45,
+	0x5f, 0x50, 0x52, 0x4f, 0x56, 0x49, 0x44, 0x45, 0x52, 0x5f, 0x4c, 0x4f, 0x47, 0x49, 0x4e, 0x10,
+	0x03, 0x12, 0x1e, 0x0a, 0x1a, 0x46, 0x41, 0x49, 0x4c, 0x55, 0x52, 0x45, 0x5f, 0x49, 0x44, 0x45,
+	0x4e, 0x54, 0x49, 0x54, 0x59, 0x5f, 0x4e, 0x4f, 0x54, 0x5f, 0x46, 0x4f, 0x55, 0x4e, 0x44, 0x10,
+	0x04, 0x12, 0x1a, 0x0a, 0x16, 0x46, 0x41, 0x49, 0x4c, 0x55, 0x52, 0x45, 0x5f, 0x55, 0x53, 0x45,
+	0x52, 0x5f, 0x4e, 0x4f, 0x54, 0x5f, 0x46, 0x4f, 0x55, 0x4e, 0x44, 0x10, 0x05, 0x12, 0x1a, 0x0a,
+	0x16, 0x46, 0x41, 0x49, 0x4c, 0x55, 0x52, 0x45, 0x5f, 0x41, 0x43, 0x43, 0x4f, 0x55, 0x4e, 0x54,
+	0x5f, 0x4c, 0x4f, 0x43, 0x4b, 0x45, 0x44, 0x10, 0x06, 0x12, 0x19, 0x0a, 0x15, 0x46, 0x41, 0x49,
+	0x4c, 0x55, 0x52, 0x45, 0x5f, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x5f, 0x54, 0x4f, 0x4b,
+	0x45, 0x4e, 0x10, 0x07, 0x12, 0x1d, 0x0a, 0x19, 0x46, 0x41, 0x49, 0x4c, 0x55, 0x52, 0x45, 0x5f,
+	0x53, 0x45, 0x53, 0x53, 0x49, 0x4f, 0x4e, 0x5f, 0x4e, 0x4f, 0x54, 0x5f, 0x46, 0x4f, 0x55, 0x4e,
+	0x44, 0x10, 0x08, 0x12, 0x16, 0x0a, 0x12, 0x46, 0x41, 0x49, 0x4c, 0x55, 0x52, 0x45, 0x5f, 0x4c,
+	0x4f, 0x43, 0x4b, 0x45, 0x44, 0x5f, 0x4f, 0x55, 0x54, 0x10, 0x09, 0x12, 0x1d, 0x0a, 0x19, 0x46,
+	0x41, 0x49, 0x4c, 0x55, 0x52, 0x45, 0x5f, 0x53, 0x43, 0x4f, 0x50, 0x45, 0x5f, 0x4e, 0x4f, 0x54,
+	0x5f, 0x41, 0x4c, 0x4c, 0x4f, 0x57, 0x45, 0x44, 0x10, 0x0a, 0x12, 0x1a, 0x0a, 0x16, 0x46, 0x41,
+	0x49, 0x4c, 0x55, 0x52, 0x45, 0x5f, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x5f, 0x43, 0x4c,
+	0x49, 0x45, 0x4e, 0x54, 0x10, 0x0b, 0x42, 0x34, 0x5a, 0x32, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62,
+	0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x64, 0x69, 0x63, 0x74, 0x79, 0x42, 0x61, 0x73, 0x65, 0x2f, 0x6d,
+	0x6f, 0x64, 0x77, 0x61, 0x72, 0x65, 0x2d, 0x61, 0x75, 0x74, 0x68, 0x2f, 0x69, 0x6e, 0x74, 0x65,
+	0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x61, 0x75, 0x74, 0x68, 0x61, 0x70, 0x69, 0x62, 0x06, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_authapi_event_proto_rawDescOnce sync.Once
+	file_authapi_event_proto_rawDescData = file_authapi_event_proto_rawDesc
+)
+
+func file_authapi_event_proto_rawDescGZIP() []byte {
+	file_authapi_event_proto_rawDescOnce.Do(func() {
+		file_authapi_event_proto_rawDescData = protoimpl.X.CompressGZIP(file_authapi_event_proto_rawDescData)
+	})
+	return file_authapi_event_proto_rawDescData
+}
+
+var file_authapi_event_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_authapi_event_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_authapi_event_proto_goTypes = []interface{}{
+	(AuthEvent_Type)(0),           // 0: authapi.AuthEvent.Type
+	(AuthEvent_Failure)(0),        // 1: authapi.AuthEvent.Failure
+	(*AuthEvent)(nil),             // 2: authapi.AuthEvent
+	(*timestamppb.Timestamp)(nil), // 3: google.protobuf.Timestamp
+}
+var file_authapi_event_proto_depIdxs = []int32{
+	0, // 0: authapi.AuthEvent.type:type_name -> authapi.AuthEvent.Type
+	3, // 1: authapi.AuthEvent.time:type_name -> google.protobuf.Timestamp
+	3, // 2: authapi.AuthEvent.expires_at:type_name -> google.protobuf.Timestamp
+	3, // 3: authapi.AuthEvent.locked_until:type_name -> google.protobuf.Timestamp
+	1, // 4: authapi.AuthEvent.failure:type_name -> authapi.AuthEvent.Failure
+	5, // [5:5] is the sub-list for method output_type
+	5, // [5:5] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_authapi_event_proto_init() }
+func file_authapi_event_proto_init() {
+	if File_authapi_event_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_authapi_event_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AuthEvent); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_authapi_event_proto_rawDesc,
+			NumEnums:      2,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_authapi_event_proto_goTypes,
+		DependencyIndexes: file_authapi_event_proto_depIdxs,
+		EnumInfos:         file_authapi_event_proto_enumTypes,
+		MessageInfos:      file_authapi_event_proto_msgTypes,
+	}.Build()
+	File_authapi_event_proto = out.File
+	file_authapi_event_proto_rawDesc = nil
+	file_authapi_event_proto_goTypes = nil
+	file_authapi_event_proto_depIdxs = nil
+}
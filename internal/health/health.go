@@ -0,0 +1,297 @@
+// Package health tracks the reachability of the dependencies of the server.
+// The checks run in a background loop and their results are exposed
+// through the standard grpc.health.v1 service and over HTTP.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Check reports whether a dependency is reachable
+type Check func(ctx context.Context) error
+
+// Pinger is implemented by the dependencies that can report their
+// reachability
+type Pinger interface {
+	Ping() error
+}
+
+// PingCheck returns the check of a Pinger
+func PingCheck(p Pinger) Check {
+	return func(context.Context) error {
+		return p.Ping()
+	}
+}
+
+// ConnCheck returns the check of a gRPC client connection. An idle
+// connection is asked to connect and is not considered a failure.
+func ConnCheck(conn *grpc.ClientConn) Check {
+	return func(context.Context) error {
+		switch s := conn.GetState(); s {
+		case connectivity.Ready, connectivity.Connecting:
+			return nil
+		case connectivity.Idle:
+			conn.Connect()
+			return nil
+		default:
+			return fmt.Errorf("connection to %s is %s", conn.Target(), s)
+		}
+	}
+}
+
+// Monitor runs the checks periodically and keeps the health service
+// up to date
+type Monitor struct {
+	server   *grpchealth.Server
+	checks   map[string]Check
+	optional map[string]bool
+	services []string
+	interval time.Duration
+	timeout  time.Duration
+	logger   *logrus.Entry
+	mu       sync.RWMutex
+	results  map[string]error
+	shutdown bool
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+	started  bool
+}
+
+// MonitorParams are the attributes that are required for creating a new Monitor
+type MonitorParams struct {
+	// Checks are the checks of the dependencies by their name
+	Checks map[string]Check `validate:"required"`
+	// Optional are the checks of the dependencies the server works without,
+	// they are reported but do not affect the services or the readiness
+	Optional map[string]Check
+	// Services are the gRPC services that depend on all the checks
+	Services []string      `validate:"required"`
+	Interval time.Duration `validate:"required"`
+	// Timeout of a single check
+	Timeout time.Duration `validate:"required"`
+	Logger  *logrus.Entry `validate:"required"`
+}
+
+// NewMonitor is the constructor for creating a new instance of Monitor. All
+// services are NOT_SERVING until the checks ran for the first time.
+func NewMonitor(p *MonitorParams) (*Monitor, error) {
+	if err := validator.New().Struct(p); err != nil {
+		return &Monitor{}, err
+	}
+	m := &Monitor{
+		server:   grpchealth.NewServer(),
+		checks:   make(map[string]Check, len(p.Checks)+len(p.Optional)),
+		optional: make(map[string]bool, len(p.Optional)),
+		services: p.Services,
+		interval: p.Interval,
+		timeout:  p.Timeout,
+		logger:   p.Logger,
+		results:  make(map[string]error),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for name, check := range p.Checks {
+		m.checks[name] = check
+	}
+	for name, check := range p.Optional {
+		m.checks[name] = check
+		m.optional[name] = true
+	}
+	for _, name := range append(m.names(), m.serviceNames()...) {
+		m.server.SetServingStatus(name, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	return m, nil
+}
+
+// Register registers the health service with the gRPC server
+func (m *Monitor) Register(s *grpc.Server) {
+	healthpb.RegisterHealthServer(s, m.server)
+}
+
+// Start runs the checks right away and then in the background until the
+// monitor is stopped
+func (m *Monitor) Start() {
+	m.started = true
+	m.run()
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				m.run()
+			}
+		}
+	}()
+}
+
+// Stop stops the background checks, the services keep their last status
+func (m *Monitor) Stop() {
+	m.once.Do(func() {
+		close(m.stop)
+		if m.started {
+			<-m.done
+		}
+	})
+}
+
+// Shutdown stops the background checks and sets all services to
+// NOT_SERVING for good, so that no new requests are routed to the server
+func (m *Monitor) Shutdown() {
+	m.Stop()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.shutdown = true
+	m.server.Shutdown()
+}
+
+// run runs all checks concurrently and updates the health service
+func (m *Monitor) run() {
+	results := make(map[string]error, len(m.checks))
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for name, check := range m.checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+			defer cancel()
+			err := check(ctx)
+			mu.Lock()
+			results[name] = err
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	healthy := true
+	for name, err := range results {
+		if err != nil {
+			if !m.optional[name] {
+				healthy = false
+			}
+			if m.results[name] == nil {
+				m.logger.WithError(err).WithField("dependency", name).
+					Warn("dependency is unreachable")
+			}
+		}
+		m.server.SetServingStatus(name, servingStatus(err == nil))
+	}
+	m.results = results
+	for _, svc := range m.serviceNames() {
+		m.server.SetServingStatus(svc, servingStatus(healthy))
+	}
+}
+
+// Handler returns the HTTP handler for the /healthz liveness and the
+// /readyz readiness endpoints. The optional checks are only reported by
+// /healthz.
+func (m *Monitor) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", m.healthz)
+	mux.HandleFunc("/readyz", m.readyz)
+	return mux
+}
+
+func (m *Monitor) healthz(w http.ResponseWriter, _ *http.Request) {
+	if len(m.optional) == 0 {
+		writeStatus(w, http.StatusOK, map[string]string{"status": "ok"})
+		return
+	}
+	m.mu.RLock()
+	checks := make(map[string]string, len(m.optional))
+	for name := range m.optional {
+		checks[name], _ = m.result(name)
+	}
+	m.mu.RUnlock()
+	writeStatus(w, http.StatusOK, map[string]interface{}{
+		"status": "ok",
+		"checks": checks,
+	})
+}
+
+func (m *Monitor) readyz(w http.ResponseWriter, _ *http.Request) {
+	m.mu.RLock()
+	checks := make(map[string]string, len(m.checks))
+	code, status := http.StatusOK, "ok"
+	if m.shutdown {
+		code, status = http.StatusServiceUnavailable, "shutting down"
+	}
+	for _, name := range m.names() {
+		if m.optional[name] {
+			continue
+		}
+		var ok bool
+		if checks[name], ok = m.result(name); !ok {
+			code, status = http.StatusServiceUnavailable, "unavailable"
+		}
+	}
+	m.mu.RUnlock()
+	writeStatus(w, code, map[string]interface{}{
+		"status": status,
+		"checks": checks,
+	})
+}
+
+// result returns the last result of the check and whether it succeeded,
+// the caller has to hold the lock
+func (m *Monitor) result(name string) (string, bool) {
+	err, ok := m.results[name]
+	switch {
+	case !ok:
+		return "unknown", false
+	case err != nil:
+		return err.Error(), false
+	default:
+		return "ok", true
+	}
+}
+
+// names returns the names of the checks in a stable order
+func (m *Monitor) names() []string {
+	names := make([]string, 0, len(m.checks))
+	for name := range m.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// serviceNames returns the services including the empty name of the
+// overall health of the server
+func (m *Monitor) serviceNames() []string {
+	return append([]string{""}, m.services...)
+}
+
+func servingStatus(ok bool) healthpb.HealthCheckResponse_ServingStatus {
+	if ok {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
+
+func writeStatus(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body) //nolint:errcheck
+}
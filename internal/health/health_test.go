@@ -0,0 +1,169 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type fakePinger struct {
+	down int32
+}
+
+func (f *fakePinger) Ping() error {
+	if atomic.LoadInt32(&f.down) == 1 {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func newTestMonitor(t *testing.T, checks, optional map[string]Check) *Monitor {
+	t.Helper()
+	log := logrus.New()
+	log.Out = io.Discard
+	m, err := NewMonitor(&MonitorParams{
+		Checks:   checks,
+		Optional: optional,
+		Services: []string{"dictybase.auth.AuthService"},
+		Interval: time.Hour,
+		Timeout:  time.Second,
+		Logger:   logrus.NewEntry(log),
+	})
+	if err != nil {
+		t.Fatalf("error in creating monitor %s", err)
+	}
+	return m
+}
+
+func servingStatusOf(t *testing.T, m *Monitor, svc string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	res, err := m.server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: svc})
+	if err != nil {
+		t.Fatalf("error in checking health of %q %s", svc, err)
+	}
+	return res.Status
+}
+
+func readyz(t *testing.T, m *Monitor) (int, map[string]interface{}) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	body := make(map[string]interface{})
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("error in decoding body %s", err)
+	}
+	return rec.Code, body
+}
+
+func TestMonitor(t *testing.T) {
+	assert := assert.New(t)
+	redis, users := &fakePinger{}, &fakePinger{}
+	m := newTestMonitor(t, map[string]Check{
+		"repository": PingCheck(redis),
+		"user-api":   PingCheck(users),
+	}, nil)
+	notServing := healthpb.HealthCheckResponse_NOT_SERVING
+	assert.Equal(notServing, servingStatusOf(t, m, ""), "should not serve before checks")
+	code, _ := readyz(t, m)
+	assert.Equal(http.StatusServiceUnavailable, code, "should not be ready before checks")
+	m.run()
+	for _, svc := range []string{"", "dictybase.auth.AuthService", "repository", "user-api"} {
+		assert.Equal(healthpb.HealthCheckResponse_SERVING, servingStatusOf(t, m, svc), "should serve %q", svc)
+	}
+	code, body := readyz(t, m)
+	assert.Equal(http.StatusOK, code, "should be ready")
+	assert.Equal("ok", body["status"], "should report ok")
+	atomic.StoreInt32(&users.down, 1)
+	m.run()
+	assert.Equal(notServing, servingStatusOf(t, m, ""), "should not serve with failing dependency")
+	assert.Equal(notServing, servingStatusOf(t, m, "dictybase.auth.AuthService"), "should not serve services")
+	assert.Equal(notServing, servingStatusOf(t, m, "user-api"), "should report failing dependency")
+	assert.Equal(
+		healthpb.HealthCheckResponse_SERVING,
+		servingStatusOf(t, m, "repository"),
+		"should report healthy dependency",
+	)
+	code, body = readyz(t, m)
+	assert.Equal(http.StatusServiceUnavailable, code, "should not be ready")
+	checks := body["checks"].(map[string]interface{})
+	assert.Equal("connection refused", checks["user-api"], "should report the failure")
+	assert.Equal("ok", checks["repository"], "should report healthy dependency")
+}
+
+func TestHealthz(t *testing.T) {
+	m := newTestMonitor(t, map[string]Check{
+		"repository": func(context.Context) error { return errors.New("down") },
+	}, nil)
+	m.Start()
+	defer m.Stop()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code, "should be alive with failing dependencies")
+}
+
+func TestOptionalCheck(t *testing.T) {
+	assert := assert.New(t)
+	nats := &fakePinger{down: 1}
+	m := newTestMonitor(t, map[string]Check{
+		"repository": PingCheck(&fakePinger{}),
+	}, map[string]Check{
+		"messaging": PingCheck(nats),
+	})
+	m.run()
+	for _, svc := range []string{"", "dictybase.auth.AuthService"} {
+		assert.Equal(
+			healthpb.HealthCheckResponse_SERVING,
+			servingStatusOf(t, m, svc),
+			"should serve %q with failing optional dependency", svc,
+		)
+	}
+	assert.Equal(
+		healthpb.HealthCheckResponse_NOT_SERVING,
+		servingStatusOf(t, m, "messaging"),
+		"should report failing optional dependency",
+	)
+	code, body := readyz(t, m)
+	assert.Equal(http.StatusOK, code, "should be ready with failing optional dependency")
+	assert.NotContains(body["checks"], "messaging", "should leave optional dependency out of readiness")
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(http.StatusOK, rec.Code, "should be alive with failing optional dependency")
+	body = make(map[string]interface{})
+	assert.NoError(json.NewDecoder(rec.Body).Decode(&body), "error in decoding body")
+	assert.Equal(
+		map[string]interface{}{"messaging": "connection refused"},
+		body["checks"],
+		"should report optional dependency",
+	)
+}
+
+func TestShutdown(t *testing.T) {
+	assert := assert.New(t)
+	m := newTestMonitor(t, map[string]Check{
+		"repository": PingCheck(&fakePinger{}),
+	}, nil)
+	m.Start()
+	assert.Equal(healthpb.HealthCheckResponse_SERVING, servingStatusOf(t, m, ""), "should serve")
+	m.Shutdown()
+	m.run()
+	for _, svc := range []string{"", "dictybase.auth.AuthService"} {
+		assert.Equal(
+			healthpb.HealthCheckResponse_NOT_SERVING,
+			servingStatusOf(t, m, svc),
+			"should stop serving %q for good", svc,
+		)
+	}
+	code, body := readyz(t, m)
+	assert.Equal(http.StatusServiceUnavailable, code, "should not be ready")
+	assert.Equal("shutting down", body["status"], "should report shutdown")
+}
@@ -14,6 +14,7 @@ var (
 	ErrNoTokenFound     = errors.New("jwtauth: no token found")
 	ErrAlgoInvalid      = errors.New("jwtauth: algorithm mismatch")
 	ErrInvalidSignature = errors.New("jwtauth: invalid signature")
+	ErrInvalidAudience  = errors.New("jwtauth: audience mismatch")
 )
 
 func isValidationNotValidYet(err *jwt.ValidationError) bool {
@@ -31,3 +32,28 @@ func isValidationExpired(err *jwt.ValidationError) bool {
 func isInvalidSignature(err *jwt.ValidationError) bool {
 	return err.Errors == jwt.ValidationErrorSignatureInvalid
 }
+
+// Reason returns a short name for the verification error, it is meant for
+// labelling metrics and logs
+func Reason(err error) string {
+	switch {
+	case errors.Is(err, ErrExpired):
+		return "expired"
+	case errors.Is(err, ErrInvalidSignature):
+		return "invalid_signature"
+	case errors.Is(err, ErrNBFInvalid):
+		return "nbf_invalid"
+	case errors.Is(err, ErrIATInvalid):
+		return "iat_invalid"
+	case errors.Is(err, ErrNoTokenFound):
+		return "no_token"
+	case errors.Is(err, ErrAlgoInvalid):
+		return "algorithm_mismatch"
+	case errors.Is(err, ErrInvalidAudience):
+		return "invalid_audience"
+	case errors.Is(err, ErrUnauthorized):
+		return "unauthorized"
+	default:
+		return "malformed"
+	}
+}
@@ -27,6 +27,37 @@ func NewJwtAuth(alg jwt.SigningMethod, signKey *rsa.PrivateKey, verifyKey *rsa.P
 // Verify a JWT string and returns a token object
 func (ja *JWTAuth) Verify(tokenString string) (*jwt.Token, error) {
 	token, err := ja.decode(tokenString)
+	return ja.validate(token, err)
+}
+
+// VerifyClaims verifies a JWT string and decodes its claims into the
+// given claims value
+func (ja *JWTAuth) VerifyClaims(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	token, err := ja.parser.ParseWithClaims(tokenString, claims, ja.keyFunc)
+	return ja.validate(token, err)
+}
+
+// AudienceClaims are the claims that carry an audience, such as the
+// claims that embed jwt.StandardClaims
+type AudienceClaims interface {
+	jwt.Claims
+	VerifyAudience(cmp string, req bool) bool
+}
+
+// VerifyAudience verifies a JWT string like VerifyClaims and checks that
+// the token was issued for the given audience
+func (ja *JWTAuth) VerifyAudience(tokenString string, claims AudienceClaims, audience string) (*jwt.Token, error) {
+	token, err := ja.VerifyClaims(tokenString, claims)
+	if err != nil {
+		return token, err
+	}
+	if !claims.VerifyAudience(audience, true) {
+		return token, ErrInvalidAudience
+	}
+	return token, nil
+}
+
+func (ja *JWTAuth) validate(token *jwt.Token, err error) (*jwt.Token, error) {
 	if err != nil {
 		verr, ok := err.(*jwt.ValidationError)
 		switch ok {
@@ -156,3 +156,83 @@ func TestVerifySignature(t *testing.T) {
 	_, err = ja2.Verify(val)
 	assert.IsType(ErrInvalidSignature, err, "expect to be unauthorized error")
 }
+
+type testClaims struct {
+	UserID int64
+	jwt.StandardClaims
+}
+
+func TestVerifyClaims_Decode(t *testing.T) {
+	assert := assert.New(t)
+	private, public, err := generateKeys()
+	if err != nil {
+		t.Error(err)
+	}
+	ja := NewJwtAuth(jwt.SigningMethodRS512, private, public)
+	claims := testClaims{
+		UserID: 42,
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    "dictyBase",
+			ExpiresAt: time.Now().Add(time.Hour * 240).Unix(),
+			IssuedAt:  time.Now().Unix(),
+			Id:        xid.New().String(),
+		},
+	}
+	val, err := ja.Encode(claims)
+	assert.NoError(err, "expect no error for jwt encoding")
+	decoded := &testClaims{}
+	_, err = ja.VerifyClaims(val, decoded)
+	assert.NoError(err, "expect no error when verifying valid jwt")
+	assert.Equal(int64(42), decoded.UserID, "expect to decode custom claims")
+	claims.ExpiresAt = time.Now().Unix() - 1000000
+	val, err = ja.Encode(claims)
+	assert.NoError(err, "expect no error for jwt encoding")
+	_, err = ja.VerifyClaims(val, &testClaims{})
+	assert.IsType(ErrExpired, err, "expect a jwt expired error")
+}
+
+func TestVerifyAudience(t *testing.T) {
+	assert := assert.New(t)
+	private, public, err := generateKeys()
+	if err != nil {
+		t.Error(err)
+	}
+	ja := NewJwtAuth(jwt.SigningMethodRS512, private, public)
+	val, err := ja.Encode(testClaims{
+		UserID: 42,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+			Audience:  "admin",
+		},
+	})
+	assert.NoError(err, "expect no error for jwt encoding")
+	decoded := &testClaims{}
+	_, err = ja.VerifyAudience(val, decoded, "admin")
+	assert.NoError(err, "expect no error for expected audience")
+	assert.Equal(int64(42), decoded.UserID, "expect to decode custom claims")
+	_, err = ja.VerifyAudience(val, &testClaims{}, "user")
+	assert.ErrorIs(err, ErrInvalidAudience, "expect error for other audience")
+	assert.Equal("invalid_audience", Reason(err), "should name audience mismatch")
+	val, err = ja.Encode(testClaims{UserID: 42})
+	assert.NoError(err, "expect no error for jwt encoding")
+	_, err = ja.VerifyAudience(val, &testClaims{}, "admin")
+	assert.ErrorIs(err, ErrInvalidAudience, "expect error for missing audience")
+}
+
+func TestReason(t *testing.T) {
+	assert := assert.New(t)
+	private, public, err := generateKeys()
+	if err != nil {
+		t.Error(err)
+	}
+	ja := NewJwtAuth(jwt.SigningMethodRS512, private, public)
+	tkn, err := ja.Encode(jwt.StandardClaims{
+		ExpiresAt: time.Now().Add(-time.Minute).Unix(),
+	})
+	assert.NoError(err, "error in encoding token")
+	_, err = ja.Verify(tkn)
+	assert.Equal("expired", Reason(err), "should name expired token")
+	_, err = ja.Verify("garbage")
+	assert.Equal("malformed", Reason(err), "should name malformed token")
+	assert.Equal("no_token", Reason(fmt.Errorf("wrapped %w", ErrNoTokenFound)), "should name wrapped error")
+}
@@ -0,0 +1,133 @@
+// Package cloudevent encodes the auth events as CloudEvents in the binary
+// content mode of the NATS and Kafka protocol bindings. The attributes of
+// the event are given as prefixed headers and the payload is the event
+// either in its protobuf or in its JSON encoding.
+package cloudevent
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dictyBase/modware-auth/internal/authapi"
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// SpecVersion is the version of the CloudEvents specification
+	SpecVersion = "1.0"
+	// TypePrefix is prepended to the lowercased type of the auth event
+	TypePrefix = "org.dictybase.auth."
+	// ContentTypeProtobuf is the content type of protobuf payloads
+	ContentTypeProtobuf = "application/protobuf"
+	// ContentTypeJSON is the content type of JSON payloads
+	ContentTypeJSON = "application/json"
+)
+
+// names of the CloudEvent attributes
+const (
+	AttrSpecVersion = "specversion"
+	AttrID          = "id"
+	AttrSource      = "source"
+	AttrType        = "type"
+	AttrTime        = "time"
+)
+
+const (
+	// NATSPrefix is prepended to the attributes in the NATS headers
+	NATSPrefix = "ce-"
+	// KafkaPrefix is prepended to the attributes in the Kafka headers
+	KafkaPrefix = "ce_"
+	// HeaderContentType is the header of the content type in both bindings
+	HeaderContentType = "content-type"
+)
+
+// headers of the CloudEvent attributes in NATS messages
+const (
+	HeaderSpecVersion = NATSPrefix + AttrSpecVersion
+	HeaderID          = NATSPrefix + AttrID
+	HeaderSource      = NATSPrefix + AttrSource
+	HeaderType        = NATSPrefix + AttrType
+	HeaderTime        = NATSPrefix + AttrTime
+)
+
+// Encoder converts the auth events to NATS messages
+type Encoder struct {
+	source      string
+	contentType string
+}
+
+// NewEncoder returns an Encoder for the given source and payload format,
+// the format is either of protobuf or json
+func NewEncoder(source, format string) (*Encoder, error) {
+	switch format {
+	case "protobuf":
+		return &Encoder{source: source, contentType: ContentTypeProtobuf}, nil
+	case "json":
+		return &Encoder{source: source, contentType: ContentTypeJSON}, nil
+	default:
+		return &Encoder{}, fmt.Errorf("unsupported event format %s", format)
+	}
+}
+
+// Message returns the event as a CloudEvent message for the subject
+func (en *Encoder) Message(subject string, e *authapi.AuthEvent) (*nats.Msg, error) {
+	msg := nats.NewMsg(subject)
+	data, err := en.Payload(e)
+	if err != nil {
+		return msg, err
+	}
+	msg.Data = data
+	for k, v := range en.Attributes(e) {
+		msg.Header.Set(NATSPrefix+k, v)
+	}
+	msg.Header.Set(HeaderContentType, en.contentType)
+	return msg, nil
+}
+
+// Attributes returns the CloudEvent attributes of the event by their name
+func (en *Encoder) Attributes(e *authapi.AuthEvent) map[string]string {
+	return map[string]string{
+		AttrSpecVersion: SpecVersion,
+		AttrID:          e.Id,
+		AttrSource:      en.source,
+		AttrType:        Type(e.Type),
+		AttrTime:        e.Time.AsTime().Format(time.RFC3339Nano),
+	}
+}
+
+// ContentType returns the content type of the payloads
+func (en *Encoder) ContentType() string {
+	return en.contentType
+}
+
+// Payload returns the encoded event
+func (en *Encoder) Payload(e *authapi.AuthEvent) ([]byte, error) {
+	if en.contentType == ContentTypeJSON {
+		return protojson.Marshal(e)
+	}
+	return proto.Marshal(e)
+}
+
+// Type returns the CloudEvent type of the auth event type
+func Type(t authapi.AuthEvent_Type) string {
+	return TypePrefix + strings.ToLower(t.String())
+}
+
+// Decode returns the auth event of a CloudEvent message
+func Decode(msg *nats.Msg) (*authapi.AuthEvent, error) {
+	e := &authapi.AuthEvent{}
+	if v := msg.Header.Get(HeaderSpecVersion); v != SpecVersion {
+		return e, fmt.Errorf("unsupported cloudevents spec version %q", v)
+	}
+	switch ct := msg.Header.Get(HeaderContentType); ct {
+	case ContentTypeProtobuf:
+		return e, proto.Unmarshal(msg.Data, e)
+	case ContentTypeJSON:
+		return e, protojson.Unmarshal(msg.Data, e)
+	default:
+		return e, fmt.Errorf("unsupported content type %q", ct)
+	}
+}
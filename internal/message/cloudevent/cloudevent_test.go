@@ -0,0 +1,48 @@
+package cloudevent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dictyBase/modware-auth/internal/authapi"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func testEvent() *authapi.AuthEvent {
+	return &authapi.AuthEvent{
+		Id:        "c5l1ndr6n88kt0gq4ng0",
+		Type:      authapi.AuthEvent_LOGIN_SUCCEEDED,
+		Time:      timestamppb.New(time.Date(2021, 7, 28, 10, 0, 0, 0, time.UTC)),
+		UserId:    7,
+		Provider:  "google",
+		SessionId: "c5l1ndr6n88kt0gq4nfg",
+	}
+}
+
+func TestMessage(t *testing.T) {
+	assert := assert.New(t)
+	for format, ct := range map[string]string{
+		"protobuf": ContentTypeProtobuf,
+		"json":     ContentTypeJSON,
+	} {
+		en, err := NewEncoder("modware-auth", format)
+		assert.NoError(err, "error in creating encoder")
+		e := testEvent()
+		msg, err := en.Message("AuthService.Create", e)
+		assert.NoError(err, "error in encoding event")
+		assert.Equal("AuthService.Create", msg.Subject, "should match subject")
+		assert.Equal(SpecVersion, msg.Header.Get(HeaderSpecVersion), "should match spec version")
+		assert.Equal(e.Id, msg.Header.Get(HeaderID), "should use event id")
+		assert.Equal("modware-auth", msg.Header.Get(HeaderSource), "should match source")
+		assert.Equal("org.dictybase.auth.login_succeeded", msg.Header.Get(HeaderType), "should match type")
+		assert.Equal("2021-07-28T10:00:00Z", msg.Header.Get(HeaderTime), "should match time")
+		assert.Equal(ct, msg.Header.Get(HeaderContentType), "should match content type")
+		de, err := Decode(msg)
+		assert.NoError(err, "error in decoding event")
+		assert.True(proto.Equal(e, de), "should decode the event")
+	}
+	_, err := NewEncoder("modware-auth", "xml")
+	assert.Error(err, "should reject unknown format")
+}
@@ -0,0 +1,253 @@
+// Package jetstream publishes the auth events as CloudEvents to a NATS
+// JetStream stream.
+// Every event is acknowledged by the server and deduplicated by its id.
+// Events that cannot be delivered at once are kept in a bounded local
+// buffer and retried in the background.
+package jetstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dictyBase/modware-auth/internal/authapi"
+	"github.com/dictyBase/modware-auth/internal/message"
+	"github.com/dictyBase/modware-auth/internal/message/cloudevent"
+	"github.com/dictyBase/modware-auth/internal/tracing"
+	"github.com/nats-io/nats.go"
+)
+
+// ErrBufferFull is returned when an undelivered event does not fit in
+// the local buffer
+var ErrBufferFull = errors.New("buffer of undelivered events is full")
+
+// Options configures the stream and the delivery of the events
+type Options struct {
+	// Stream is the name of the stream, it is created if missing
+	Stream string
+	// Subjects are bound to a newly created stream
+	Subjects []string
+	// Duplicates is the window for deduplicating the events by their id
+	Duplicates time.Duration
+	// RetryWait is the pause between two attempts of a buffered event
+	RetryWait time.Duration
+	// AckWait is the time to wait for the acknowledgement of an event
+	AckWait time.Duration
+	// BufferSize is the maximum number of buffered events
+	BufferSize int
+	// DrainTimeout limits the delivery of the buffered events at closing,
+	// the events that are left are lost
+	DrainTimeout time.Duration
+}
+
+// DefaultOptions returns the options for the stream
+func DefaultOptions(stream string, subjects []string) *Options {
+	return &Options{
+		Stream:       stream,
+		Subjects:     subjects,
+		Duplicates:   2 * time.Minute,
+		RetryWait:    200 * time.Millisecond,
+		AckWait:      time.Second,
+		BufferSize:   1000,
+		DrainTimeout: 10 * time.Second,
+	}
+}
+
+// streamPublisher is the part of nats.JetStreamContext that is used for
+// publishing
+type streamPublisher interface {
+	PublishMsg(m *nats.Msg, opts ...nats.PubOpt) (*nats.PubAck, error)
+}
+
+type jsPublisher struct {
+	conn    *nats.Conn
+	js      streamPublisher
+	encoder *cloudevent.Encoder
+	opts    *Options
+	buffer  chan *nats.Msg
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	// number of events that were lost at closing
+	lost int
+}
+
+// NewPublisher connects to the NATS server, creates the stream if it
+// does not exist and returns a Publisher for it
+func NewPublisher(
+	host, port string,
+	enc *cloudevent.Encoder,
+	opts *Options,
+	options ...nats.Option,
+) (message.Publisher, error) {
+	nc, err := nats.Connect(fmt.Sprintf("nats://%s:%s", host, port), options...)
+	if err != nil {
+		return &jsPublisher{}, err
+	}
+	js, err := nc.JetStream()
+	if err != nil {
+		nc.Close()
+		return &jsPublisher{}, err
+	}
+	if err := ensureStream(js, opts); err != nil {
+		nc.Close()
+		return &jsPublisher{}, err
+	}
+	p := newPublisher(js, enc, opts)
+	p.conn = nc
+	return p, nil
+}
+
+func ensureStream(js nats.JetStreamContext, opts *Options) error {
+	_, err := js.StreamInfo(opts.Stream)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, nats.ErrStreamNotFound) {
+		return fmt.Errorf("error in looking up stream %s %s", opts.Stream, err)
+	}
+	_, err = js.AddStream(&nats.StreamConfig{
+		Name:       opts.Stream,
+		Subjects:   opts.Subjects,
+		Duplicates: opts.Duplicates,
+	})
+	if err != nil {
+		return fmt.Errorf("error in creating stream %s %s", opts.Stream, err)
+	}
+	return nil
+}
+
+func newPublisher(js streamPublisher, enc *cloudevent.Encoder, opts *Options) *jsPublisher {
+	p := &jsPublisher{
+		js:      js,
+		encoder: enc,
+		opts:    opts,
+		buffer:  make(chan *nats.Msg, opts.BufferSize),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go p.redeliver()
+	return p
+}
+
+// Publish publishes the event and waits for its acknowledgement. The
+// event is buffered if the attempt fails or if earlier events are still
+// waiting for delivery.
+func (p *jsPublisher) Publish(subj string, e *authapi.AuthEvent) error {
+	return p.PublishContext(context.Background(), subj, e)
+}
+
+// PublishContext publishes the event with the trace context of the
+// request in the headers, the span ends once the event is either
+// acknowledged or buffered
+func (p *jsPublisher) PublishContext(ctx context.Context, subj string, e *authapi.AuthEvent) error {
+	msg, err := p.encoder.Message(subj, e)
+	if err != nil {
+		return err
+	}
+	// the server drops the redelivery of an acknowledged event
+	msg.Header.Set(nats.MsgIdHdr, e.Id)
+	span := tracing.StartPublish(ctx, msg)
+	if len(p.buffer) == 0 && p.deliver(msg, p.opts.AckWait) == nil {
+		tracing.End(span, nil)
+		return nil
+	}
+	select {
+	case p.buffer <- msg:
+		span.AddEvent("buffered")
+		tracing.End(span, nil)
+		return nil
+	default:
+		tracing.End(span, ErrBufferFull)
+		return ErrBufferFull
+	}
+}
+
+// deliver makes a single attempt to publish the message, waiting at most
+// for the given time for its acknowledgement
+func (p *jsPublisher) deliver(msg *nats.Msg, wait time.Duration) error {
+	_, err := p.js.PublishMsg(msg, nats.AckWait(wait))
+	return err
+}
+
+// redeliver delivers the buffered events in order, retrying each until it
+// is delivered or the publisher is closed
+func (p *jsPublisher) redeliver() {
+	defer close(p.done)
+	for {
+		select {
+		case <-p.stop:
+			p.drain(nil)
+			return
+		case msg := <-p.buffer:
+			for p.deliver(msg, p.opts.AckWait) != nil {
+				select {
+				case <-p.stop:
+					p.drain(msg)
+					return
+				case <-time.After(p.opts.RetryWait):
+				}
+			}
+		}
+	}
+}
+
+// drain makes a last attempt for the pending and the buffered events
+// until the drain timeout passes, the events that are left then are lost
+func (p *jsPublisher) drain(pending *nats.Msg) {
+	deadline := time.Now().Add(p.opts.DrainTimeout)
+	last := func(msg *nats.Msg) {
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			p.lost++
+			return
+		}
+		if wait > p.opts.AckWait {
+			wait = p.opts.AckWait
+		}
+		if p.deliver(msg, wait) != nil {
+			p.lost++
+		}
+	}
+	if pending != nil {
+		last(pending)
+	}
+	for {
+		select {
+		case msg := <-p.buffer:
+			last(msg)
+		default:
+			return
+		}
+	}
+}
+
+// Ping reports whether the connection to the server is established and
+// the buffer is not full
+func (p *jsPublisher) Ping() error {
+	if s := p.conn.Status(); s != nats.CONNECTED {
+		return fmt.Errorf("nats connection is %s", s)
+	}
+	if len(p.buffer) == cap(p.buffer) {
+		return ErrBufferFull
+	}
+	return nil
+}
+
+// Close delivers the buffered events and closes the connection. It
+// returns an error if any of the buffered events could not be delivered.
+func (p *jsPublisher) Close() error {
+	p.once.Do(func() {
+		close(p.stop)
+		<-p.done
+		if p.conn != nil {
+			p.conn.Close()
+		}
+	})
+	if p.lost > 0 {
+		return fmt.Errorf("%d buffered events were not delivered", p.lost)
+	}
+	return nil
+}
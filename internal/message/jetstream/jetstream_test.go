@@ -0,0 +1,172 @@
+package jetstream
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dictyBase/modware-auth/internal/authapi"
+	"github.com/dictyBase/modware-auth/internal/message/cloudevent"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// fakeStream fails while it is down, after waiting for the
+// acknowledgement if a wait is given, and records the delivered messages
+type fakeStream struct {
+	mu        sync.Mutex
+	down      bool
+	wait      time.Duration
+	attempts  int
+	delivered []*nats.Msg
+}
+
+func (f *fakeStream) PublishMsg(m *nats.Msg, _ ...nats.PubOpt) (*nats.PubAck, error) {
+	f.mu.Lock()
+	f.attempts++
+	down, wait := f.down, f.wait
+	f.mu.Unlock()
+	if down {
+		time.Sleep(wait)
+		return nil, errors.New("nats: no responders available for request")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.delivered = append(f.delivered, m)
+	return &nats.PubAck{Stream: "AUTH_EVENTS"}, nil
+}
+
+func (f *fakeStream) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+func (f *fakeStream) attempted() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.attempts
+}
+
+func (f *fakeStream) messages() []*nats.Msg {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*nats.Msg{}, f.delivered...)
+}
+
+func testPublisher(t *testing.T, fs *fakeStream) *jsPublisher {
+	t.Helper()
+	enc, err := cloudevent.NewEncoder("modware-auth", "protobuf")
+	if err != nil {
+		t.Fatalf("error in creating encoder %s", err)
+	}
+	return newPublisher(fs, enc, testOptions())
+}
+
+func testOptions() *Options {
+	opts := DefaultOptions("AUTH_EVENTS", []string{"AuthService.>"})
+	opts.RetryWait = time.Millisecond
+	opts.BufferSize = 2
+	return opts
+}
+
+func TestPublish(t *testing.T) {
+	assert := assert.New(t)
+	fs := &fakeStream{}
+	p := testPublisher(t, fs)
+	e := &authapi.AuthEvent{
+		Id:   "c5l1ndr6n88kt0gq4ng0",
+		Type: authapi.AuthEvent_LOGIN_SUCCEEDED,
+		Time: timestamppb.Now(),
+	}
+	assert.NoError(p.Publish("AuthService.Create", e), "error in publishing event")
+	assert.NoError(p.Close(), "error in closing publisher")
+	msgs := fs.messages()
+	assert.Len(msgs, 1, "should deliver the event")
+	assert.Equal("AuthService.Create", msgs[0].Subject, "should match subject")
+	assert.Equal(e.Id, msgs[0].Header.Get(nats.MsgIdHdr), "should use event id as message id")
+	de, err := cloudevent.Decode(msgs[0])
+	assert.NoError(err, "error in decoding event")
+	assert.True(proto.Equal(e, de), "should match event")
+}
+
+func TestPublishBuffer(t *testing.T) {
+	assert := assert.New(t)
+	fs := &fakeStream{down: true}
+	p := testPublisher(t, fs)
+	for _, id := range []string{"a", "b"} {
+		assert.NoError(
+			p.Publish("AuthService.Create", &authapi.AuthEvent{Id: id}),
+			"should buffer undelivered event",
+		)
+	}
+	assert.GreaterOrEqual(fs.attempted(), 1, "should attempt before buffering")
+	// the redelivery might have taken one of the events out of the buffer
+	var err error
+	for i := 0; i < 2 && err == nil; i++ {
+		err = p.Publish("AuthService.Create", &authapi.AuthEvent{Id: "c"})
+	}
+	assert.ErrorIs(err, ErrBufferFull, "should reject events beyond the buffer")
+	fs.setDown(false)
+	assert.Eventually(
+		func() bool { return len(fs.messages()) >= 2 },
+		time.Second, time.Millisecond,
+		"should redeliver buffered events",
+	)
+	assert.NoError(p.Close(), "error in closing publisher")
+	msgs := fs.messages()
+	assert.Equal("a", msgs[0].Header.Get(nats.MsgIdHdr), "should keep the order of events")
+	assert.Equal("b", msgs[1].Header.Get(nats.MsgIdHdr), "should keep the order of events")
+}
+
+func TestCloseUndelivered(t *testing.T) {
+	assert := assert.New(t)
+	fs := &fakeStream{down: true}
+	p := testPublisher(t, fs)
+	assert.NoError(
+		p.Publish("AuthService.Create", &authapi.AuthEvent{Id: "a"}),
+		"should buffer undelivered event",
+	)
+	assert.Error(p.Close(), "should report undelivered events")
+	assert.Empty(fs.messages(), "should not deliver while down")
+}
+
+func TestPublishSingleAttempt(t *testing.T) {
+	assert := assert.New(t)
+	fs := &fakeStream{down: true, wait: 50 * time.Millisecond}
+	p := testPublisher(t, fs)
+	start := time.Now()
+	assert.NoError(
+		p.Publish("AuthService.Create", &authapi.AuthEvent{Id: "a"}),
+		"should buffer undelivered event",
+	)
+	assert.Less(time.Since(start), 2*fs.wait, "should attempt only once within the request")
+	p.opts.DrainTimeout = 0
+	assert.Error(p.Close(), "should report undelivered events")
+}
+
+func TestCloseDrainTimeout(t *testing.T) {
+	assert := assert.New(t)
+	fs := &fakeStream{down: true, wait: 20 * time.Millisecond}
+	opts := testOptions()
+	opts.BufferSize = 50
+	opts.DrainTimeout = 50 * time.Millisecond
+	enc, err := cloudevent.NewEncoder("modware-auth", "protobuf")
+	assert.NoError(err, "error in creating encoder")
+	p := newPublisher(fs, enc, opts)
+	for i := 0; i < opts.BufferSize; i++ {
+		assert.NoError(
+			p.Publish("AuthService.Create", &authapi.AuthEvent{Id: fmt.Sprint(i)}),
+			"should buffer undelivered event",
+		)
+	}
+	start := time.Now()
+	err = p.Close()
+	assert.Less(time.Since(start), opts.DrainTimeout+10*fs.wait, "should give up draining after the timeout")
+	assert.ErrorContains(err, "were not delivered", "should report lost events")
+	assert.Greater(p.lost, opts.BufferSize/2, "should count the events left as lost")
+}
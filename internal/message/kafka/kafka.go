@@ -0,0 +1,122 @@
+// Package kafka publishes the auth events as CloudEvents to Kafka. The
+// events are partitioned by the id of their user, so the events of a user
+// are consumed in order.
+package kafka
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/dictyBase/modware-auth/internal/authapi"
+	"github.com/dictyBase/modware-auth/internal/message"
+	"github.com/dictyBase/modware-auth/internal/message/cloudevent"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+const (
+	writeTimeout = 10 * time.Second
+	dialTimeout  = 5 * time.Second
+	// batchTimeout is how long a write waits for other messages to batch
+	// with, every event is written on its own and waits for it in full
+	batchTimeout = 5 * time.Millisecond
+)
+
+// messageWriter is the part of kafka.Writer that is used for publishing
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafkago.Message) error
+	Close() error
+}
+
+type kafkaPublisher struct {
+	brokers []string
+	writer  messageWriter
+	encoder *cloudevent.Encoder
+	topic   string
+}
+
+// NewPublisher returns a Publisher for the Kafka brokers. The events are
+// written to the given topic, or to a topic named after their subject if
+// the topic is empty.
+func NewPublisher(brokers []string, topic string, enc *cloudevent.Encoder) message.Publisher {
+	p := newPublisher(newWriter(brokers), topic, enc)
+	p.brokers = brokers
+	return p
+}
+
+// newWriter returns the writer for the brokers, it partitions the messages
+// by the hash of their keys
+func newWriter(brokers []string) *kafkago.Writer {
+	return &kafkago.Writer{
+		Addr:         kafkago.TCP(brokers...),
+		Balancer:     &kafkago.Hash{},
+		RequiredAcks: kafkago.RequireAll,
+		WriteTimeout: writeTimeout,
+		BatchTimeout: batchTimeout,
+	}
+}
+
+func newPublisher(w messageWriter, topic string, enc *cloudevent.Encoder) *kafkaPublisher {
+	return &kafkaPublisher{writer: w, encoder: enc, topic: topic}
+}
+
+func (k *kafkaPublisher) Publish(subj string, e *authapi.AuthEvent) error {
+	data, err := k.encoder.Payload(e)
+	if err != nil {
+		return err
+	}
+	topic := k.topic
+	if topic == "" {
+		topic = subj
+	}
+	headers := []kafkago.Header{{
+		Key:   cloudevent.HeaderContentType,
+		Value: []byte(k.encoder.ContentType()),
+	}}
+	for n, v := range k.encoder.Attributes(e) {
+		headers = append(headers, kafkago.Header{
+			Key:   cloudevent.KafkaPrefix + n,
+			Value: []byte(v),
+		})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+	return k.writer.WriteMessages(ctx, kafkago.Message{
+		Topic:   topic,
+		Key:     []byte(partitionKey(e)),
+		Value:   data,
+		Headers: headers,
+	})
+}
+
+// Ping reports whether any of the brokers is reachable
+func (k *kafkaPublisher) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	var err error
+	for _, b := range k.brokers {
+		var conn *kafkago.Conn
+		conn, err = kafkago.DialContext(ctx, "tcp", b)
+		if err == nil {
+			return conn.Close()
+		}
+	}
+	return err
+}
+
+func (k *kafkaPublisher) Close() error {
+	return k.writer.Close()
+}
+
+// partitionKey returns the id of the user of the event. Failed logins have
+// no user and are partitioned by their identity instead.
+func partitionKey(e *authapi.AuthEvent) string {
+	switch {
+	case e.UserId != 0:
+		return strconv.FormatInt(e.UserId, 10)
+	case e.IdentityDigest != "":
+		return e.IdentityDigest
+	default:
+		return e.Id
+	}
+}
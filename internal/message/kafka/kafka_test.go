@@ -0,0 +1,153 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dictyBase/modware-auth/internal/authapi"
+	"github.com/dictyBase/modware-auth/internal/message/cloudevent"
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// fakeWriter partitions the messages by the hash of their keys like the
+// publisher configured for the brokers
+type fakeWriter struct {
+	partitions []int
+	balancer   kafkago.Balancer
+	messages   map[int][]kafkago.Message
+	closed     bool
+}
+
+func newFakeWriter() *fakeWriter {
+	return &fakeWriter{
+		partitions: []int{0, 1, 2, 3},
+		balancer:   &kafkago.Hash{},
+		messages:   make(map[int][]kafkago.Message),
+	}
+}
+
+func (f *fakeWriter) WriteMessages(_ context.Context, msgs ...kafkago.Message) error {
+	for _, m := range msgs {
+		p := f.balancer.Balance(m, f.partitions...)
+		f.messages[p] = append(f.messages[p], m)
+	}
+	return nil
+}
+
+func (f *fakeWriter) Close() error {
+	f.closed = true
+	return nil
+}
+
+func (f *fakeWriter) all() []kafkago.Message {
+	var msgs []kafkago.Message
+	for _, p := range f.partitions {
+		msgs = append(msgs, f.messages[p]...)
+	}
+	return msgs
+}
+
+func newTestPublisher(t *testing.T, topic, format string) (*kafkaPublisher, *fakeWriter) {
+	t.Helper()
+	enc, err := cloudevent.NewEncoder("modware-auth", format)
+	if err != nil {
+		t.Fatalf("error in creating encoder %s", err)
+	}
+	fw := newFakeWriter()
+	return newPublisher(fw, topic, enc), fw
+}
+
+func header(m kafkago.Message, key string) string {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func testEvent(userID int64) *authapi.AuthEvent {
+	return &authapi.AuthEvent{
+		Id:     "c5l1ndr6n88kt0gq4ng0",
+		Type:   authapi.AuthEvent_LOGIN_SUCCEEDED,
+		Time:   timestamppb.Now(),
+		UserId: userID,
+	}
+}
+
+func TestPublish(t *testing.T) {
+	assert := assert.New(t)
+	p, fw := newTestPublisher(t, "", "protobuf")
+	e := testEvent(7)
+	assert.NoError(p.Publish("AuthService.Create", e), "error in publishing event")
+	msgs := fw.all()
+	assert.Len(msgs, 1, "should write the event")
+	m := msgs[0]
+	assert.Equal("AuthService.Create", m.Topic, "should use subject as topic")
+	assert.Equal("7", string(m.Key), "should key by user id")
+	assert.Equal(e.Id, header(m, "ce_id"), "should set id attribute")
+	assert.Equal("org.dictybase.auth.login_succeeded", header(m, "ce_type"), "should set type attribute")
+	assert.Equal("modware-auth", header(m, "ce_source"), "should set source attribute")
+	assert.Equal(cloudevent.SpecVersion, header(m, "ce_specversion"), "should set spec version")
+	assert.Equal(cloudevent.ContentTypeProtobuf, header(m, "content-type"), "should set content type")
+	de := &authapi.AuthEvent{}
+	assert.NoError(proto.Unmarshal(m.Value, de), "error in decoding event")
+	assert.True(proto.Equal(e, de), "should match event")
+	assert.NoError(p.Close(), "error in closing publisher")
+	assert.True(fw.closed, "should close the writer")
+}
+
+func TestPublishTopic(t *testing.T) {
+	assert := assert.New(t)
+	p, fw := newTestPublisher(t, "auth-events", "json")
+	e := testEvent(7)
+	assert.NoError(p.Publish("AuthService.Create", e), "error in publishing event")
+	m := fw.all()[0]
+	assert.Equal("auth-events", m.Topic, "should use configured topic")
+	assert.Equal(cloudevent.ContentTypeJSON, header(m, "content-type"), "should set content type")
+	de := &authapi.AuthEvent{}
+	assert.NoError(protojson.Unmarshal(m.Value, de), "error in decoding event")
+	assert.Equal(e.Id, de.Id, "should match event")
+}
+
+func TestPartition(t *testing.T) {
+	assert := assert.New(t)
+	p, fw := newTestPublisher(t, "auth-events", "protobuf")
+	for i := 0; i < 20; i++ {
+		for _, uid := range []int64{7, 8, 9} {
+			assert.NoError(p.Publish("AuthService.Create", testEvent(uid)), "error in publishing event")
+		}
+	}
+	keys := make(map[string]int)
+	for part, msgs := range fw.messages {
+		for _, m := range msgs {
+			if prev, ok := keys[string(m.Key)]; ok {
+				assert.Equal(prev, part, "should keep the events of a user in one partition")
+			}
+			keys[string(m.Key)] = part
+		}
+	}
+	assert.Len(keys, 3, "should key every user")
+	failed := &authapi.AuthEvent{Id: "c5l1ndr6n88kt0gq4nh0", IdentityDigest: "abc"}
+	assert.Equal("abc", partitionKey(failed), "should key failures by identity")
+	assert.Equal(failed.Id, partitionKey(&authapi.AuthEvent{Id: failed.Id}), "should fall back to event id")
+}
+
+func TestNewPublisherWriter(t *testing.T) {
+	assert := assert.New(t)
+	enc, err := cloudevent.NewEncoder("modware-auth", "protobuf")
+	assert.NoError(err, "error in creating encoder")
+	p := NewPublisher([]string{"localhost:9092"}, "", enc)
+	defer p.Close()
+	w, ok := p.(*kafkaPublisher).writer.(*kafkago.Writer)
+	assert.True(ok, "should write with kafka writer")
+	assert.Equal(batchTimeout, w.BatchTimeout, "should not wait for batches to fill")
+	assert.Less(w.BatchTimeout, 10*time.Millisecond, "should write every event without delay")
+	assert.IsType(&kafkago.Hash{}, w.Balancer, "should partition by key")
+	assert.Equal(kafkago.RequireAll, w.RequiredAcks, "should wait for all replicas")
+}
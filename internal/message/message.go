@@ -1,13 +1,31 @@
 package message
 
 import (
-	"github.com/dictyBase/go-genproto/dictybaseapis/auth"
+	"context"
+
+	"github.com/dictyBase/modware-auth/internal/authapi"
 )
 
 // Publisher manages the publishing of messages
 type Publisher interface {
-	// PublishTokens publishes the token object using the given subject
-	PublishTokens(subject string, token *auth.Token) error
+	// Publish publishes the auth event using the given subject
+	Publish(subject string, event *authapi.AuthEvent) error
 	// Close closes the connection to the underlying messaging server
 	Close() error
 }
+
+// ContextPublisher is implemented by the publishers that propagate the
+// trace context of the request along with the event
+type ContextPublisher interface {
+	// PublishContext publishes the auth event using the given subject
+	PublishContext(ctx context.Context, subject string, event *authapi.AuthEvent) error
+}
+
+// PublishContext publishes the event with the trace context if the
+// publisher is able to propagate it, otherwise it is published without
+func PublishContext(ctx context.Context, p Publisher, subject string, event *authapi.AuthEvent) error {
+	if cp, ok := p.(ContextPublisher); ok {
+		return cp.PublishContext(ctx, subject, event)
+	}
+	return p.Publish(subject, event)
+}
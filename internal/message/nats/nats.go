@@ -1,35 +1,77 @@
 package nats
 
 import (
+	"context"
 	"fmt"
+	"time"
 
-	"github.com/dictyBase/go-genproto/dictybaseapis/auth"
+	"github.com/dictyBase/modware-auth/internal/authapi"
 	"github.com/dictyBase/modware-auth/internal/message"
-	gnats "github.com/nats-io/go-nats"
-	"github.com/nats-io/go-nats/encoders/protobuf"
+	"github.com/dictyBase/modware-auth/internal/message/cloudevent"
+	"github.com/dictyBase/modware-auth/internal/tracing"
+	gnats "github.com/nats-io/nats.go"
 )
 
+// closeGrace is how long Close waits for the connection to be closed
+// beyond the drain timeout of the connection
+const closeGrace = time.Second
+
 type natsPublisher struct {
-	econn *gnats.EncodedConn
+	conn    *gnats.Conn
+	encoder *cloudevent.Encoder
+	// closed is closed by the handler of the closing of the connection
+	closed chan struct{}
 }
 
-func NewPublisher(host, port string, options ...gnats.Option) (message.Publisher, error) {
+// NewPublisher returns a Publisher for core NATS that sends every event
+// as a CloudEvent
+func NewPublisher(host, port string, enc *cloudevent.Encoder, options ...gnats.Option) (message.Publisher, error) {
+	closed := make(chan struct{})
+	options = append(options, gnats.ClosedHandler(func(*gnats.Conn) {
+		close(closed)
+	}))
 	nc, err := gnats.Connect(fmt.Sprintf("nats://%s:%s", host, port), options...)
 	if err != nil {
 		return &natsPublisher{}, err
 	}
-	ec, err := gnats.NewEncodedConn(nc, protobuf.PROTOBUF_ENCODER)
+	return &natsPublisher{conn: nc, encoder: enc, closed: closed}, nil
+}
+
+func (n *natsPublisher) Publish(subj string, e *authapi.AuthEvent) error {
+	return n.PublishContext(context.Background(), subj, e)
+}
+
+// PublishContext publishes the event with the trace context of the
+// request in the headers
+func (n *natsPublisher) PublishContext(ctx context.Context, subj string, e *authapi.AuthEvent) error {
+	msg, err := n.encoder.Message(subj, e)
 	if err != nil {
-		return &natsPublisher{}, err
+		return err
 	}
-	return &natsPublisher{econn: ec}, nil
+	span := tracing.StartPublish(ctx, msg)
+	err = n.conn.PublishMsg(msg)
+	tracing.End(span, err)
+	return err
 }
 
-func (n *natsPublisher) PublishTokens(subj string, t *auth.Token) error {
-	return n.econn.Publish(subj, t)
+// Ping reports whether the connection to the server is established
+func (n *natsPublisher) Ping() error {
+	if s := n.conn.Status(); s != gnats.CONNECTED {
+		return fmt.Errorf("nats connection is %s", s)
+	}
+	return nil
 }
 
+// Close drains the connection, which flushes the pending events, and
+// waits until the connection is closed or the drain has timed out
 func (n *natsPublisher) Close() error {
-	n.econn.Close()
-	return nil
+	if err := n.conn.Drain(); err != nil {
+		return err
+	}
+	select {
+	case <-n.closed:
+		return nil
+	case <-time.After(n.conn.Opts.DrainTimeout + closeGrace):
+		return fmt.Errorf("nats connection was not closed within %s", n.conn.Opts.DrainTimeout)
+	}
 }
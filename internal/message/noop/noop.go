@@ -0,0 +1,27 @@
+// Package noop provides a Publisher that discards all events. It allows
+// running the server without any messaging server.
+package noop
+
+import (
+	"github.com/dictyBase/modware-auth/internal/authapi"
+	"github.com/dictyBase/modware-auth/internal/message"
+)
+
+type noopPublisher struct{}
+
+// NewPublisher returns a Publisher that discards all events
+func NewPublisher() message.Publisher {
+	return noopPublisher{}
+}
+
+func (noopPublisher) Publish(string, *authapi.AuthEvent) error {
+	return nil
+}
+
+func (noopPublisher) Ping() error {
+	return nil
+}
+
+func (noopPublisher) Close() error {
+	return nil
+}
@@ -0,0 +1,157 @@
+// Package outbox relays the events that are stored in the outbox of the
+// repository to the messaging server. A relay claims the events before
+// publishing them, so the relays of several replicas do not publish the
+// same events. An event is removed from the outbox only after it is
+// published, so every event is delivered at least once. An event whose
+// relay fails before removing it is published again once its claim
+// expires, consumers deduplicate by the id of the event.
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dictyBase/modware-auth/internal/authapi"
+	"github.com/dictyBase/modware-auth/internal/message"
+	"github.com/dictyBase/modware-auth/internal/repository"
+	"github.com/dictyBase/modware-auth/internal/tracing"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+)
+
+// claimLease is how long the claimed events are left to the relay that
+// claimed them, the events are relayed by another one afterwards
+const claimLease = time.Minute
+
+// Relay periodically publishes the pending events of the outbox
+type Relay struct {
+	outbox    repository.Outbox
+	publisher message.Publisher
+	logger    *logrus.Entry
+	interval  time.Duration
+	batchSize int
+	stop      chan struct{}
+	done      chan struct{}
+	once      sync.Once
+}
+
+// RelayParams are the attributes that are required for creating a new Relay
+type RelayParams struct {
+	Outbox    repository.Outbox `validate:"required"`
+	Publisher message.Publisher `validate:"required"`
+	Logger    *logrus.Entry     `validate:"required"`
+	// Interval is the pause between two runs of the relay
+	Interval time.Duration `validate:"required"`
+	// BatchSize is the maximum number of events of a single run
+	BatchSize int `validate:"required"`
+}
+
+// NewRelay is the constructor for creating a new instance of Relay
+func NewRelay(p *RelayParams) (*Relay, error) {
+	if err := validator.New().Struct(p); err != nil {
+		return &Relay{}, err
+	}
+	return &Relay{
+		outbox:    p.Outbox,
+		publisher: p.Publisher,
+		logger:    p.Logger,
+		interval:  p.Interval,
+		batchSize: p.BatchSize,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}, nil
+}
+
+// NewEvent encodes the auth event for storing it in the outbox along with
+// the trace context of the request, which is restored for publishing it
+func NewEvent(ctx context.Context, subject string, e *authapi.AuthEvent) (*repository.OutboxEvent, error) {
+	data, err := proto.Marshal(e)
+	if err != nil {
+		return &repository.OutboxEvent{}, fmt.Errorf("error in encoding event %s", err)
+	}
+	parent, state := tracing.TraceContext(ctx)
+	return &repository.OutboxEvent{
+		ID:          e.Id,
+		Subject:     subject,
+		Payload:     data,
+		CreatedAt:   e.Time.AsTime(),
+		TraceParent: parent,
+		TraceState:  state,
+	}, nil
+}
+
+// Start runs the relay in the background until it is stopped
+func (r *Relay) Start() {
+	go r.run()
+}
+
+// Stop stops the relay after relaying the pending events one last time
+func (r *Relay) Stop() {
+	r.once.Do(func() {
+		close(r.stop)
+		<-r.done
+	})
+}
+
+func (r *Relay) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			r.relay() //nolint:errcheck
+			return
+		case <-ticker.C:
+			if err := r.relay(); err != nil {
+				r.logger.WithError(err).Warn("unable to relay outbox events")
+			}
+		}
+	}
+}
+
+// relay claims the pending events and publishes them in order, it stops
+// at the first failure and releases the events that are left, they are
+// retried at the next run
+func (r *Relay) relay() error {
+	for {
+		events, err := r.outbox.ClaimEvents(r.batchSize, claimLease)
+		if err != nil {
+			return fmt.Errorf("error in claiming pending events %s", err)
+		}
+		for i, oe := range events {
+			e := &authapi.AuthEvent{}
+			if err := proto.Unmarshal(oe.Payload, e); err != nil {
+				// an undecodable event would block the outbox forever
+				r.logger.WithError(err).WithField("event", oe.ID).
+					Error("dropping undecodable outbox event")
+			} else if err := message.PublishContext(
+				tracing.WithTraceContext(context.Background(), oe.TraceParent, oe.TraceState),
+				r.publisher, oe.Subject, e,
+			); err != nil {
+				r.release(events[i:])
+				return fmt.Errorf("error in publishing event %s %s", oe.ID, err)
+			}
+			if err := r.outbox.DeleteEvent(oe.ID); err != nil {
+				return fmt.Errorf("error in deleting event %s %s", oe.ID, err)
+			}
+		}
+		if len(events) < r.batchSize {
+			return nil
+		}
+	}
+}
+
+// release removes the claims of the events, an event whose claim cannot be
+// removed is relayed once the claim expires
+func (r *Relay) release(events []*repository.OutboxEvent) {
+	for _, oe := range events {
+		if err := r.outbox.ReleaseEvent(oe.ID); err != nil {
+			r.logger.WithError(err).WithField("event", oe.ID).
+				Warn("unable to release outbox event")
+		}
+	}
+}
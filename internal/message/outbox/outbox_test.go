@@ -0,0 +1,160 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dictyBase/modware-auth/internal/authapi"
+	"github.com/dictyBase/modware-auth/internal/message/recording"
+	"github.com/dictyBase/modware-auth/internal/repository"
+	"github.com/dictyBase/modware-auth/internal/repository/redis"
+	"github.com/rs/xid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func newTestRepo(t *testing.T) repository.AuthRepository {
+	t.Helper()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("unable to start miniredis %s", err)
+	}
+	t.Cleanup(mr.Close)
+	repo, err := redis.NewAuthRepo(mr.Addr(), "modware-auth-test")
+	if err != nil {
+		t.Fatalf("error connecting to redis %s", err)
+	}
+	t.Cleanup(func() { repo.Close() }) //nolint:errcheck
+	return repo
+}
+
+func storeEvents(t *testing.T, repo repository.AuthRepository, types ...authapi.AuthEvent_Type) []*authapi.AuthEvent {
+	t.Helper()
+	var (
+		events []*authapi.AuthEvent
+		oes    []*repository.OutboxEvent
+	)
+	for _, et := range types {
+		e := &authapi.AuthEvent{Id: xid.New().String(), Type: et, Time: timestamppb.Now()}
+		oe, err := NewEvent(context.Background(), "AuthService.Create", e)
+		if err != nil {
+			t.Fatalf("error in encoding event %s", err)
+		}
+		events = append(events, e)
+		oes = append(oes, oe)
+		time.Sleep(time.Millisecond)
+	}
+	sess := &repository.Session{ID: xid.New().String(), UserID: 7}
+	if err := repo.SetSession(sess, "vandelay", time.Hour, oes...); err != nil {
+		t.Fatalf("error in storing session %s", err)
+	}
+	return events
+}
+
+func newTestRelay(t *testing.T, repo repository.AuthRepository, pub *recording.Publisher) *Relay {
+	t.Helper()
+	r, err := NewRelay(&RelayParams{
+		Outbox:    repo,
+		Publisher: pub,
+		Logger:    logrus.NewEntry(logrus.New()),
+		Interval:  time.Hour,
+		BatchSize: 1,
+	})
+	if err != nil {
+		t.Fatalf("error in creating relay %s", err)
+	}
+	return r
+}
+
+func TestRelay(t *testing.T) {
+	assert := assert.New(t)
+	repo, pub := newTestRepo(t), recording.NewPublisher()
+	pub.Fail(errors.New("nats: connection closed"))
+	events := storeEvents(t, repo, authapi.AuthEvent_LOGIN_SUCCEEDED, authapi.AuthEvent_TOKEN_REFRESHED)
+	r := newTestRelay(t, repo, pub)
+	assert.Error(r.relay(), "should fail while publisher is down")
+	pending, err := repo.PendingEvents(10)
+	assert.NoError(err, "error in getting pending events")
+	assert.Len(pending, 2, "should keep undelivered events")
+	pub.Fail(nil)
+	assert.NoError(r.relay(), "error in relaying events")
+	records := pub.Records()
+	assert.Len(records, 2, "should publish all events across batches")
+	for i, e := range events {
+		assert.Equal(e.Id, records[i].Event.Id, "should publish in order")
+		assert.Equal(e.Type, records[i].Event.Type, "should decode the event")
+		assert.Equal("AuthService.Create", records[i].Subject, "should use stored subject")
+	}
+	pending, err = repo.PendingEvents(10)
+	assert.NoError(err, "error in getting pending events")
+	assert.Empty(pending, "should remove relayed events")
+}
+
+func TestRelayStop(t *testing.T) {
+	assert := assert.New(t)
+	repo, pub := newTestRepo(t), recording.NewPublisher()
+	storeEvents(t, repo, authapi.AuthEvent_LOGIN_SUCCEEDED)
+	r := newTestRelay(t, repo, pub)
+	r.Start()
+	r.Stop()
+	r.Stop()
+	assert.Len(pub.Events(), 1, "should relay pending events when stopped")
+}
+
+func TestRelayTraceContext(t *testing.T) {
+	assert := assert.New(t)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	repo, pub := newTestRepo(t), recording.NewPublisher()
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+	e := &authapi.AuthEvent{Id: xid.New().String(), Type: authapi.AuthEvent_LOGIN_SUCCEEDED, Time: timestamppb.Now()}
+	oe, err := NewEvent(ctx, "AuthService.Create", e)
+	assert.NoError(err, "error in encoding event")
+	assert.Equal(
+		"00-4bf92f3577b34da60000000000000000-00f067aa0ba902b7-01",
+		oe.TraceParent,
+		"should store trace context of request",
+	)
+	sess := &repository.Session{ID: xid.New().String(), UserID: 7}
+	assert.NoError(repo.SetSession(sess, "vandelay", time.Hour, oe), "error in storing session")
+	assert.NoError(newTestRelay(t, repo, pub).relay(), "error in relaying events")
+	records := pub.Records()
+	assert.Len(records, 1, "should publish event")
+	assert.Equal(sc.TraceID(), records[0].SpanContext.TraceID(), "should publish within trace of request")
+	assert.Equal(sc.SpanID(), records[0].SpanContext.SpanID(), "should publish as child of request span")
+}
+
+func TestRelayClaim(t *testing.T) {
+	assert := assert.New(t)
+	repo, pub := newTestRepo(t), recording.NewPublisher()
+	storeEvents(
+		t, repo,
+		authapi.AuthEvent_LOGIN_SUCCEEDED,
+		authapi.AuthEvent_TOKEN_REFRESHED,
+		authapi.AuthEvent_LOGOUT,
+	)
+	claimed, err := repo.ClaimEvents(1, claimLease)
+	assert.NoError(err, "error in claiming events")
+	assert.Len(claimed, 1, "should claim the first event")
+	r := newTestRelay(t, repo, pub)
+	assert.NoError(r.relay(), "error in relaying events")
+	records := pub.Records()
+	assert.Len(records, 2, "should relay the events that are not claimed")
+	for _, rec := range records {
+		assert.NotEqual(claimed[0].ID, rec.Event.Id, "should not relay the event claimed by another relay")
+	}
+	pending, err := repo.PendingEvents(10)
+	assert.NoError(err, "error in getting pending events")
+	assert.Len(pending, 1, "should leave the claimed event to its relay")
+}
@@ -0,0 +1,107 @@
+// Package recording provides a Publisher that keeps the published events
+// in memory for the assertions of tests
+package recording
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dictyBase/modware-auth/internal/authapi"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Record is a published event along with its subject
+type Record struct {
+	Subject string
+	Event   *authapi.AuthEvent
+	// SpanContext is the span of the context the event is published with
+	SpanContext trace.SpanContext
+}
+
+// Publisher records the published events, it is safe for concurrent use
+type Publisher struct {
+	mu      sync.Mutex
+	records []Record
+	err     error
+	closed  bool
+}
+
+// NewPublisher returns a Publisher without any recorded event
+func NewPublisher() *Publisher {
+	return &Publisher{}
+}
+
+// Publish records the event unless a failure is set
+func (p *Publisher) Publish(subject string, e *authapi.AuthEvent) error {
+	return p.PublishContext(context.Background(), subject, e)
+}
+
+// PublishContext records the event along with the span of the context
+// unless a failure is set
+func (p *Publisher) PublishContext(ctx context.Context, subject string, e *authapi.AuthEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	p.records = append(p.records, Record{
+		Subject:     subject,
+		Event:       e,
+		SpanContext: trace.SpanContextFromContext(ctx),
+	})
+	return nil
+}
+
+func (p *Publisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return nil
+}
+
+// Fail makes the following publishes fail with the error, a nil error
+// makes them succeed again
+func (p *Publisher) Fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+// Records returns the recorded events in the order of their publishing
+func (p *Publisher) Records() []Record {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Record{}, p.records...)
+}
+
+// Events returns the recorded events without their subjects
+func (p *Publisher) Events() []*authapi.AuthEvent {
+	var el []*authapi.AuthEvent
+	for _, r := range p.Records() {
+		el = append(el, r.Event)
+	}
+	return el
+}
+
+// Subjects returns the subjects of the recorded events
+func (p *Publisher) Subjects() []string {
+	var sl []string
+	for _, r := range p.Records() {
+		sl = append(sl, r.Subject)
+	}
+	return sl
+}
+
+// Closed reports whether the publisher was closed
+func (p *Publisher) Closed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+// Reset removes the recorded events
+func (p *Publisher) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.records = nil
+}
@@ -0,0 +1,148 @@
+// Package metrics provides the Prometheus instrumentation of the server.
+// All methods are safe to call on a nil *Metrics, which discards the
+// observations, so the services run without instrumentation in tests.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/dictyBase/modware-auth/internal/jwtauth"
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "modware_auth"
+
+// OutcomeSuccess is the outcome of a successful login, refresh or logout
+const OutcomeSuccess = "success"
+
+// Metrics holds the collectors of the server in its own registry
+type Metrics struct {
+	registry             *prometheus.Registry
+	logins               *prometheus.CounterVec
+	refreshes            *prometheus.CounterVec
+	logouts              *prometheus.CounterVec
+	verificationFailures *prometheus.CounterVec
+	providerLatency      *prometheus.HistogramVec
+	repositoryLatency    *prometheus.HistogramVec
+	// GRPCServer instruments the gRPC server through its interceptor
+	GRPCServer *grpc_prometheus.ServerMetrics
+	// GRPCClient instruments the calls to the user and identity services
+	GRPCClient *grpc_prometheus.ClientMetrics
+}
+
+// New creates and registers all collectors including the Go runtime and
+// process collectors
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "logins_total",
+			Help:      "Logins by provider and outcome.",
+		}, []string{"provider", "outcome"}),
+		refreshes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "refreshes_total",
+			Help:      "Token refreshes by outcome.",
+		}, []string{"outcome"}),
+		logouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "logouts_total",
+			Help:      "Logouts by outcome.",
+		}, []string{"outcome"}),
+		verificationFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "token_verification_failures_total",
+			Help:      "Failed verifications of access and refresh tokens by reason.",
+		}, []string{"reason"}),
+		providerLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "provider_exchange_duration_seconds",
+			Help:      "Duration of the code exchange and user retrieval with the login provider.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"provider"}),
+		repositoryLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "repository_duration_seconds",
+			Help:      "Duration of the repository operations.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"operation"}),
+		GRPCServer: grpc_prometheus.NewServerMetrics(),
+		GRPCClient: grpc_prometheus.NewClientMetrics(),
+	}
+	m.GRPCServer.EnableHandlingTimeHistogram()
+	m.GRPCClient.EnableClientHandlingTimeHistogram()
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.logins,
+		m.refreshes,
+		m.logouts,
+		m.verificationFailures,
+		m.providerLatency,
+		m.repositoryLatency,
+		m.GRPCServer,
+		m.GRPCClient,
+	)
+	return m
+}
+
+// Handler returns the HTTP handler of the /metrics endpoint
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Login counts a login with the provider
+func (m *Metrics) Login(provider, outcome string) {
+	if m == nil {
+		return
+	}
+	m.logins.WithLabelValues(provider, outcome).Inc()
+}
+
+// Refresh counts a token refresh
+func (m *Metrics) Refresh(outcome string) {
+	if m == nil {
+		return
+	}
+	m.refreshes.WithLabelValues(outcome).Inc()
+}
+
+// Logout counts a logout
+func (m *Metrics) Logout(outcome string) {
+	if m == nil {
+		return
+	}
+	m.logouts.WithLabelValues(outcome).Inc()
+}
+
+// VerificationFailure counts a failed token verification by the type of
+// its jwtauth error
+func (m *Metrics) VerificationFailure(err error) {
+	if m == nil {
+		return
+	}
+	m.verificationFailures.WithLabelValues(jwtauth.Reason(err)).Inc()
+}
+
+// ObserveProvider records the duration of a login with the provider
+// that started at the given time
+func (m *Metrics) ObserveProvider(provider string, start time.Time) {
+	if m == nil {
+		return
+	}
+	m.providerLatency.WithLabelValues(provider).Observe(time.Since(start).Seconds())
+}
+
+// ObserveRepository records the duration of a repository operation that
+// started at the given time
+func (m *Metrics) ObserveRepository(operation string, start time.Time) {
+	if m == nil {
+		return
+	}
+	m.repositoryLatency.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
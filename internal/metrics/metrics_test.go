@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dictyBase/modware-auth/internal/jwtauth"
+	"github.com/dictyBase/modware-auth/internal/repository/redis"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounters(t *testing.T) {
+	assert := assert.New(t)
+	m := New()
+	m.Login("google", OutcomeSuccess)
+	m.Login("google", OutcomeSuccess)
+	m.Login("orcid", "provider_login")
+	m.Refresh("invalid_token")
+	m.Logout(OutcomeSuccess)
+	m.VerificationFailure(jwtauth.ErrExpired)
+	m.VerificationFailure(errors.New("token contains an invalid number of segments"))
+	assert.Equal(2.0, testutil.ToFloat64(m.logins.WithLabelValues("google", OutcomeSuccess)), "should count logins by provider")
+	assert.Equal(1.0, testutil.ToFloat64(m.logins.WithLabelValues("orcid", "provider_login")), "should count failed logins")
+	assert.Equal(1.0, testutil.ToFloat64(m.refreshes.WithLabelValues("invalid_token")), "should count refreshes")
+	assert.Equal(1.0, testutil.ToFloat64(m.logouts.WithLabelValues(OutcomeSuccess)), "should count logouts")
+	assert.Equal(1.0, testutil.ToFloat64(m.verificationFailures.WithLabelValues("expired")), "should count expired tokens")
+	assert.Equal(1.0, testutil.ToFloat64(m.verificationFailures.WithLabelValues("malformed")), "should count malformed tokens")
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+	assert.NotPanics(t, func() {
+		m.Login("google", OutcomeSuccess)
+		m.Refresh(OutcomeSuccess)
+		m.Logout(OutcomeSuccess)
+		m.VerificationFailure(jwtauth.ErrExpired)
+		m.ObserveProvider("google", time.Now())
+		m.ObserveRepository("get_token", time.Now())
+	}, "should discard observations without metrics")
+}
+
+func TestRepository(t *testing.T) {
+	assert := assert.New(t)
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("unable to start miniredis %s", err)
+	}
+	defer mr.Close()
+	rrepo, err := redis.NewAuthRepo(mr.Addr(), "modware-auth-test")
+	if err != nil {
+		t.Fatalf("error connecting to redis %s", err)
+	}
+	m := New()
+	repo := m.Repository(rrepo)
+	defer repo.Close()
+	assert.NoError(repo.SetToken("jti", "token", time.Minute), "error in setting token")
+	ok, err := repo.HasToken("jti")
+	assert.NoError(err, "error in checking token")
+	assert.True(ok, "should find the token of the repository")
+	assert.NoError(repo.Ping(), "error in pinging repository")
+	assert.Equal(3, testutil.CollectAndCount(m.repositoryLatency), "should observe every operation")
+}
+
+func TestHandler(t *testing.T) {
+	assert := assert.New(t)
+	m := New()
+	m.Login("google", OutcomeSuccess)
+	m.ObserveProvider("google", time.Now())
+	srv := httptest.NewServer(m.Handler())
+	defer srv.Close()
+	res, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatalf("error in requesting metrics %s", err)
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	assert.NoError(err, "error in reading metrics")
+	body := string(b)
+	assert.Contains(body, `modware_auth_logins_total{outcome="success",provider="google"} 1`, "should expose logins")
+	assert.Contains(body, "modware_auth_provider_exchange_duration_seconds_count", "should expose provider latency")
+	assert.Contains(body, "go_goroutines", "should expose runtime metrics")
+}
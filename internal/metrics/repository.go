@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/dictyBase/modware-auth/internal/repository"
+)
+
+// instrumentedRepo records the duration of every operation of the
+// wrapped repository
+type instrumentedRepo struct {
+	repo    repository.AuthRepository
+	metrics *Metrics
+}
+
+// Repository returns the repository instrumented with the metrics
+func (m *Metrics) Repository(repo repository.AuthRepository) repository.AuthRepository {
+	return &instrumentedRepo{repo: repo, metrics: m}
+}
+
+func (r *instrumentedRepo) observe(op string) func() {
+	start := time.Now()
+	return func() { r.metrics.ObserveRepository(op, start) }
+}
+
+func (r *instrumentedRepo) GetToken(key string) (string, error) {
+	defer r.observe("get_token")()
+	return r.repo.GetToken(key)
+}
+
+func (r *instrumentedRepo) SetToken(key, val string, ttl time.Duration) error {
+	defer r.observe("set_token")()
+	return r.repo.SetToken(key, val, ttl)
+}
+
+func (r *instrumentedRepo) DeleteToken(key string) error {
+	defer r.observe("delete_token")()
+	return r.repo.DeleteToken(key)
+}
+
+func (r *instrumentedRepo) HasToken(key string) (bool, error) {
+	defer r.observe("has_token")()
+	return r.repo.HasToken(key)
+}
+
+func (r *instrumentedRepo) SetSession(
+	sess *repository.Session,
+	token string,
+	ttl time.Duration,
+	events ...*repository.OutboxEvent,
+) error {
+	defer r.observe("set_session")()
+	return r.repo.SetSession(sess, token, ttl, events...)
+}
+
+func (r *instrumentedRepo) RefreshSession(
+	sess *repository.Session,
+	token string,
+	ttl time.Duration,
+	events ...*repository.OutboxEvent,
+) error {
+	defer r.observe("refresh_session")()
+	return r.repo.RefreshSession(sess, token, ttl, events...)
+}
+
+func (r *instrumentedRepo) GetSession(id string) (*repository.Session, error) {
+	defer r.observe("get_session")()
+	return r.repo.GetSession(id)
+}
+
+func (r *instrumentedRepo) ListSessions(userID int64) ([]*repository.Session, error) {
+	defer r.observe("list_sessions")()
+	return r.repo.ListSessions(userID)
+}
+
+func (r *instrumentedRepo) DeleteSession(id string) error {
+	defer r.observe("delete_session")()
+	return r.repo.DeleteSession(id)
+}
+
+func (r *instrumentedRepo) DeleteUserSessions(userID int64) (int, error) {
+	defer r.observe("delete_user_sessions")()
+	return r.repo.DeleteUserSessions(userID)
+}
+
+func (r *instrumentedRepo) LockUser(userID int64, d time.Duration) error {
+	defer r.observe("lock_user")()
+	return r.repo.LockUser(userID, d)
+}
+
+func (r *instrumentedRepo) IsUserLocked(userID int64) (bool, error) {
+	defer r.observe("is_user_locked")()
+	return r.repo.IsUserLocked(userID)
+}
+
+func (r *instrumentedRepo) UnlockUser(userID int64) error {
+	defer r.observe("unlock_user")()
+	return r.repo.UnlockUser(userID)
+}
+
+func (r *instrumentedRepo) PendingEvents(limit int) ([]*repository.OutboxEvent, error) {
+	defer r.observe("pending_events")()
+	return r.repo.PendingEvents(limit)
+}
+
+func (r *instrumentedRepo) ClaimEvents(limit int, lease time.Duration) ([]*repository.OutboxEvent, error) {
+	defer r.observe("claim_events")()
+	return r.repo.ClaimEvents(limit, lease)
+}
+
+func (r *instrumentedRepo) ReleaseEvent(id string) error {
+	defer r.observe("release_event")()
+	return r.repo.ReleaseEvent(id)
+}
+
+func (r *instrumentedRepo) DeleteEvent(id string) error {
+	defer r.observe("delete_event")()
+	return r.repo.DeleteEvent(id)
+}
+
+func (r *instrumentedRepo) SetClient(c *repository.Client) error {
+	defer r.observe("set_client")()
+	return r.repo.SetClient(c)
+}
+
+func (r *instrumentedRepo) GetClient(id string) (*repository.Client, error) {
+	defer r.observe("get_client")()
+	return r.repo.GetClient(id)
+}
+
+func (r *instrumentedRepo) ListClients() ([]*repository.Client, error) {
+	defer r.observe("list_clients")()
+	return r.repo.ListClients()
+}
+
+func (r *instrumentedRepo) DeleteClient(id string) error {
+	defer r.observe("delete_client")()
+	return r.repo.DeleteClient(id)
+}
+
+func (r *instrumentedRepo) Ping() error {
+	defer r.observe("ping")()
+	return r.repo.Ping()
+}
+
+func (r *instrumentedRepo) Close() error {
+	return r.repo.Close()
+}
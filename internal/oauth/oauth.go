@@ -11,6 +11,7 @@ import (
 
 	"github.com/dictyBase/go-genproto/dictybaseapis/auth"
 	"github.com/dictyBase/modware-auth/internal/user"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"golang.org/x/oauth2/linkedin"
@@ -21,6 +22,32 @@ var OrcidEndpoint = oauth2.Endpoint{
 	TokenURL: "https://orcid.org/oauth/token",
 }
 
+// httpClient records a span for every request to the providers
+var httpClient = &http.Client{
+	Transport: otelhttp.NewTransport(
+		http.DefaultTransport,
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return fmt.Sprintf("oauth %s %s%s", r.Method, r.URL.Host, r.URL.Path)
+		}),
+	),
+}
+
+// clientContext returns the context that makes the oauth2 package use
+// the traced http client
+func clientContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, httpClient)
+}
+
+// getUser requests the user data from the provider as part of the trace
+// of the request
+func getUser(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
 type ProviderSecrets struct {
 	Google   string `json:"google"`
 	LinkedIn string `json:"linkedin"`
@@ -43,13 +70,13 @@ func OrcidLogin(ctx context.Context, l *Login) (*user.NormalizedUser, error) {
 		l.NewLogin.Code,
 	)
 	body := strings.NewReader(postBody)
-	req, err := http.NewRequest("POST", OrcidEndpoint.TokenURL, body)
+	req, err := http.NewRequestWithContext(ctx, "POST", OrcidEndpoint.TokenURL, body)
 	if err != nil {
 		return nu, aphgrpc.HandleJSONEncodingError(ctx, err)
 	}
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return nu, aphgrpc.HandleOauthExchangeError(ctx, err)
 	}
@@ -75,12 +102,12 @@ func GoogleLogin(ctx context.Context, l *Login) (*user.NormalizedUser, error) {
 		RedirectURL:  l.NewLogin.RedirectUrl,
 		Scopes:       strings.Split(l.NewLogin.Scopes, " "),
 	}
-	token, err := oc.Exchange(ctx, l.NewLogin.Code)
+	token, err := oc.Exchange(clientContext(ctx), l.NewLogin.Code)
 	if err != nil {
 		return nu, aphgrpc.HandleOauthExchangeError(ctx, err)
 	}
-	oauthClient := oc.Client(ctx, token)
-	resp, err := oauthClient.Get(user.Google)
+	oauthClient := oc.Client(clientContext(ctx), token)
+	resp, err := getUser(ctx, oauthClient, user.Google)
 	if err != nil {
 		return nu, aphgrpc.HandleUserRetrievalError(ctx, err)
 	}
@@ -107,12 +134,12 @@ func LinkedInLogin(ctx context.Context, l *Login) (*user.NormalizedUser, error)
 		RedirectURL:  l.NewLogin.RedirectUrl,
 		Scopes:       strings.Split(l.NewLogin.Scopes, " "),
 	}
-	token, err := oc.Exchange(context.Background(), l.NewLogin.Code)
+	token, err := oc.Exchange(clientContext(ctx), l.NewLogin.Code)
 	if err != nil {
 		return nu, aphgrpc.HandleOauthExchangeError(ctx, err)
 	}
-	oauthClient := oc.Client(context.Background(), token)
-	resp, err := oauthClient.Get(user.LinkedIn)
+	oauthClient := oc.Client(clientContext(ctx), token)
+	resp, err := getUser(ctx, oauthClient, user.LinkedIn)
 	if err != nil {
 		return nu, aphgrpc.HandleUserRetrievalError(ctx, err)
 	}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dictyBase/modware-auth/internal/repository"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+// InterceptorParams are the attributes that are required for creating
+// the rate limiting interceptor
+type InterceptorParams struct {
+	Limiter *Limiter `validate:"required"`
+	// Methods are the full names of the limited methods
+	Methods []string `validate:"required,min=1"`
+	// IP is the bucket of every client address
+	IP Bucket
+	// Identity is the bucket of every identity
+	Identity Bucket
+	// ClientIP returns the address of the client of the request
+	ClientIP func(ctx context.Context) string `validate:"required"`
+	// RequestIdentity returns the verified identity of the request, a
+	// request without identity is only limited by the client address
+	RequestIdentity func(ctx context.Context, req interface{}) string `validate:"required"`
+	Logger          *logrus.Entry                                     `validate:"required"`
+}
+
+type limit struct {
+	key    string
+	bucket Bucket
+}
+
+// UnaryServerInterceptor returns the interceptor that rejects the
+// requests of the limited methods with ResourceExhausted once the bucket
+// of the client address or the identity is empty. Requests are allowed
+// while Redis is unreachable.
+func UnaryServerInterceptor(p *InterceptorParams) (grpc.UnaryServerInterceptor, error) {
+	if err := validator.New().Struct(p); err != nil {
+		return nil, err
+	}
+	methods := make(map[string]bool, len(p.Methods))
+	for _, m := range p.Methods {
+		methods[m] = true
+	}
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if !methods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		var limits []limit
+		if ip := p.ClientIP(ctx); ip != "" && p.IP.Enabled() {
+			limits = append(limits, limit{"ip:" + ip, p.IP})
+		}
+		if idn := p.RequestIdentity(ctx, req); idn != "" && p.Identity.Enabled() {
+			limits = append(limits, limit{"identity:" + repository.Digest(idn), p.Identity})
+		}
+		for _, l := range limits {
+			wait, err := p.Limiter.Allow(l.key, l.bucket)
+			if err != nil {
+				p.Logger.WithError(err).Warn("rate limit is not enforced")
+				continue
+			}
+			if wait > 0 {
+				return nil, Exhausted(ctx, wait, fmt.Errorf(
+					"too many requests for %s, retry in %s", info.FullMethod, wait,
+				))
+			}
+		}
+		return handler(ctx, req)
+	}, nil
+}
@@ -0,0 +1,118 @@
+package ratelimit
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/go-redis/redis/v7"
+)
+
+// failureScript counts the failure of the identity and locks it once the
+// failures reach the threshold. Every further failure doubles the lockout
+// up to the maximum. The failures are forgotten after the maximum
+// lockout without any failure. It returns the milliseconds of the
+// lockout, zero if the identity is not locked.
+var failureScript = redis.NewScript(`
+local threshold = tonumber(ARGV[1])
+local base = tonumber(ARGV[2])
+local max = tonumber(ARGV[3])
+local n = redis.call("INCR", KEYS[1])
+redis.call("PEXPIRE", KEYS[1], max)
+if n < threshold then
+	return 0
+end
+local d = math.min(max, base * 2 ^ (n - threshold))
+d = math.floor(d)
+redis.call("SET", KEYS[2], n, "PX", d)
+return d
+`)
+
+// LockoutParams are the attributes that are required for creating a new
+// Lockout
+type LockoutParams struct {
+	Client    *redis.Client `validate:"required"`
+	Namespace string        `validate:"required"`
+	// Threshold is the number of failures that locks the identity
+	Threshold int `validate:"required,min=1"`
+	// Base is the lockout at the threshold
+	Base time.Duration `validate:"required"`
+	// Max is the longest lockout
+	Max time.Duration `validate:"required,gtefield=Base"`
+}
+
+// Lockout locks identities out after repeated failures with an
+// exponentially growing duration. The keys become part of the redis keys,
+// so they should be digests rather than the identities themselves. All
+// methods are safe to call on a nil *Lockout, which never locks any
+// identity.
+type Lockout struct {
+	client    *redis.Client
+	namespace string
+	threshold int
+	base      time.Duration
+	max       time.Duration
+}
+
+// NewLockout is the constructor for creating a new instance of Lockout
+func NewLockout(p *LockoutParams) (*Lockout, error) {
+	if err := validator.New().Struct(p); err != nil {
+		return &Lockout{}, err
+	}
+	return &Lockout{
+		client:    p.Client,
+		namespace: p.Namespace,
+		threshold: p.Threshold,
+		base:      p.Base,
+		max:       p.Max,
+	}, nil
+}
+
+func (l *Lockout) failuresKey(key string) string {
+	return fmt.Sprintf("%s:lockout:failures:%s", l.namespace, key)
+}
+
+func (l *Lockout) lockKey(key string) string {
+	return fmt.Sprintf("%s:lockout:%s", l.namespace, key)
+}
+
+// Locked returns the remaining lockout of the key, zero if it is not
+// locked
+func (l *Lockout) Locked(key string) (time.Duration, error) {
+	if l == nil {
+		return 0, nil
+	}
+	d, err := l.client.PTTL(l.lockKey(key)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("error in looking up lockout of %s %s", key, err)
+	}
+	if d < 0 {
+		return 0, nil
+	}
+	return d, nil
+}
+
+// Failure counts a failure of the key and returns the lockout that
+// starts with it, zero if the key is not locked
+func (l *Lockout) Failure(key string) (time.Duration, error) {
+	if l == nil {
+		return 0, nil
+	}
+	ms, err := failureScript.Run(
+		l.client,
+		[]string{l.failuresKey(key), l.lockKey(key)},
+		l.threshold, l.base.Milliseconds(), l.max.Milliseconds(),
+	).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("error in counting failure of %s %s", key, err)
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// Reset forgets the failures of the key after a success
+func (l *Lockout) Reset(key string) error {
+	if l == nil {
+		return nil
+	}
+	return l.client.Del(l.failuresKey(key), l.lockKey(key)).Err()
+}
@@ -0,0 +1,117 @@
+// Package ratelimit limits the requests of clients with token buckets and
+// locks out identities after repeated failures. The state is kept in
+// Redis, so the limits are shared by all replicas of the server.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// RetryAfterHeader is the metadata with the seconds until a rejected
+// request may be retried
+const RetryAfterHeader = "retry-after"
+
+// bucketScript takes a token from the bucket if there is one. The bucket
+// is refilled with rate tokens per second up to burst tokens. It returns
+// whether the request is allowed and otherwise the milliseconds until the
+// next token is available.
+var bucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local b = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(b[1])
+local ts = tonumber(b[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate * 1000)
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate * 1000))
+return {allowed, wait}
+`)
+
+// Bucket is the size and the refill rate of a token bucket
+type Bucket struct {
+	// Rate is the number of tokens that are added per second
+	Rate float64
+	// Burst is the maximum number of tokens
+	Burst int
+}
+
+// Enabled reports whether the bucket limits any request
+func (b Bucket) Enabled() bool {
+	return b.Rate > 0 && b.Burst > 0
+}
+
+// Limiter takes tokens from the buckets stored in Redis
+type Limiter struct {
+	client    *redis.Client
+	namespace string
+}
+
+// NewLimiter returns the Limiter with the buckets stored under the
+// namespace
+func NewLimiter(client *redis.Client, namespace string) *Limiter {
+	return &Limiter{client: client, namespace: namespace}
+}
+
+// Allow takes a token from the bucket of the key. It returns zero if the
+// request is allowed and otherwise the time until it may be retried.
+func (l *Limiter) Allow(key string, b Bucket) (time.Duration, error) {
+	res, err := bucketScript.Run(
+		l.client,
+		[]string{fmt.Sprintf("%s:ratelimit:%s", l.namespace, key)},
+		b.Rate, b.Burst, time.Now().UnixNano()/int64(time.Millisecond),
+	).Result()
+	if err != nil {
+		return 0, fmt.Errorf("error in taking token for %s %s", key, err)
+	}
+	vals, ok := res.([]interface{})
+	if !ok || len(vals) != 2 {
+		return 0, fmt.Errorf("unexpected result of bucket %v", res)
+	}
+	if allowed, _ := vals[0].(int64); allowed == 1 {
+		return 0, nil
+	}
+	wait, _ := vals[1].(int64)
+	return time.Duration(wait) * time.Millisecond, nil
+}
+
+// Exhausted returns the ResourceExhausted error for a request that is
+// rejected for the given time. The time is also set as retry-after
+// metadata in whole seconds and as RetryInfo detail of the status.
+func Exhausted(ctx context.Context, retryAfter time.Duration, err error) error {
+	secs := int64(math.Ceil(retryAfter.Seconds()))
+	grpc.SetHeader(ctx, metadata.Pairs( //nolint:errcheck
+		RetryAfterHeader, strconv.FormatInt(secs, 10),
+	))
+	st := status.New(codes.ResourceExhausted, err.Error())
+	if dst, derr := st.WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(retryAfter),
+	}); derr == nil {
+		st = dst
+	}
+	return st.Err()
+}
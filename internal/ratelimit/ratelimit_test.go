@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestClient(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("unable to start miniredis %s", err)
+	}
+	t.Cleanup(mr.Close)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() }) //nolint:errcheck
+	return mr, client
+}
+
+func TestLimiter(t *testing.T) {
+	assert := assert.New(t)
+	_, client := newTestClient(t)
+	l := NewLimiter(client, "modware-auth-test")
+	b := Bucket{Rate: 20, Burst: 3}
+	for i := 0; i < 3; i++ {
+		wait, err := l.Allow("ip:10.0.0.1", b)
+		assert.NoError(err, "error in taking token")
+		assert.Zero(wait, "should allow burst of requests")
+	}
+	wait, err := l.Allow("ip:10.0.0.1", b)
+	assert.NoError(err, "error in taking token")
+	assert.True(wait > 0 && wait <= 50*time.Millisecond, "should reject request until refill")
+	wait, err = l.Allow("ip:10.0.0.2", b)
+	assert.NoError(err, "error in taking token")
+	assert.Zero(wait, "should limit every key separately")
+	time.Sleep(60 * time.Millisecond)
+	wait, err = l.Allow("ip:10.0.0.1", b)
+	assert.NoError(err, "error in taking token")
+	assert.Zero(wait, "should allow request after refill")
+}
+
+func TestLockout(t *testing.T) {
+	assert := assert.New(t)
+	mr, client := newTestClient(t)
+	l, err := NewLockout(&LockoutParams{
+		Client:    client,
+		Namespace: "modware-auth-test",
+		Threshold: 2,
+		Base:      time.Minute,
+		Max:       3 * time.Minute,
+	})
+	assert.NoError(err, "error in creating lockout")
+	for _, want := range []time.Duration{0, time.Minute, 2 * time.Minute, 3 * time.Minute} {
+		d, err := l.Failure("digest")
+		assert.NoError(err, "error in counting failure")
+		assert.Equal(want, d, "should double the lockout up to the maximum")
+	}
+	d, err := l.Locked("digest")
+	assert.NoError(err, "error in looking up lockout")
+	assert.Equal(3*time.Minute, d, "should be locked")
+	mr.FastForward(3 * time.Minute)
+	d, err = l.Locked("digest")
+	assert.NoError(err, "error in looking up lockout")
+	assert.Zero(d, "should unlock after the lockout")
+	_, err = l.Failure("digest")
+	assert.NoError(err, "error in counting failure")
+	assert.NoError(l.Reset("digest"), "error in resetting lockout")
+	d, err = l.Failure("digest")
+	assert.NoError(err, "error in counting failure")
+	assert.Zero(d, "should forget the failures after reset")
+	var nl *Lockout
+	d, err = nl.Failure("digest")
+	assert.NoError(err, "should ignore failures without lockout")
+	assert.Zero(d, "should never lock without lockout")
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	assert := assert.New(t)
+	_, client := newTestClient(t)
+	interceptor, err := UnaryServerInterceptor(&InterceptorParams{
+		Limiter:         NewLimiter(client, "modware-auth-test"),
+		Methods:         []string{"/grpc.health.v1.Health/Check"},
+		IP:              Bucket{Rate: 0.01, Burst: 3},
+		Identity:        Bucket{Rate: 0.01, Burst: 2},
+		ClientIP:        func(context.Context) string { return "10.0.0.1" },
+		RequestIdentity: func(context.Context, interface{}) string { return "jo@dicty.org" },
+		Logger:          logrus.NewEntry(logrus.New()),
+	})
+	assert.NoError(err, "error in creating interceptor")
+	lis := bufconn.Listen(1 << 16)
+	srv := grpc.NewServer(grpc.UnaryInterceptor(interceptor))
+	grpc_health_v1.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis) //nolint:errcheck
+	defer srv.Stop()
+	conn, err := grpc.Dial(
+		"bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("error in dialing %s", err)
+	}
+	defer conn.Close()
+	hc := grpc_health_v1.NewHealthClient(conn)
+	for i := 0; i < 2; i++ {
+		_, err := hc.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		assert.NoError(err, "should allow burst of identity")
+	}
+	var md metadata.MD
+	_, err = hc.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{}, grpc.Header(&md))
+	assert.Equal(codes.ResourceExhausted, status.Code(err), "should reject request of empty bucket")
+	secs, _ := strconv.Atoi(md.Get(RetryAfterHeader)[0])
+	assert.True(secs > 0 && secs <= 100, "should send retry-after in seconds")
+	details := status.Convert(err).Details()
+	assert.Len(details, 1, "should send retry info")
+	_, ok := details[0].(*errdetails.RetryInfo)
+	assert.True(ok, "should send retry info")
+}
@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/dictyBase/modware-auth/internal/repository"
+	"github.com/lib/pq"
+)
+
+const clientColumns = `id, secret_hash, scopes, ttl_ms, created_at`
+
+func (ps *PostgresStorage) SetClient(c *repository.Client) error {
+	// a nil array is stored as null
+	scopes := c.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	_, err := ps.db.Exec(`
+		INSERT INTO auth_client (`+clientColumns+`)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO UPDATE
+		SET secret_hash = EXCLUDED.secret_hash, scopes = EXCLUDED.scopes,
+		ttl_ms = EXCLUDED.ttl_ms`,
+		c.ID, c.SecretHash, pq.Array(scopes), c.TTL.Milliseconds(), c.CreatedAt,
+	)
+	return err
+}
+
+func (ps *PostgresStorage) GetClient(id string) (*repository.Client, error) {
+	c, err := scanClient(ps.db.QueryRow(`
+		SELECT `+clientColumns+` FROM auth_client WHERE id = $1`,
+		id,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return c, repository.ErrClientNotFound
+	}
+	return c, err
+}
+
+// ListClients returns the clients ordered by their id
+func (ps *PostgresStorage) ListClients() ([]*repository.Client, error) {
+	cl := make([]*repository.Client, 0)
+	rows, err := ps.db.Query(`
+		SELECT ` + clientColumns + ` FROM auth_client ORDER BY id`,
+	)
+	if err != nil {
+		return cl, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		c, err := scanClient(rows)
+		if err != nil {
+			return cl, err
+		}
+		cl = append(cl, c)
+	}
+	return cl, rows.Err()
+}
+
+func (ps *PostgresStorage) DeleteClient(id string) error {
+	res, err := ps.db.Exec("DELETE FROM auth_client WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repository.ErrClientNotFound
+	}
+	return nil
+}
+
+func scanClient(row rowScanner) (*repository.Client, error) {
+	c := &repository.Client{}
+	var ttl int64
+	err := row.Scan(
+		&c.ID, &c.SecretHash, pq.Array(&c.Scopes), &ttl, &c.CreatedAt,
+	)
+	c.TTL = time.Duration(ttl) * time.Millisecond
+	return c, err
+}
@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// arbitrary key for the advisory lock that serializes migrations
+// across replicas
+const migrationLockID = 7425901
+
+type migration struct {
+	version int
+	name    string
+	stmt    string
+}
+
+// migrate applies all pending migrations in the order of their version
+// prefix and records them in the schema_migrations table
+func migrate(db *sql.DB) error {
+	ms, err := readMigrations()
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error in starting migration transaction %s", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("error in acquiring migration lock %s", err)
+	}
+	if _, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`,
+	); err != nil {
+		return fmt.Errorf("error in creating migration table %s", err)
+	}
+	for _, m := range ms {
+		var applied bool
+		err := tx.QueryRow(
+			"SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)",
+			m.version,
+		).Scan(&applied)
+		if err != nil {
+			return fmt.Errorf("error in checking migration %s %s", m.name, err)
+		}
+		if applied {
+			continue
+		}
+		if _, err := tx.Exec(m.stmt); err != nil {
+			return fmt.Errorf("error in applying migration %s %s", m.name, err)
+		}
+		if _, err := tx.Exec(
+			"INSERT INTO schema_migrations (version, name) VALUES ($1, $2)",
+			m.version, m.name,
+		); err != nil {
+			return fmt.Errorf("error in recording migration %s %s", m.name, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func readMigrations() ([]*migration, error) {
+	var ms []*migration
+	files, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return ms, err
+	}
+	for _, f := range files {
+		name := strings.TrimPrefix(f, "migrations/")
+		version, err := strconv.Atoi(strings.SplitN(name, "_", 2)[0])
+		if err != nil {
+			return ms, fmt.Errorf("invalid migration file name %s %s", name, err)
+		}
+		stmt, err := migrationFiles.ReadFile(f)
+		if err != nil {
+			return ms, fmt.Errorf("unable to read migration %s %s", name, err)
+		}
+		ms = append(ms, &migration{
+			version: version,
+			name:    name,
+			stmt:    string(stmt),
+		})
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].version < ms[j].version })
+	return ms, nil
+}
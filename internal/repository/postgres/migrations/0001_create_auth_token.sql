@@ -0,0 +1,7 @@
+CREATE TABLE auth_token (
+    token_key TEXT PRIMARY KEY,
+    token TEXT NOT NULL,
+    expires_at TIMESTAMPTZ
+);
+
+CREATE INDEX auth_token_expires_at_idx ON auth_token (expires_at);
@@ -0,0 +1,13 @@
+CREATE TABLE auth_session (
+    id TEXT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    provider TEXT NOT NULL,
+    client_ip TEXT NOT NULL,
+    user_agent TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    refreshed_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ
+);
+
+CREATE INDEX auth_session_user_id_idx ON auth_session (user_id);
+CREATE INDEX auth_session_expires_at_idx ON auth_session (expires_at);
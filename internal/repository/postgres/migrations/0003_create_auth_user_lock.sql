@@ -0,0 +1,4 @@
+CREATE TABLE auth_user_lock (
+    user_id BIGINT PRIMARY KEY,
+    expires_at TIMESTAMPTZ
+);
@@ -0,0 +1,8 @@
+CREATE TABLE auth_outbox (
+    id TEXT PRIMARY KEY,
+    subject TEXT NOT NULL,
+    payload BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX auth_outbox_created_at_idx ON auth_outbox (created_at, id);
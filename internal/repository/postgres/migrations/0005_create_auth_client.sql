@@ -0,0 +1,7 @@
+CREATE TABLE auth_client (
+    id TEXT PRIMARY KEY,
+    secret_hash TEXT NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    ttl_ms BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,3 @@
+ALTER TABLE auth_outbox
+    ADD COLUMN traceparent TEXT NOT NULL DEFAULT '',
+    ADD COLUMN tracestate TEXT NOT NULL DEFAULT '';
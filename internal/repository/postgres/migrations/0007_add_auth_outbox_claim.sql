@@ -0,0 +1,2 @@
+ALTER TABLE auth_outbox
+    ADD COLUMN claimed_until TIMESTAMPTZ;
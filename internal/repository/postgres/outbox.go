@@ -0,0 +1,87 @@
+package postgres
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/dictyBase/modware-auth/internal/repository"
+)
+
+// PendingEvents returns the events of the outbox ordered by their
+// creation time
+func (ps *PostgresStorage) PendingEvents(limit int) ([]*repository.OutboxEvent, error) {
+	el := make([]*repository.OutboxEvent, 0)
+	rows, err := ps.db.Query(`
+		SELECT id, subject, payload, created_at, traceparent, tracestate
+		FROM auth_outbox
+		ORDER BY created_at, id
+		LIMIT $1`,
+		limit,
+	)
+	if err != nil {
+		return el, err
+	}
+	return scanEvents(rows)
+}
+
+// ClaimEvents claims the pending events by setting the end of their lease,
+// the rows that are locked by the claim of another relay are skipped
+func (ps *PostgresStorage) ClaimEvents(limit int, lease time.Duration) ([]*repository.OutboxEvent, error) {
+	rows, err := ps.db.Query(`
+		UPDATE auth_outbox
+		SET claimed_until = now() + $2::bigint * interval '1 millisecond'
+		WHERE id IN (
+			SELECT id FROM auth_outbox
+			WHERE claimed_until IS NULL OR claimed_until <= now()
+			ORDER BY created_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, subject, payload, created_at, traceparent, tracestate`,
+		limit, expiry(lease),
+	)
+	if err != nil {
+		return make([]*repository.OutboxEvent, 0), err
+	}
+	el, err := scanEvents(rows)
+	if err != nil {
+		return el, err
+	}
+	// the updated rows are returned in no particular order
+	sort.SliceStable(el, func(i, j int) bool {
+		if el[i].CreatedAt.Equal(el[j].CreatedAt) {
+			return el[i].ID < el[j].ID
+		}
+		return el[i].CreatedAt.Before(el[j].CreatedAt)
+	})
+	return el, nil
+}
+
+func scanEvents(rows *sql.Rows) ([]*repository.OutboxEvent, error) {
+	el := make([]*repository.OutboxEvent, 0)
+	defer rows.Close()
+	for rows.Next() {
+		e := &repository.OutboxEvent{}
+		if err := rows.Scan(
+			&e.ID, &e.Subject, &e.Payload, &e.CreatedAt,
+			&e.TraceParent, &e.TraceState,
+		); err != nil {
+			return el, err
+		}
+		el = append(el, e)
+	}
+	return el, rows.Err()
+}
+
+func (ps *PostgresStorage) ReleaseEvent(id string) error {
+	_, err := ps.db.Exec(
+		"UPDATE auth_outbox SET claimed_until = NULL WHERE id = $1", id,
+	)
+	return err
+}
+
+func (ps *PostgresStorage) DeleteEvent(id string) error {
+	_, err := ps.db.Exec("DELETE FROM auth_outbox WHERE id = $1", id)
+	return err
+}
@@ -0,0 +1,191 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dictyBase/modware-auth/internal/repository"
+	_ "github.com/lib/pq" // registers the postgres driver
+)
+
+// PostgresStorage is a durable AuthRepository. Like the redis storage it
+// keeps only the digests of identities and tokens. Expired tokens are
+// never returned and are periodically removed by a background sweeper.
+type PostgresStorage struct {
+	db       *sql.DB
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+	closeErr error
+}
+
+// NewAuthRepo connects to the postgres database, applies the pending
+// schema migrations and starts the sweeper that removes expired tokens
+// at the given interval, no sweeper is started for an interval that is not
+// positive
+func NewAuthRepo(dsn string, sweep time.Duration) (repository.AuthRepository, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error opening postgres connection %s", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close() //nolint:errcheck
+		return nil, fmt.Errorf("error pinging postgres %s", err)
+	}
+	if err := migrate(db); err != nil {
+		db.Close() //nolint:errcheck
+		return nil, fmt.Errorf("error migrating postgres schema %s", err)
+	}
+	ps := &PostgresStorage{
+		db:   db,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if sweep <= 0 {
+		close(ps.done)
+		return ps, nil
+	}
+	go ps.sweep(sweep)
+	return ps, nil
+}
+
+// GetToken returns the digest of the token stored for the identity
+func (ps *PostgresStorage) GetToken(key string) (string, error) {
+	var val string
+	err := ps.db.QueryRow(`
+		SELECT token FROM auth_token
+		WHERE token_key = $1
+		AND (expires_at IS NULL OR expires_at > now())`,
+		repository.Digest(key),
+	).Scan(&val)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", repository.ErrTokenNotFound
+		}
+		return "", err
+	}
+	return val, nil
+}
+
+// SetToken stores the digest of the token for the identity, a zero
+// duration stores it without any expiration
+func (ps *PostgresStorage) SetToken(key, val string, ttl time.Duration) error {
+	_, err := ps.db.Exec(`
+		INSERT INTO auth_token (token_key, token, expires_at)
+		VALUES ($1, $2, now() + $3::bigint * interval '1 millisecond')
+		ON CONFLICT (token_key) DO UPDATE
+		SET token = EXCLUDED.token, expires_at = EXCLUDED.expires_at`,
+		repository.Digest(key),
+		repository.Digest(val),
+		expiry(ttl),
+	)
+	return err
+}
+
+func (ps *PostgresStorage) DeleteToken(key string) error {
+	res, err := ps.db.Exec(`
+		DELETE FROM auth_token
+		WHERE token_key = $1
+		AND (expires_at IS NULL OR expires_at > now())`,
+		repository.Digest(key),
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repository.ErrTokenNotFound
+	}
+	return nil
+}
+
+func (ps *PostgresStorage) HasToken(key string) (bool, error) {
+	var h bool
+	err := ps.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM auth_token
+			WHERE token_key = $1
+			AND (expires_at IS NULL OR expires_at > now())
+		)`,
+		repository.Digest(key),
+	).Scan(&h)
+	return h, err
+}
+
+func (ps *PostgresStorage) LockUser(userID int64, ttl time.Duration) error {
+	_, err := ps.db.Exec(`
+		INSERT INTO auth_user_lock (user_id, expires_at)
+		VALUES ($1, now() + $2::bigint * interval '1 millisecond')
+		ON CONFLICT (user_id) DO UPDATE
+		SET expires_at = EXCLUDED.expires_at`,
+		userID,
+		expiry(ttl),
+	)
+	return err
+}
+
+func (ps *PostgresStorage) IsUserLocked(userID int64) (bool, error) {
+	var h bool
+	err := ps.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM auth_user_lock
+			WHERE user_id = $1
+			AND (expires_at IS NULL OR expires_at > now())
+		)`,
+		userID,
+	).Scan(&h)
+	return h, err
+}
+
+func (ps *PostgresStorage) UnlockUser(userID int64) error {
+	_, err := ps.db.Exec("DELETE FROM auth_user_lock WHERE user_id = $1", userID)
+	return err
+}
+
+func (ps *PostgresStorage) Ping() error {
+	return ps.db.Ping()
+}
+
+// Close stops the sweeper and closes the database connections, further
+// calls return the result of the first one
+func (ps *PostgresStorage) Close() error {
+	ps.once.Do(func() {
+		close(ps.stop)
+		<-ps.done
+		ps.closeErr = ps.db.Close()
+	})
+	return ps.closeErr
+}
+
+func (ps *PostgresStorage) sweep(interval time.Duration) {
+	defer close(ps.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ps.stop:
+			return
+		case <-ticker.C:
+			// expired rows are already invisible to the readers, a failed
+			// sweep will simply be retried at the next tick
+			ps.db.Exec("DELETE FROM auth_token WHERE expires_at <= now()")     //nolint:errcheck
+			ps.db.Exec("DELETE FROM auth_session WHERE expires_at <= now()")   //nolint:errcheck
+			ps.db.Exec("DELETE FROM auth_user_lock WHERE expires_at <= now()") //nolint:errcheck
+		}
+	}
+}
+
+// expiry converts the duration to milliseconds, nil means no expiration
+func expiry(ttl time.Duration) *int64 {
+	if ttl <= 0 {
+		return nil
+	}
+	ms := ttl.Milliseconds()
+	return &ms
+}
@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dictyBase/modware-auth/internal/repository"
+	"github.com/dictyBase/modware-auth/internal/repository/repotest"
+	"github.com/stretchr/testify/assert"
+)
+
+// CheckPostgresEnv checks for the presence of the following
+// environment variables
+//
+//	POSTGRES_SERVICE_HOST
+//	POSTGRES_SERVICE_PORT
+//	POSTGRES_USER
+//	POSTGRES_PASSWORD
+//	POSTGRES_DB
+func CheckPostgresEnv() error {
+	envs := []string{
+		"POSTGRES_SERVICE_HOST",
+		"POSTGRES_SERVICE_PORT",
+		"POSTGRES_USER",
+		"POSTGRES_PASSWORD",
+		"POSTGRES_DB",
+	}
+	for _, e := range envs {
+		if len(os.Getenv(e)) == 0 {
+			return fmt.Errorf("env %s is not set", e)
+		}
+	}
+	return nil
+}
+
+func postgresDSN() string {
+	dsn := &url.URL{
+		Scheme: "postgres",
+		User: url.UserPassword(
+			os.Getenv("POSTGRES_USER"),
+			os.Getenv("POSTGRES_PASSWORD"),
+		),
+		Host: fmt.Sprintf(
+			"%s:%s",
+			os.Getenv("POSTGRES_SERVICE_HOST"),
+			os.Getenv("POSTGRES_SERVICE_PORT"),
+		),
+		Path:     os.Getenv("POSTGRES_DB"),
+		RawQuery: "sslmode=disable",
+	}
+	return dsn.String()
+}
+
+// newTestRepo connects to the postgres database given by the environment,
+// the test is skipped if the environment is not set. The caller is
+// responsible for closing the repository.
+func newTestRepo(t *testing.T, sweep time.Duration) repository.AuthRepository {
+	t.Helper()
+	if err := CheckPostgresEnv(); err != nil {
+		t.Skipf("skipping postgres test %s", err)
+	}
+	repo, err := NewAuthRepo(postgresDSN(), sweep)
+	if err != nil {
+		t.Fatalf("error connecting to postgres %s", err)
+	}
+	return repo
+}
+
+func TestAuthRepository(t *testing.T) {
+	repotest.Run(t, &repotest.Harness{
+		New: func(t *testing.T) repository.AuthRepository {
+			return newTestRepo(t, time.Minute)
+		},
+		Advance: time.Sleep,
+	})
+}
+
+func TestSweeper(t *testing.T) {
+	assert := assert.New(t)
+	repo := newTestRepo(t, 100*time.Millisecond)
+	defer repo.Close()
+	err := repo.SetToken("pennypacker", "kel varnsen", 200*time.Millisecond)
+	assert.NoError(err, "error in setting token")
+	lookup, err := repo.HasToken("pennypacker")
+	assert.NoError(err, "error finding token")
+	assert.True(lookup, "should find token before expiration")
+	time.Sleep(500 * time.Millisecond)
+	lookup, err = repo.HasToken("pennypacker")
+	assert.NoError(err, "error finding token")
+	assert.False(lookup, "should not find expired token")
+	var count int
+	err = repo.(*PostgresStorage).db.QueryRow(
+		"SELECT count(*) FROM auth_token WHERE token_key = $1",
+		repository.Digest("pennypacker"),
+	).Scan(&count)
+	assert.NoError(err, "error counting tokens")
+	assert.Zero(count, "should remove expired token by sweeper")
+}
+
+func TestReadMigrations(t *testing.T) {
+	assert := assert.New(t)
+	ms, err := readMigrations()
+	assert.NoError(err, "error reading migrations")
+	assert.NotEmpty(ms, "should embed the migration files")
+	for i, m := range ms {
+		assert.Equal(i+1, m.version, "should order migrations by version")
+	}
+}
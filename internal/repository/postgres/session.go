@@ -0,0 +1,239 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/dictyBase/modware-auth/internal/repository"
+)
+
+const sessionColumns = `
+	id, user_id, provider, client_ip, user_agent, created_at, refreshed_at`
+
+// SetSession stores the session, the digest of its refresh token and
+// adds the events to the outbox in a single transaction
+func (ps *PostgresStorage) SetSession(
+	sess *repository.Session,
+	token string,
+	ttl time.Duration,
+	events ...*repository.OutboxEvent,
+) error {
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+	_, err = tx.Exec(`
+		INSERT INTO auth_token (token_key, token, expires_at)
+		VALUES ($1, $2, now() + $3::bigint * interval '1 millisecond')
+		ON CONFLICT (token_key) DO UPDATE
+		SET token = EXCLUDED.token, expires_at = EXCLUDED.expires_at`,
+		repository.Digest(sess.ID),
+		repository.Digest(token),
+		expiry(ttl),
+	)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO auth_session (`+sessionColumns+`, expires_at)
+		VALUES (
+			$1, $2, $3, $4, $5, $6, $7,
+			now() + $8::bigint * interval '1 millisecond'
+		)
+		ON CONFLICT (id) DO UPDATE
+		SET refreshed_at = EXCLUDED.refreshed_at,
+		expires_at = EXCLUDED.expires_at`,
+		sess.ID, sess.UserID, sess.Provider, sess.ClientIP, sess.UserAgent,
+		sess.CreatedAt, sess.RefreshedAt, expiry(ttl),
+	)
+	if err != nil {
+		return err
+	}
+	if err := insertEvents(tx, events); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RefreshSession updates the refresh time and the expiry of the session
+// and replaces the digest of its refresh token, the session row is locked
+// by the update so a concurrent removal either waits for the refresh or
+// leaves nothing to update
+func (ps *PostgresStorage) RefreshSession(
+	sess *repository.Session,
+	token string,
+	ttl time.Duration,
+	events ...*repository.OutboxEvent,
+) error {
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+	res, err := tx.Exec(`
+		UPDATE auth_session
+		SET refreshed_at = $2,
+		expires_at = now() + $3::bigint * interval '1 millisecond'
+		WHERE id = $1
+		AND (expires_at IS NULL OR expires_at > now())`,
+		sess.ID, sess.RefreshedAt, expiry(ttl),
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repository.ErrSessionNotFound
+	}
+	_, err = tx.Exec(`
+		INSERT INTO auth_token (token_key, token, expires_at)
+		VALUES ($1, $2, now() + $3::bigint * interval '1 millisecond')
+		ON CONFLICT (token_key) DO UPDATE
+		SET token = EXCLUDED.token, expires_at = EXCLUDED.expires_at`,
+		repository.Digest(sess.ID),
+		repository.Digest(token),
+		expiry(ttl),
+	)
+	if err != nil {
+		return err
+	}
+	if err := insertEvents(tx, events); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertEvents adds the events to the outbox within the transaction
+func insertEvents(tx *sql.Tx, events []*repository.OutboxEvent) error {
+	for _, e := range events {
+		_, err := tx.Exec(`
+			INSERT INTO auth_outbox (
+				id, subject, payload, created_at, traceparent, tracestate
+			)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			e.ID, e.Subject, e.Payload, e.CreatedAt, e.TraceParent, e.TraceState,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ps *PostgresStorage) GetSession(id string) (*repository.Session, error) {
+	sess, err := scanSession(ps.db.QueryRow(`
+		SELECT `+sessionColumns+` FROM auth_session
+		WHERE id = $1
+		AND (expires_at IS NULL OR expires_at > now())`,
+		id,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return sess, repository.ErrSessionNotFound
+	}
+	return sess, err
+}
+
+// ListSessions returns the sessions of the user ordered by their creation time
+func (ps *PostgresStorage) ListSessions(userID int64) ([]*repository.Session, error) {
+	sl := make([]*repository.Session, 0)
+	rows, err := ps.db.Query(`
+		SELECT `+sessionColumns+` FROM auth_session
+		WHERE user_id = $1
+		AND (expires_at IS NULL OR expires_at > now())
+		ORDER BY created_at`,
+		userID,
+	)
+	if err != nil {
+		return sl, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		sess, err := scanSession(rows)
+		if err != nil {
+			return sl, err
+		}
+		sl = append(sl, sess)
+	}
+	return sl, rows.Err()
+}
+
+func (ps *PostgresStorage) DeleteSession(id string) error {
+	n, err := ps.deleteSessions(`
+		DELETE FROM auth_session
+		WHERE id = $1
+		AND (expires_at IS NULL OR expires_at > now())
+		RETURNING id`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repository.ErrSessionNotFound
+	}
+	return nil
+}
+
+func (ps *PostgresStorage) DeleteUserSessions(userID int64) (int, error) {
+	return ps.deleteSessions(`
+		DELETE FROM auth_session
+		WHERE user_id = $1
+		AND (expires_at IS NULL OR expires_at > now())
+		RETURNING id`,
+		userID,
+	)
+}
+
+// deleteSessions runs the delete statement, which has to return the ids of
+// the removed sessions, and removes their refresh tokens in the same
+// transaction
+func (ps *PostgresStorage) deleteSessions(stmt string, arg interface{}) (int, error) {
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+	rows, err := tx.Query(stmt, arg)
+	if err != nil {
+		return 0, err
+	}
+	var keys []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		keys = append(keys, repository.Digest(id))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	for _, k := range keys {
+		if _, err := tx.Exec(
+			"DELETE FROM auth_token WHERE token_key = $1", k,
+		); err != nil {
+			return 0, err
+		}
+	}
+	return len(keys), tx.Commit()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSession(row rowScanner) (*repository.Session, error) {
+	sess := &repository.Session{}
+	err := row.Scan(
+		&sess.ID, &sess.UserID, &sess.Provider, &sess.ClientIP,
+		&sess.UserAgent, &sess.CreatedAt, &sess.RefreshedAt,
+	)
+	return sess, err
+}
@@ -1,18 +1,28 @@
 package redis
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/dictyBase/modware-auth/internal/repository"
 	r "github.com/go-redis/redis/v7"
 )
 
+// maxSessionRetries is the number of attempts of a session write that
+// conflicts with other writes of the session
+const maxSessionRetries = 3
+
+// RedisStorage stores the SHA-256 digest of tokens under namespaced keys
+// that are derived from the digest of the identity
 type RedisStorage struct {
-	client *r.Client
+	client    *r.Client
+	namespace string
 }
 
-func NewAuthRepo(redisAddress string) (repository.AuthRepository, error) {
+func NewAuthRepo(redisAddress, namespace string) (repository.AuthRepository, error) {
 	client := r.NewClient(&r.Options{
 		Addr: redisAddress,
 	})
@@ -22,34 +32,43 @@ func NewAuthRepo(redisAddress string) (repository.AuthRepository, error) {
 		return nil, fmt.Errorf("error pinging redis %s", err)
 	}
 
-	return &RedisStorage{client: client}, nil
+	return &RedisStorage{client: client, namespace: namespace}, nil
 }
 
+// GetToken returns the digest of the token stored for the identity
 func (rs *RedisStorage) GetToken(key string) (string, error) {
-	val, err := rs.client.Get(key).Result()
+	val, err := rs.client.Get(rs.tokenKey(key)).Result()
 	if err != nil {
+		if errors.Is(err, r.Nil) {
+			return "", repository.ErrTokenNotFound
+		}
 		return "", err
 	}
 	return val, err
 }
 
+// SetToken stores the digest of the token for the identity
 func (rs *RedisStorage) SetToken(key, val string, time time.Duration) error {
-	return rs.client.Set(key, val, time).Err()
+	return rs.client.Set(
+		rs.tokenKey(key),
+		repository.Digest(val),
+		time,
+	).Err()
 }
 
 func (rs *RedisStorage) DeleteToken(key string) error {
-	val, err := rs.client.Del(key).Result()
+	val, err := rs.client.Del(rs.tokenKey(key)).Result()
 	if err != nil {
 		return err
 	}
 	if val == 0 {
-		return fmt.Errorf("token does not exist")
+		return repository.ErrTokenNotFound
 	}
 	return nil
 }
 
 func (rs *RedisStorage) HasToken(key string) (bool, error) {
-	h, err := rs.client.Exists(key).Result()
+	h, err := rs.client.Exists(rs.tokenKey(key)).Result()
 	if err != nil {
 		return false, err
 	}
@@ -58,3 +77,397 @@ func (rs *RedisStorage) HasToken(key string) (bool, error) {
 	}
 	return true, nil
 }
+
+// SetSession stores the session, the digest of its refresh token, indexes
+// the session for its user and adds the events to the outbox in a single
+// transaction
+func (rs *RedisStorage) SetSession(
+	sess *repository.Session,
+	token string,
+	ttl time.Duration,
+	events ...*repository.OutboxEvent,
+) error {
+	return rs.writeSession(sess, token, ttl, false, events)
+}
+
+// RefreshSession stores the session like SetSession as long as the session
+// exists, the transaction is aborted if the session is removed meanwhile
+func (rs *RedisStorage) RefreshSession(
+	sess *repository.Session,
+	token string,
+	ttl time.Duration,
+	events ...*repository.OutboxEvent,
+) error {
+	return rs.writeSession(sess, token, ttl, true, events)
+}
+
+// writeSession writes the session in a transaction that watches the
+// session key, a refresh requires the session to exist. The index of the
+// user expires along with the longest lived of its sessions.
+func (rs *RedisStorage) writeSession(
+	sess *repository.Session,
+	token string,
+	ttl time.Duration,
+	refresh bool,
+	events []*repository.OutboxEvent,
+) error {
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return fmt.Errorf("error in encoding session %s", err)
+	}
+	encoded := make(map[string]interface{}, len(events))
+	for _, e := range events {
+		ed, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("error in encoding event %s", err)
+		}
+		encoded[e.ID] = ed
+	}
+	sk, uk := rs.sessionKey(sess.ID), rs.userKey(sess.UserID)
+	write := func(tx *r.Tx) error {
+		if refresh {
+			n, err := tx.Exists(sk).Result()
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return repository.ErrSessionNotFound
+			}
+		}
+		cur, err := tx.TTL(uk).Result()
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(func(pipe r.Pipeliner) error {
+			pipe.Set(rs.tokenKey(sess.ID), repository.Digest(token), ttl)
+			pipe.Set(sk, data, ttl)
+			pipe.SAdd(uk, sess.ID)
+			switch {
+			case ttl <= 0:
+				pipe.Persist(uk)
+			case cur < ttl:
+				// a missing index or one without expiry gets the expiry
+				// of the session too
+				pipe.Expire(uk, ttl)
+			}
+			for _, e := range events {
+				pipe.ZAdd(rs.outboxKey(), &r.Z{
+					Score:  float64(e.CreatedAt.UnixNano()),
+					Member: e.ID,
+				})
+			}
+			if len(encoded) > 0 {
+				pipe.HSet(rs.outboxEventsKey(), encoded)
+			}
+			return nil
+		})
+		return err
+	}
+	// a failed transaction means the session was changed meanwhile, the
+	// retry sees whether it still exists
+	for i := 0; i < maxSessionRetries; i++ {
+		err = rs.client.Watch(write, sk)
+		if !errors.Is(err, r.TxFailedErr) {
+			return err
+		}
+	}
+	return err
+}
+
+func (rs *RedisStorage) GetSession(id string) (*repository.Session, error) {
+	data, err := rs.client.Get(rs.sessionKey(id)).Bytes()
+	if err != nil {
+		if errors.Is(err, r.Nil) {
+			return nil, repository.ErrSessionNotFound
+		}
+		return nil, err
+	}
+	return decodeSession(data)
+}
+
+// ListSessions returns the sessions of the user ordered by their creation
+// time. Sessions that have expired are removed from the index of the user.
+func (rs *RedisStorage) ListSessions(userID int64) ([]*repository.Session, error) {
+	sl := make([]*repository.Session, 0)
+	ids, err := rs.client.SMembers(rs.userKey(userID)).Result()
+	if err != nil || len(ids) == 0 {
+		return sl, err
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = rs.sessionKey(id)
+	}
+	vals, err := rs.client.MGet(keys...).Result()
+	if err != nil {
+		return sl, err
+	}
+	var expired []interface{}
+	for i, v := range vals {
+		data, ok := v.(string)
+		if !ok {
+			expired = append(expired, ids[i])
+			continue
+		}
+		sess, err := decodeSession([]byte(data))
+		if err != nil {
+			return sl, err
+		}
+		sl = append(sl, sess)
+	}
+	if len(expired) > 0 {
+		if err := rs.client.SRem(rs.userKey(userID), expired...).Err(); err != nil {
+			return sl, err
+		}
+	}
+	sort.Slice(sl, func(i, j int) bool {
+		return sl[i].CreatedAt.Before(sl[j].CreatedAt)
+	})
+	return sl, nil
+}
+
+func (rs *RedisStorage) DeleteSession(id string) error {
+	sess, err := rs.GetSession(id)
+	if err != nil {
+		return err
+	}
+	_, err = rs.client.TxPipelined(func(pipe r.Pipeliner) error {
+		pipe.Del(rs.tokenKey(id), rs.sessionKey(id))
+		pipe.SRem(rs.userKey(sess.UserID), id)
+		return nil
+	})
+	return err
+}
+
+// DeleteUserSessions removes the sessions in the index of the user. Only
+// the ids that were read are removed from the index, so a session that is
+// added meanwhile is kept along with its entry.
+func (rs *RedisStorage) DeleteUserSessions(userID int64) (int, error) {
+	ids, err := rs.client.SMembers(rs.userKey(userID)).Result()
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	cmds := make([]*r.IntCmd, len(ids))
+	members := make([]interface{}, len(ids))
+	_, err = rs.client.TxPipelined(func(pipe r.Pipeliner) error {
+		for i, id := range ids {
+			pipe.Del(rs.tokenKey(id))
+			cmds[i] = pipe.Del(rs.sessionKey(id))
+			members[i] = id
+		}
+		pipe.SRem(rs.userKey(userID), members...)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	for _, cmd := range cmds {
+		count += int(cmd.Val())
+	}
+	return count, nil
+}
+
+func (rs *RedisStorage) LockUser(userID int64, ttl time.Duration) error {
+	return rs.client.Set(rs.lockKey(userID), time.Now().Unix(), ttl).Err()
+}
+
+func (rs *RedisStorage) IsUserLocked(userID int64) (bool, error) {
+	h, err := rs.client.Exists(rs.lockKey(userID)).Result()
+	if err != nil {
+		return false, err
+	}
+	return h > 0, nil
+}
+
+func (rs *RedisStorage) UnlockUser(userID int64) error {
+	return rs.client.Del(rs.lockKey(userID)).Err()
+}
+
+func (rs *RedisStorage) Ping() error {
+	return rs.client.Ping().Err()
+}
+
+func (rs *RedisStorage) Close() error {
+	return rs.client.Close()
+}
+
+// PendingEvents returns the events of the outbox ordered by their
+// creation time
+func (rs *RedisStorage) PendingEvents(limit int) ([]*repository.OutboxEvent, error) {
+	ids, err := rs.client.ZRange(rs.outboxKey(), 0, int64(limit-1)).Result()
+	if err != nil {
+		return make([]*repository.OutboxEvent, 0), err
+	}
+	return rs.outboxEvents(ids)
+}
+
+// ClaimEvents returns the pending events in the order of their creation
+// that could be claimed, an event is claimed by a key that expires after
+// the lease
+func (rs *RedisStorage) ClaimEvents(limit int, lease time.Duration) ([]*repository.OutboxEvent, error) {
+	var claimed []string
+	for start := int64(0); len(claimed) < limit; start += int64(limit) {
+		ids, err := rs.client.ZRange(rs.outboxKey(), start, start+int64(limit)-1).Result()
+		if err != nil {
+			return make([]*repository.OutboxEvent, 0), err
+		}
+		for _, id := range ids {
+			ok, err := rs.client.SetNX(rs.outboxClaimKey(id), 1, lease).Result()
+			if err != nil {
+				return make([]*repository.OutboxEvent, 0), err
+			}
+			if ok {
+				claimed = append(claimed, id)
+			}
+			if len(claimed) == limit {
+				break
+			}
+		}
+		if len(ids) < limit {
+			break
+		}
+	}
+	return rs.outboxEvents(claimed)
+}
+
+// outboxEvents returns the events of the ids in their order, the ids of
+// events that were deleted meanwhile are skipped
+func (rs *RedisStorage) outboxEvents(ids []string) ([]*repository.OutboxEvent, error) {
+	el := make([]*repository.OutboxEvent, 0)
+	if len(ids) == 0 {
+		return el, nil
+	}
+	vals, err := rs.client.HMGet(rs.outboxEventsKey(), ids...).Result()
+	if err != nil {
+		return el, err
+	}
+	for _, v := range vals {
+		data, ok := v.(string)
+		if !ok {
+			continue
+		}
+		e := &repository.OutboxEvent{}
+		if err := json.Unmarshal([]byte(data), e); err != nil {
+			return el, fmt.Errorf("error in decoding event %s", err)
+		}
+		el = append(el, e)
+	}
+	return el, nil
+}
+
+func (rs *RedisStorage) ReleaseEvent(id string) error {
+	return rs.client.Del(rs.outboxClaimKey(id)).Err()
+}
+
+func (rs *RedisStorage) DeleteEvent(id string) error {
+	_, err := rs.client.TxPipelined(func(pipe r.Pipeliner) error {
+		pipe.ZRem(rs.outboxKey(), id)
+		pipe.HDel(rs.outboxEventsKey(), id)
+		pipe.Del(rs.outboxClaimKey(id))
+		return nil
+	})
+	return err
+}
+
+func (rs *RedisStorage) SetClient(c *repository.Client) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("error in encoding client %s", err)
+	}
+	return rs.client.HSet(rs.clientsKey(), c.ID, data).Err()
+}
+
+func (rs *RedisStorage) GetClient(id string) (*repository.Client, error) {
+	data, err := rs.client.HGet(rs.clientsKey(), id).Bytes()
+	if err != nil {
+		if errors.Is(err, r.Nil) {
+			return nil, repository.ErrClientNotFound
+		}
+		return nil, err
+	}
+	return decodeClient(data)
+}
+
+func (rs *RedisStorage) ListClients() ([]*repository.Client, error) {
+	cl := make([]*repository.Client, 0)
+	vals, err := rs.client.HGetAll(rs.clientsKey()).Result()
+	if err != nil {
+		return cl, err
+	}
+	for _, v := range vals {
+		c, err := decodeClient([]byte(v))
+		if err != nil {
+			return cl, err
+		}
+		cl = append(cl, c)
+	}
+	sort.Slice(cl, func(i, j int) bool { return cl[i].ID < cl[j].ID })
+	return cl, nil
+}
+
+func (rs *RedisStorage) DeleteClient(id string) error {
+	n, err := rs.client.HDel(rs.clientsKey(), id).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repository.ErrClientNotFound
+	}
+	return nil
+}
+
+func (rs *RedisStorage) tokenKey(identity string) string {
+	return fmt.Sprintf(
+		"%s:token:%s",
+		rs.namespace,
+		repository.Digest(identity),
+	)
+}
+
+func (rs *RedisStorage) sessionKey(id string) string {
+	return fmt.Sprintf("%s:session:%s", rs.namespace, id)
+}
+
+func (rs *RedisStorage) userKey(userID int64) string {
+	return fmt.Sprintf("%s:user:%d:sessions", rs.namespace, userID)
+}
+
+// outboxKey orders the ids of the outbox events by their creation time
+func (rs *RedisStorage) outboxKey() string {
+	return fmt.Sprintf("%s:outbox", rs.namespace)
+}
+
+// outboxEventsKey maps the ids of the outbox events to the events
+func (rs *RedisStorage) outboxEventsKey() string {
+	return fmt.Sprintf("%s:outbox:events", rs.namespace)
+}
+
+// outboxClaimKey marks an outbox event as claimed by a relay
+func (rs *RedisStorage) outboxClaimKey(id string) string {
+	return fmt.Sprintf("%s:outbox:claim:%s", rs.namespace, id)
+}
+
+func (rs *RedisStorage) lockKey(userID int64) string {
+	return fmt.Sprintf("%s:user:%d:lock", rs.namespace, userID)
+}
+
+// clientsKey maps the ids of the clients to the clients
+func (rs *RedisStorage) clientsKey() string {
+	return fmt.Sprintf("%s:clients", rs.namespace)
+}
+
+func decodeSession(data []byte) (*repository.Session, error) {
+	sess := &repository.Session{}
+	if err := json.Unmarshal(data, sess); err != nil {
+		return sess, fmt.Errorf("error in decoding session %s", err)
+	}
+	return sess, nil
+}
+
+func decodeClient(data []byte) (*repository.Client, error) {
+	c := &repository.Client{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return c, fmt.Errorf("error in decoding client %s", err)
+	}
+	return c, nil
+}
@@ -2,19 +2,17 @@ package redis
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"testing"
+	"time"
 
-	r "github.com/go-redis/redis/v7"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dictyBase/modware-auth/internal/repository"
+	"github.com/dictyBase/modware-auth/internal/repository/repotest"
 	"github.com/stretchr/testify/assert"
 )
 
-var redisAddr = fmt.Sprintf(
-	"%s:%s",
-	os.Getenv("REDIS_MASTER_SERVICE_HOST"),
-	os.Getenv("REDIS_MASTER_SERVICE_PORT"),
-)
+const testNamespace = "modware-auth-test"
 
 // CheckRedisEnv checks for the presence of the following
 // environment variables
@@ -34,79 +32,94 @@ func CheckRedisEnv() error {
 	return nil
 }
 
-type TestRedis struct {
-	client *r.Client
-}
-
-// NewTestRedisFromEnv is a constructor for TestRedis instance.
-// It expects the following environmental variables to be set.
-//
-//	REDIS_MASTER_SERVICE_HOST
-//	REDIS_MASTER_SERVICE_PORT
-func NewTestRedisFromEnv() (*TestRedis, error) {
-	tr := new(TestRedis)
-	if err := CheckRedisEnv(); err != nil {
-		return tr, err
-	}
-	tr.client = r.NewClient(&r.Options{
-		Addr: redisAddr,
-	})
-	err := tr.client.Ping().Err()
-	if err != nil {
-		return nil, fmt.Errorf("error pinging redis %s", err)
-	}
-	return tr, nil
-}
-
-func TestMain(m *testing.M) {
-	_, err := NewTestRedisFromEnv()
+// newMiniRedis starts an in-process redis server that is stopped
+// at the end of the test
+func newMiniRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr, err := miniredis.Run()
 	if err != nil {
-		log.Fatalf("unable to construct new TestRedisFromEnv instance %s", err)
+		t.Fatalf("unable to start miniredis %s", err)
 	}
-	os.Exit(m.Run())
+	t.Cleanup(mr.Close)
+	return mr
 }
 
-func TestSetToken(t *testing.T) {
-	assert := assert.New(t)
-	repo, err := NewAuthRepo(redisAddr)
-	assert.NoError(err, "error connecting to redis")
-	err = repo.SetToken("art", "vandelay", 0)
-	assert.NoError(err, "error in setting token")
+func TestAuthRepository(t *testing.T) {
+	mr := newMiniRedis(t)
+	repotest.Run(t, &repotest.Harness{
+		New: func(t *testing.T) repository.AuthRepository {
+			repo, err := NewAuthRepo(mr.Addr(), testNamespace)
+			if err != nil {
+				t.Fatalf("error connecting to redis %s", err)
+			}
+			return repo
+		},
+		Advance: mr.FastForward,
+	})
 }
 
-func TestGetToken(t *testing.T) {
-	assert := assert.New(t)
-	repo, err := NewAuthRepo(redisAddr)
-	assert.NoError(err, "error connecting to redis")
-	err = repo.SetToken("art", "vandelay", 0)
-	assert.NoError(err, "error in setting token")
-	token, err := repo.GetToken("art")
-	assert.NoError(err, "error getting token")
-	assert.Equal(token, "vandelay", "should retrieve correct value")
+// TestAuthRepositoryServer runs the suite against the redis server given
+// by the environment, it is skipped if the environment is not set
+func TestAuthRepositoryServer(t *testing.T) {
+	if err := CheckRedisEnv(); err != nil {
+		t.Skipf("skipping redis server test %s", err)
+	}
+	redisAddr := fmt.Sprintf(
+		"%s:%s",
+		os.Getenv("REDIS_MASTER_SERVICE_HOST"),
+		os.Getenv("REDIS_MASTER_SERVICE_PORT"),
+	)
+	repotest.Run(t, &repotest.Harness{
+		New: func(t *testing.T) repository.AuthRepository {
+			repo, err := NewAuthRepo(redisAddr, testNamespace)
+			if err != nil {
+				t.Fatalf("error connecting to redis %s", err)
+			}
+			return repo
+		},
+		Advance: time.Sleep,
+	})
 }
 
-func TestDeleteToken(t *testing.T) {
+func TestTokenKey(t *testing.T) {
 	assert := assert.New(t)
-	repo, err := NewAuthRepo(redisAddr)
+	mr := newMiniRedis(t)
+	repo, err := NewAuthRepo(mr.Addr(), testNamespace)
 	assert.NoError(err, "error connecting to redis")
-	err = repo.SetToken("art", "vandelay", 0)
+	defer repo.Close()
+	err = repo.SetToken("art@vandelay.com", "industries", 0)
 	assert.NoError(err, "error in setting token")
-	err = repo.DeleteToken("art")
-	assert.NoError(err, "error in deleting token")
-	err = repo.DeleteToken("cheever")
-	assert.Error(err, "error deleting nonexistent token")
+	key := fmt.Sprintf(
+		"%s:token:%s",
+		testNamespace,
+		repository.Digest("art@vandelay.com"),
+	)
+	val, err := mr.Get(key)
+	assert.NoError(err, "should store token under namespaced digest key")
+	assert.Equal(
+		val,
+		repository.Digest("industries"),
+		"should not store token in plain text",
+	)
+	assert.False(
+		mr.Exists("art@vandelay.com"),
+		"should not store token under raw identity",
+	)
 }
 
-func TestHasToken(t *testing.T) {
+func TestUserSessionsExpiry(t *testing.T) {
 	assert := assert.New(t)
-	repo, err := NewAuthRepo(redisAddr)
+	mr := newMiniRedis(t)
+	repo, err := NewAuthRepo(mr.Addr(), testNamespace)
 	assert.NoError(err, "error connecting to redis")
-	err = repo.SetToken("art", "vandelay", 0)
-	assert.NoError(err, "error in setting token")
-	lookup, err := repo.HasToken("art")
-	assert.NoError(err, "error finding token")
-	assert.True(lookup, "should find previously set token")
-	badLookup, err := repo.HasToken("obrien-murphy")
-	assert.NoError(err, "error finding token ")
-	assert.False(badLookup, "should not find random token")
+	defer repo.Close()
+	now := time.Now()
+	long := &repository.Session{ID: "long", UserID: 7, CreatedAt: now, RefreshedAt: now}
+	short := &repository.Session{ID: "short", UserID: 7, CreatedAt: now, RefreshedAt: now}
+	assert.NoError(repo.SetSession(long, "vandelay", time.Hour), "error in setting session")
+	assert.NoError(repo.SetSession(short, "vandelay", time.Minute), "error in setting session")
+	key := fmt.Sprintf("%s:user:7:sessions", testNamespace)
+	assert.Equal(time.Hour, mr.TTL(key), "should expire index with longest session")
+	mr.FastForward(2 * time.Hour)
+	assert.False(mr.Exists(key), "should remove index of expired sessions")
 }
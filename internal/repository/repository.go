@@ -1,10 +1,135 @@
 package repository
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrTokenNotFound is returned when no token is stored for the given key
+	ErrTokenNotFound = errors.New("repository: token not found")
+	// ErrSessionNotFound is returned when no session exists with the given id
+	ErrSessionNotFound = errors.New("repository: session not found")
+	// ErrClientNotFound is returned when no client exists with the given id
+	ErrClientNotFound = errors.New("repository: client not found")
+)
+
+// Session is the metadata of a login, it is kept for as long as
+// its refresh token is valid
+type Session struct {
+	ID          string    `json:"id"`
+	UserID      int64     `json:"user_id"`
+	Provider    string    `json:"provider"`
+	ClientIP    string    `json:"client_ip"`
+	UserAgent   string    `json:"user_agent"`
+	CreatedAt   time.Time `json:"created_at"`
+	RefreshedAt time.Time `json:"refreshed_at"`
+}
+
+// Client is a confidential client, such as a batch loader, that obtains
+// access tokens with its own credentials instead of a login
+type Client struct {
+	ID string `json:"id"`
+	// SecretHash is the bcrypt hash of the secret of the client
+	SecretHash string `json:"secret_hash"`
+	// Scopes are the scopes that the client may request
+	Scopes []string `json:"scopes"`
+	// TTL is the lifetime of the access tokens of the client
+	TTL       time.Duration `json:"ttl"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// ClientRegistry holds the confidential clients
+type ClientRegistry interface {
+	// GetClient returns the client with the given id
+	GetClient(string) (*Client, error)
+}
+
+// ClientStore manages the confidential clients
+type ClientStore interface {
+	ClientRegistry
+	// SetClient stores the client, an existing client with the same id is
+	// replaced
+	SetClient(*Client) error
+	// ListClients returns all clients ordered by their id
+	ListClients() ([]*Client, error)
+	// DeleteClient removes the client
+	DeleteClient(string) error
+}
+
+// OutboxEvent is an encoded event that is stored along with a session and
+// relayed to the messaging server afterwards
+type OutboxEvent struct {
+	ID        string    `json:"id"`
+	Subject   string    `json:"subject"`
+	Payload   []byte    `json:"payload"`
+	CreatedAt time.Time `json:"created_at"`
+	// TraceParent and TraceState are the W3C trace context of the request
+	// that created the event, they are empty for an untraced request
+	TraceParent string `json:"traceparent,omitempty"`
+	TraceState  string `json:"tracestate,omitempty"`
+}
+
+// Outbox holds the events that are yet to be relayed
+type Outbox interface {
+	// PendingEvents returns up to the given number of events in the
+	// order of their creation
+	PendingEvents(int) ([]*OutboxEvent, error)
+	// ClaimEvents returns up to the given number of pending events that
+	// are not claimed by another relay, in the order of their creation,
+	// and claims them for the given time
+	ClaimEvents(int, time.Duration) ([]*OutboxEvent, error)
+	// ReleaseEvent removes the claim of an event that was not relayed, so
+	// that it is claimed again by the next relay
+	ReleaseEvent(string) error
+	// DeleteEvent removes a relayed event, removing an event that does
+	// not exist is not an error
+	DeleteEvent(string) error
+}
 
 type AuthRepository interface {
+	Outbox
+	ClientStore
 	GetToken(string) (string, error)
 	SetToken(string, string, time.Duration) error
 	DeleteToken(string) error
 	HasToken(string) (bool, error)
+	// SetSession stores the session along with the digest of its refresh
+	// token, which is then available through GetToken with the session id.
+	// The events are added to the outbox in the same transaction.
+	SetSession(*Session, string, time.Duration, ...*OutboxEvent) error
+	// RefreshSession replaces the session and the digest of its refresh
+	// token like SetSession but only while the session exists, it returns
+	// ErrSessionNotFound for a session that has been removed or expired
+	RefreshSession(*Session, string, time.Duration, ...*OutboxEvent) error
+	// GetSession returns the session with the given id
+	GetSession(string) (*Session, error)
+	// ListSessions returns all active sessions of the user
+	ListSessions(int64) ([]*Session, error)
+	// DeleteSession removes the session along with its refresh token
+	DeleteSession(string) error
+	// DeleteUserSessions removes all sessions of the user and returns
+	// the number of removed sessions
+	DeleteUserSessions(int64) (int, error)
+	// LockUser locks the account of the user for the given duration, a
+	// zero duration locks it until it is unlocked
+	LockUser(int64, time.Duration) error
+	// IsUserLocked reports whether the account of the user is locked
+	IsUserLocked(int64) (bool, error)
+	// UnlockUser removes the lock of the account, unlocking an account
+	// that is not locked is not an error
+	UnlockUser(int64) error
+	// Ping reports whether the storage is reachable
+	Ping() error
+	// Close releases the resources held by the repository
+	Close() error
+}
+
+// Digest returns the hex encoded SHA-256 digest of the given value. It is
+// used for keeping identities and tokens out of the storage in plain text.
+func Digest(val string) string {
+	sum := sha256.Sum256([]byte(val))
+	return hex.EncodeToString(sum[:])
 }
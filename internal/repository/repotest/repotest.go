@@ -0,0 +1,669 @@
+// Package repotest provides the conformance test suite that every
+// AuthRepository implementation is expected to pass
+package repotest
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dictyBase/modware-auth/internal/repository"
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/assert"
+)
+
+const writers = 25
+
+// Harness provides the repository under test to the suite
+type Harness struct {
+	// New returns a connected repository, it is closed by the suite
+	New func(t *testing.T) repository.AuthRepository
+	// Advance moves the clock of the repository forward, expired
+	// tokens should no longer be visible afterwards
+	Advance func(d time.Duration)
+}
+
+// Run runs the conformance suite against the repository given by the harness.
+// Every test uses its own keys, so the backend is not required to be empty.
+func Run(t *testing.T, h *Harness) {
+	t.Helper()
+	tests := map[string]func(*testing.T, repository.AuthRepository, *Harness){
+		"SetGetToken":        testSetGetToken,
+		"HasToken":           testHasToken,
+		"DeleteToken":        testDeleteToken,
+		"MissingToken":       testMissingToken,
+		"TokenExpiry":        testTokenExpiry,
+		"ConcurrentWriters":  testConcurrentWriters,
+		"SetGetSession":      testSetGetSession,
+		"ListSessions":       testListSessions,
+		"DeleteSession":      testDeleteSession,
+		"RefreshSession":     testRefreshSession,
+		"DeleteUserSessions": testDeleteUserSessions,
+		"ConcurrentRevoke":   testConcurrentRevoke,
+		"SessionExpiry":      testSessionExpiry,
+		"LockUser":           testLockUser,
+		"Outbox":             testOutbox,
+		"ClaimEvents":        testClaimEvents,
+		"Clients":            testClients,
+		"Ping":               testPing,
+		"Close":              testClose,
+	}
+	for name, fn := range tests {
+		fn := fn
+		t.Run(name, func(t *testing.T) {
+			repo := h.New(t)
+			defer repo.Close() //nolint:errcheck
+			fn(t, repo, h)
+		})
+	}
+}
+
+func key(prefix string) string {
+	return fmt.Sprintf("%s-%s", prefix, xid.New().String())
+}
+
+func testSetGetToken(t *testing.T, repo repository.AuthRepository, _ *Harness) {
+	assert := assert.New(t)
+	k := key("art")
+	assert.NoError(repo.SetToken(k, "vandelay", 0), "error in setting token")
+	token, err := repo.GetToken(k)
+	assert.NoError(err, "error getting token")
+	assert.Equal(
+		repository.Digest("vandelay"),
+		token,
+		"should retrieve digest of the token",
+	)
+	assert.NoError(
+		repo.SetToken(k, "industries", time.Hour),
+		"error in overwriting token",
+	)
+	token, err = repo.GetToken(k)
+	assert.NoError(err, "error getting token")
+	assert.Equal(
+		repository.Digest("industries"),
+		token,
+		"should retrieve digest of the overwritten token",
+	)
+}
+
+func testHasToken(t *testing.T, repo repository.AuthRepository, _ *Harness) {
+	assert := assert.New(t)
+	k := key("art")
+	assert.NoError(repo.SetToken(k, "vandelay", 0), "error in setting token")
+	lookup, err := repo.HasToken(k)
+	assert.NoError(err, "error finding token")
+	assert.True(lookup, "should find previously set token")
+	badLookup, err := repo.HasToken(key("obrien-murphy"))
+	assert.NoError(err, "error finding token")
+	assert.False(badLookup, "should not find random token")
+}
+
+func testDeleteToken(t *testing.T, repo repository.AuthRepository, _ *Harness) {
+	assert := assert.New(t)
+	k := key("art")
+	assert.NoError(repo.SetToken(k, "vandelay", 0), "error in setting token")
+	assert.NoError(repo.DeleteToken(k), "error in deleting token")
+	lookup, err := repo.HasToken(k)
+	assert.NoError(err, "error finding token")
+	assert.False(lookup, "should not find deleted token")
+	assert.ErrorIs(
+		repo.DeleteToken(k),
+		repository.ErrTokenNotFound,
+		"should return not found error for deleting twice",
+	)
+}
+
+func testMissingToken(t *testing.T, repo repository.AuthRepository, _ *Harness) {
+	assert := assert.New(t)
+	_, err := repo.GetToken(key("cheever"))
+	assert.ErrorIs(
+		err,
+		repository.ErrTokenNotFound,
+		"should return not found error for missing token",
+	)
+	assert.ErrorIs(
+		repo.DeleteToken(key("cheever")),
+		repository.ErrTokenNotFound,
+		"should return not found error for deleting missing token",
+	)
+}
+
+func testTokenExpiry(t *testing.T, repo repository.AuthRepository, h *Harness) {
+	assert := assert.New(t)
+	short, long := key("pennypacker"), key("varnsen")
+	assert.NoError(
+		repo.SetToken(short, "kel", time.Second),
+		"error in setting expiring token",
+	)
+	assert.NoError(repo.SetToken(long, "kel", 0), "error in setting token")
+	lookup, err := repo.HasToken(short)
+	assert.NoError(err, "error finding token")
+	assert.True(lookup, "should find token before expiration")
+	h.Advance(2 * time.Second)
+	lookup, err = repo.HasToken(short)
+	assert.NoError(err, "error finding token")
+	assert.False(lookup, "should not find expired token")
+	_, err = repo.GetToken(short)
+	assert.ErrorIs(
+		err,
+		repository.ErrTokenNotFound,
+		"should return not found error for expired token",
+	)
+	lookup, err = repo.HasToken(long)
+	assert.NoError(err, "error finding token")
+	assert.True(lookup, "should keep token without expiration")
+}
+
+func testConcurrentWriters(t *testing.T, repo repository.AuthRepository, _ *Harness) {
+	assert := assert.New(t)
+	shared := key("shared")
+	keys := make([]string, writers)
+	errs := make(chan error, writers*2)
+	var wg sync.WaitGroup
+	for i := range keys {
+		keys[i] = key("writer")
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- repo.SetToken(keys[i], fmt.Sprintf("token-%d", i), time.Hour)
+			errs <- repo.SetToken(shared, fmt.Sprintf("token-%d", i), time.Hour)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(err, "error in concurrent write")
+	}
+	for i, k := range keys {
+		token, err := repo.GetToken(k)
+		assert.NoError(err, "error getting token")
+		assert.Equal(
+			repository.Digest(fmt.Sprintf("token-%d", i)),
+			token,
+			"should keep every concurrently written token",
+		)
+	}
+	token, err := repo.GetToken(shared)
+	assert.NoError(err, "error getting shared token")
+	assert.True(
+		isWrittenToken(token),
+		"should keep one of the concurrently written shared tokens",
+	)
+}
+
+func isWrittenToken(digest string) bool {
+	for i := 0; i < writers; i++ {
+		if digest == repository.Digest(fmt.Sprintf("token-%d", i)) {
+			return true
+		}
+	}
+	return false
+}
+
+func session(userID int64) *repository.Session {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	return &repository.Session{
+		ID:          xid.New().String(),
+		UserID:      userID,
+		Provider:    "google",
+		ClientIP:    "10.0.0.1",
+		UserAgent:   "vandelay-browser/1.0",
+		CreatedAt:   now,
+		RefreshedAt: now,
+	}
+}
+
+func testSetGetSession(t *testing.T, repo repository.AuthRepository, _ *Harness) {
+	assert := assert.New(t)
+	sess := session(rand.Int63())
+	assert.NoError(
+		repo.SetSession(sess, "vandelay", time.Hour),
+		"error in setting session",
+	)
+	got, err := repo.GetSession(sess.ID)
+	assert.NoError(err, "error in getting session")
+	assert.Equal(sess.UserID, got.UserID, "should match user id")
+	assert.Equal(sess.Provider, got.Provider, "should match provider")
+	assert.Equal(sess.ClientIP, got.ClientIP, "should match client ip")
+	assert.Equal(sess.UserAgent, got.UserAgent, "should match user agent")
+	assert.True(sess.CreatedAt.Equal(got.CreatedAt), "should match creation time")
+	token, err := repo.GetToken(sess.ID)
+	assert.NoError(err, "error in getting session token")
+	assert.Equal(
+		repository.Digest("vandelay"),
+		token,
+		"should store digest of the refresh token under the session id",
+	)
+	sess.RefreshedAt = sess.RefreshedAt.Add(time.Minute)
+	assert.NoError(
+		repo.SetSession(sess, "industries", time.Hour),
+		"error in refreshing session",
+	)
+	got, err = repo.GetSession(sess.ID)
+	assert.NoError(err, "error in getting session")
+	assert.True(
+		sess.RefreshedAt.Equal(got.RefreshedAt),
+		"should update refresh time",
+	)
+	token, err = repo.GetToken(sess.ID)
+	assert.NoError(err, "error in getting session token")
+	assert.Equal(
+		repository.Digest("industries"),
+		token,
+		"should replace the refresh token of the session",
+	)
+	_, err = repo.GetSession(xid.New().String())
+	assert.ErrorIs(
+		err,
+		repository.ErrSessionNotFound,
+		"should return not found error for missing session",
+	)
+}
+
+func testListSessions(t *testing.T, repo repository.AuthRepository, _ *Harness) {
+	assert := assert.New(t)
+	userID := rand.Int63()
+	first, second := session(userID), session(userID)
+	second.CreatedAt = first.CreatedAt.Add(time.Second)
+	for _, sess := range []*repository.Session{second, first, session(userID + 1)} {
+		assert.NoError(
+			repo.SetSession(sess, "vandelay", time.Hour),
+			"error in setting session",
+		)
+	}
+	sl, err := repo.ListSessions(userID)
+	assert.NoError(err, "error in listing sessions")
+	assert.Len(sl, 2, "should list only the sessions of the user")
+	if len(sl) == 2 {
+		assert.Equal(first.ID, sl[0].ID, "should order by creation time")
+		assert.Equal(second.ID, sl[1].ID, "should order by creation time")
+	}
+	sl, err = repo.ListSessions(rand.Int63())
+	assert.NoError(err, "error in listing sessions")
+	assert.Empty(sl, "should return empty list for user without sessions")
+}
+
+func testDeleteSession(t *testing.T, repo repository.AuthRepository, _ *Harness) {
+	assert := assert.New(t)
+	sess := session(rand.Int63())
+	assert.NoError(
+		repo.SetSession(sess, "vandelay", time.Hour),
+		"error in setting session",
+	)
+	assert.NoError(repo.DeleteSession(sess.ID), "error in deleting session")
+	_, err := repo.GetSession(sess.ID)
+	assert.ErrorIs(
+		err,
+		repository.ErrSessionNotFound,
+		"should not find deleted session",
+	)
+	lookup, err := repo.HasToken(sess.ID)
+	assert.NoError(err, "error finding token")
+	assert.False(lookup, "should delete refresh token of the session")
+	sl, err := repo.ListSessions(sess.UserID)
+	assert.NoError(err, "error in listing sessions")
+	assert.Empty(sl, "should not list deleted session")
+	assert.ErrorIs(
+		repo.DeleteSession(sess.ID),
+		repository.ErrSessionNotFound,
+		"should return not found error for deleting twice",
+	)
+}
+
+func testRefreshSession(t *testing.T, repo repository.AuthRepository, _ *Harness) {
+	assert := assert.New(t)
+	sess := session(rand.Int63())
+	assert.NoError(
+		repo.SetSession(sess, "vandelay", time.Hour),
+		"error in setting session",
+	)
+	sess.RefreshedAt = sess.RefreshedAt.Add(time.Minute)
+	assert.NoError(
+		repo.RefreshSession(sess, "industries", time.Hour),
+		"error in refreshing session",
+	)
+	got, err := repo.GetSession(sess.ID)
+	assert.NoError(err, "error in getting session")
+	assert.True(
+		sess.RefreshedAt.Equal(got.RefreshedAt),
+		"should update refresh time",
+	)
+	token, err := repo.GetToken(sess.ID)
+	assert.NoError(err, "error in getting session token")
+	assert.Equal(
+		repository.Digest("industries"),
+		token,
+		"should replace the refresh token of the session",
+	)
+	assert.NoError(repo.DeleteSession(sess.ID), "error in deleting session")
+	event := &repository.OutboxEvent{
+		ID:        xid.New().String(),
+		Subject:   "AuthService.Refresh",
+		Payload:   []byte("event"),
+		CreatedAt: time.Now().UTC(),
+	}
+	assert.ErrorIs(
+		repo.RefreshSession(sess, "kramerica", time.Hour, event),
+		repository.ErrSessionNotFound,
+		"should not refresh removed session",
+	)
+	_, err = repo.GetSession(sess.ID)
+	assert.ErrorIs(
+		err,
+		repository.ErrSessionNotFound,
+		"should not recreate removed session",
+	)
+	lookup, err := repo.HasToken(sess.ID)
+	assert.NoError(err, "error finding token")
+	assert.False(lookup, "should not store refresh token of removed session")
+	assert.Empty(
+		ownEvents(t, repo, []*repository.OutboxEvent{event}),
+		"should not store events of failed refresh",
+	)
+}
+
+func testDeleteUserSessions(t *testing.T, repo repository.AuthRepository, _ *Harness) {
+	assert := assert.New(t)
+	userID := rand.Int63()
+	sessions := []*repository.Session{session(userID), session(userID)}
+	other := session(userID + 1)
+	for _, sess := range append(sessions, other) {
+		assert.NoError(
+			repo.SetSession(sess, "vandelay", time.Hour),
+			"error in setting session",
+		)
+	}
+	count, err := repo.DeleteUserSessions(userID)
+	assert.NoError(err, "error in deleting user sessions")
+	assert.Equal(len(sessions), count, "should return number of deleted sessions")
+	for _, sess := range sessions {
+		lookup, err := repo.HasToken(sess.ID)
+		assert.NoError(err, "error finding token")
+		assert.False(lookup, "should delete refresh tokens of the user")
+	}
+	sl, err := repo.ListSessions(userID)
+	assert.NoError(err, "error in listing sessions")
+	assert.Empty(sl, "should not list any session of the user")
+	_, err = repo.GetSession(other.ID)
+	assert.NoError(err, "should keep sessions of other users")
+	count, err = repo.DeleteUserSessions(userID)
+	assert.NoError(err, "error in deleting user sessions")
+	assert.Zero(count, "should not delete anything for user without sessions")
+}
+
+// testConcurrentRevoke removes the sessions of a user while others are
+// created, every session that is kept has to be listed for the user
+func testConcurrentRevoke(t *testing.T, repo repository.AuthRepository, _ *Harness) {
+	assert := assert.New(t)
+	userID := rand.Int63()
+	sessions := make([]*repository.Session, writers)
+	errs := make(chan error, writers*2)
+	var wg sync.WaitGroup
+	for i := range sessions {
+		sessions[i] = session(userID)
+		wg.Add(2)
+		go func(sess *repository.Session) {
+			defer wg.Done()
+			errs <- repo.SetSession(sess, "vandelay", time.Hour)
+		}(sessions[i])
+		go func() {
+			defer wg.Done()
+			_, err := repo.DeleteUserSessions(userID)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(err, "error in concurrent login or revoke")
+	}
+	sl, err := repo.ListSessions(userID)
+	assert.NoError(err, "error in listing sessions")
+	listed := make(map[string]bool)
+	for _, sess := range sl {
+		listed[sess.ID] = true
+	}
+	for _, sess := range sessions {
+		_, err := repo.GetSession(sess.ID)
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			continue
+		}
+		assert.NoError(err, "error in getting session")
+		assert.True(listed[sess.ID], "should list every session that is kept")
+	}
+	count, err := repo.DeleteUserSessions(userID)
+	assert.NoError(err, "error in deleting user sessions")
+	assert.Equal(len(sl), count, "should delete every listed session")
+}
+
+func testSessionExpiry(t *testing.T, repo repository.AuthRepository, h *Harness) {
+	assert := assert.New(t)
+	userID := rand.Int63()
+	short, long := session(userID), session(userID)
+	assert.NoError(
+		repo.SetSession(short, "vandelay", time.Second),
+		"error in setting expiring session",
+	)
+	assert.NoError(
+		repo.SetSession(long, "vandelay", time.Hour),
+		"error in setting session",
+	)
+	h.Advance(2 * time.Second)
+	_, err := repo.GetSession(short.ID)
+	assert.ErrorIs(
+		err,
+		repository.ErrSessionNotFound,
+		"should not find expired session",
+	)
+	sl, err := repo.ListSessions(userID)
+	assert.NoError(err, "error in listing sessions")
+	assert.Len(sl, 1, "should list only active sessions")
+}
+
+func testLockUser(t *testing.T, repo repository.AuthRepository, h *Harness) {
+	assert := assert.New(t)
+	temporary, permanent := rand.Int63(), rand.Int63()
+	assert.NoError(repo.LockUser(temporary, time.Second), "error in locking user")
+	assert.NoError(repo.LockUser(permanent, 0), "error in locking user")
+	for _, id := range []int64{temporary, permanent} {
+		locked, err := repo.IsUserLocked(id)
+		assert.NoError(err, "error in checking lock")
+		assert.True(locked, "should lock the user")
+	}
+	locked, err := repo.IsUserLocked(rand.Int63())
+	assert.NoError(err, "error in checking lock")
+	assert.False(locked, "should not lock other users")
+	h.Advance(2 * time.Second)
+	locked, err = repo.IsUserLocked(temporary)
+	assert.NoError(err, "error in checking lock")
+	assert.False(locked, "should release expired lock")
+	locked, err = repo.IsUserLocked(permanent)
+	assert.NoError(err, "error in checking lock")
+	assert.True(locked, "should keep lock without expiration")
+	assert.NoError(repo.UnlockUser(permanent), "error in unlocking user")
+	locked, err = repo.IsUserLocked(permanent)
+	assert.NoError(err, "error in checking lock")
+	assert.False(locked, "should unlock the user")
+	assert.NoError(
+		repo.UnlockUser(permanent),
+		"should not fail for unlocking an unlocked user",
+	)
+}
+
+func testOutbox(t *testing.T, repo repository.AuthRepository, _ *Harness) {
+	assert := assert.New(t)
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	var events []*repository.OutboxEvent
+	for i := 0; i < 3; i++ {
+		events = append(events, &repository.OutboxEvent{
+			ID:        xid.New().String(),
+			Subject:   "AuthService.Create",
+			Payload:   []byte(fmt.Sprintf("event-%d", i)),
+			CreatedAt: now.Add(time.Duration(i) * time.Millisecond),
+		})
+	}
+	events[0].TraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	events[0].TraceState = "vendor=value"
+	sess := session(rand.Int63())
+	assert.NoError(
+		repo.SetSession(sess, "vandelay", time.Hour, events[0], events[1]),
+		"error in storing session with events",
+	)
+	assert.NoError(
+		repo.SetSession(sess, "vandelay", time.Hour, events[2]),
+		"error in storing session with events",
+	)
+	// other tests might share the outbox, so only the events of this test
+	// are checked
+	pending := ownEvents(t, repo, events)
+	assert.Len(pending, len(events), "should store the events")
+	for i, e := range pending {
+		assert.Equal(events[i].ID, e.ID, "should order events by creation")
+		assert.Equal(events[i].Subject, e.Subject, "should match subject")
+		assert.Equal(events[i].Payload, e.Payload, "should match payload")
+		assert.True(events[i].CreatedAt.Equal(e.CreatedAt), "should match creation time")
+		assert.Equal(events[i].TraceParent, e.TraceParent, "should match traceparent")
+		assert.Equal(events[i].TraceState, e.TraceState, "should match tracestate")
+	}
+	assert.NoError(repo.DeleteEvent(events[0].ID), "error in deleting event")
+	assert.NoError(repo.DeleteEvent(events[0].ID), "should not fail for deleted event")
+	pending = ownEvents(t, repo, events)
+	assert.Len(pending, 2, "should remove deleted event")
+	for _, e := range events[1:] {
+		assert.NoError(repo.DeleteEvent(e.ID), "error in deleting event")
+	}
+	assert.Empty(ownEvents(t, repo, events), "should remove all events")
+}
+
+func testClaimEvents(t *testing.T, repo repository.AuthRepository, h *Harness) {
+	assert := assert.New(t)
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	var events []*repository.OutboxEvent
+	for i := 0; i < 3; i++ {
+		events = append(events, &repository.OutboxEvent{
+			ID:        xid.New().String(),
+			Subject:   "AuthService.Create",
+			Payload:   []byte(fmt.Sprintf("event-%d", i)),
+			CreatedAt: now.Add(time.Duration(i) * time.Millisecond),
+		})
+	}
+	assert.NoError(
+		repo.SetSession(session(rand.Int63()), "vandelay", time.Hour, events...),
+		"error in storing session with events",
+	)
+	lease := 500 * time.Millisecond
+	claimed := ownClaims(t, repo, events, lease)
+	assert.Len(claimed, len(events), "should claim the pending events")
+	for i, e := range claimed {
+		assert.Equal(events[i].ID, e.ID, "should order claimed events by creation")
+		assert.Equal(events[i].Payload, e.Payload, "should match payload")
+	}
+	assert.Empty(ownClaims(t, repo, events, lease), "should not claim events twice")
+	assert.Len(ownEvents(t, repo, events), len(events), "should keep claimed events pending")
+	assert.NoError(repo.ReleaseEvent(events[1].ID), "error in releasing event")
+	claimed = ownClaims(t, repo, events, lease)
+	assert.Len(claimed, 1, "should claim released event again")
+	assert.Equal(events[1].ID, claimed[0].ID, "should claim released event")
+	h.Advance(lease + 100*time.Millisecond)
+	assert.Len(ownClaims(t, repo, events, lease), len(events), "should claim events after the lease")
+	for _, e := range events {
+		assert.NoError(repo.DeleteEvent(e.ID), "error in deleting event")
+	}
+	assert.Empty(ownClaims(t, repo, events, lease), "should not claim deleted events")
+}
+
+// ownClaims claims the pending events and returns the ones that are part
+// of the given events, the claims of the others are released
+func ownClaims(t *testing.T, repo repository.AuthRepository, events []*repository.OutboxEvent, lease time.Duration) []*repository.OutboxEvent {
+	t.Helper()
+	ids := make(map[string]bool)
+	for _, e := range events {
+		ids[e.ID] = true
+	}
+	claimed, err := repo.ClaimEvents(1000, lease)
+	if err != nil {
+		t.Fatalf("error in claiming events %s", err)
+	}
+	var own []*repository.OutboxEvent
+	for _, e := range claimed {
+		if ids[e.ID] {
+			own = append(own, e)
+			continue
+		}
+		if err := repo.ReleaseEvent(e.ID); err != nil {
+			t.Fatalf("error in releasing event %s", err)
+		}
+	}
+	return own
+}
+
+// ownEvents returns the pending events that are part of the given events
+func ownEvents(t *testing.T, repo repository.AuthRepository, events []*repository.OutboxEvent) []*repository.OutboxEvent {
+	t.Helper()
+	ids := make(map[string]bool)
+	for _, e := range events {
+		ids[e.ID] = true
+	}
+	pending, err := repo.PendingEvents(10000)
+	if err != nil {
+		t.Fatalf("error in getting pending events %s", err)
+	}
+	var own []*repository.OutboxEvent
+	for _, e := range pending {
+		if ids[e.ID] {
+			own = append(own, e)
+		}
+	}
+	return own
+}
+
+func testClients(t *testing.T, repo repository.AuthRepository, _ *Harness) {
+	assert := assert.New(t)
+	c := &repository.Client{
+		ID:         key("loader"),
+		SecretHash: "$2a$10$hash",
+		Scopes:     []string{"read:stock", "write:stock"},
+		TTL:        time.Hour,
+		CreatedAt:  time.Now().UTC().Truncate(time.Millisecond),
+	}
+	assert.NoError(repo.SetClient(c), "error in storing client")
+	stored, err := repo.GetClient(c.ID)
+	assert.NoError(err, "error in getting client")
+	assert.Equal(c.SecretHash, stored.SecretHash, "should match secret hash")
+	assert.Equal(c.Scopes, stored.Scopes, "should match scopes")
+	assert.Equal(c.TTL, stored.TTL, "should match ttl")
+	assert.True(c.CreatedAt.Equal(stored.CreatedAt), "should match creation time")
+	c.Scopes = []string{"read:stock"}
+	assert.NoError(repo.SetClient(c), "error in replacing client")
+	other := &repository.Client{ID: key("indexer"), SecretHash: "$2a$10$other", CreatedAt: time.Now()}
+	assert.NoError(repo.SetClient(other), "error in storing client")
+	cl, err := repo.ListClients()
+	assert.NoError(err, "error in listing clients")
+	var own []*repository.Client
+	for _, lc := range cl {
+		if lc.ID == c.ID || lc.ID == other.ID {
+			own = append(own, lc)
+		}
+	}
+	assert.Len(own, 2, "should list the clients")
+	assert.Equal(other.ID, own[0].ID, "should order clients by id")
+	assert.Equal([]string{"read:stock"}, own[1].Scopes, "should replace client")
+	assert.NoError(repo.DeleteClient(c.ID), "error in deleting client")
+	_, err = repo.GetClient(c.ID)
+	assert.ErrorIs(err, repository.ErrClientNotFound, "should remove client")
+	assert.ErrorIs(repo.DeleteClient(c.ID), repository.ErrClientNotFound, "should not delete missing client")
+	assert.NoError(repo.DeleteClient(other.ID), "error in deleting client")
+}
+
+func testPing(t *testing.T, repo repository.AuthRepository, _ *Harness) {
+	assert.NoError(t, repo.Ping(), "should reach the storage")
+}
+
+func testClose(t *testing.T, repo repository.AuthRepository, _ *Harness) {
+	assert := assert.New(t)
+	assert.NoError(repo.Close(), "error in closing repository")
+	assert.NotPanics(func() { repo.Close() }, "should close repository again") //nolint:errcheck
+}
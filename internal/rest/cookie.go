@@ -0,0 +1,240 @@
+package rest
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dictyBase/go-genproto/dictybaseapis/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const (
+	// SessionPrefix is the path of the endpoints of the cookie session
+	// mode, the refresh token cookie is only sent to these endpoints
+	SessionPrefix = Prefix + "/session"
+	// RefreshCookie is the HttpOnly cookie with the refresh token
+	RefreshCookie = "__Secure-refresh_token"
+	// CSRFCookie is the cookie with the CSRF token, it is readable by
+	// scripts so that they can submit it as CSRFHeader
+	CSRFCookie = "__Secure-csrf_token"
+	// CSRFHeader has to repeat the CSRF token of the cookie for every
+	// request that uses the refresh token cookie
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// CookieParams are the attributes of the cookies of the session mode
+type CookieParams struct {
+	// Domain of the cookies, by default the host of the request
+	Domain string
+	// SameSite restricts the cross site requests that send the cookies
+	SameSite http.SameSite
+}
+
+// ParseSameSite returns the SameSite attribute of either of strict, lax
+// or none
+func ParseSameSite(s string) (http.SameSite, error) {
+	switch strings.ToLower(s) {
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	default:
+		return 0, fmt.Errorf("unsupported same site attribute %s", s)
+	}
+}
+
+// session serves the endpoints of the cookie session mode
+//
+//	POST /v1/auth/session/login   NewLogin -> Auth
+//	POST /v1/auth/session/relogin          -> Auth
+//	POST /v1/auth/session/refresh NewToken -> Token
+//	POST /v1/auth/session/logout           -> Empty
+//
+// The refresh token is never part of a response, login, relogin and
+// refresh set it as cookie instead and logout clears it. Login also sets a
+// new CSRF token as cookie and as CSRFHeader of the response. Relogin,
+// refresh and logout take the refresh token from the cookie and require
+// the CSRF token as CSRFHeader (double submit).
+type session struct {
+	*handler
+	cookie *CookieParams
+}
+
+func registerSession(mux *http.ServeMux, h *handler, cp *CookieParams) {
+	s := &session{handler: h, cookie: cp}
+	mux.HandleFunc(SessionPrefix+"/login", s.login)
+	mux.HandleFunc(SessionPrefix+"/relogin", s.relogin)
+	mux.HandleFunc(SessionPrefix+"/refresh", s.refresh)
+	mux.HandleFunc(SessionPrefix+"/logout", s.logout)
+}
+
+func (s *session) login(w http.ResponseWriter, r *http.Request) {
+	req := &auth.NewLogin{}
+	if !readRequest(w, r, req) {
+		return
+	}
+	resp, err := s.invoke(r, "Login", req,
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return s.service.Login(ctx, req.(*auth.NewLogin))
+		},
+	)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	csrf, err := newCSRFToken()
+	if err != nil {
+		writeError(w, status.Errorf(
+			codes.Internal, "error in generating csrf token %s", err,
+		))
+		return
+	}
+	a := resp.(*auth.Auth)
+	s.setCookies(w, a.RefreshToken, csrf)
+	a.RefreshToken = ""
+	writeMessage(w, http.StatusOK, a)
+}
+
+func (s *session) relogin(w http.ResponseWriter, r *http.Request) {
+	if !readRequest(w, r, &emptypb.Empty{}) {
+		return
+	}
+	csrf, tkn, ok := s.credentials(w, r)
+	if !ok {
+		return
+	}
+	resp, err := s.invoke(r, "Relogin", &auth.NewRelogin{RefreshToken: tkn},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return s.service.Relogin(ctx, req.(*auth.NewRelogin))
+		},
+	)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	a := resp.(*auth.Auth)
+	s.setCookies(w, a.RefreshToken, csrf)
+	a.RefreshToken = ""
+	writeMessage(w, http.StatusOK, a)
+}
+
+func (s *session) refresh(w http.ResponseWriter, r *http.Request) {
+	req := &auth.NewToken{}
+	if !readRequest(w, r, req) {
+		return
+	}
+	csrf, tkn, ok := s.credentials(w, r)
+	if !ok {
+		return
+	}
+	req.RefreshToken = tkn
+	resp, err := s.invoke(r, "GetRefreshToken", req,
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return s.service.GetRefreshToken(ctx, req.(*auth.NewToken))
+		},
+	)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	t := resp.(*auth.Token)
+	s.setCookies(w, t.RefreshToken, csrf)
+	t.RefreshToken = ""
+	writeMessage(w, http.StatusOK, t)
+}
+
+// logout clears the cookies even if the session could not be removed
+func (s *session) logout(w http.ResponseWriter, r *http.Request) {
+	if !readRequest(w, r, &emptypb.Empty{}) {
+		return
+	}
+	_, tkn, ok := s.credentials(w, r)
+	if !ok {
+		return
+	}
+	s.clearCookies(w)
+	resp, err := s.invoke(r, "Logout", &auth.NewRefreshToken{RefreshToken: tkn},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return s.service.Logout(ctx, req.(*auth.NewRefreshToken))
+		},
+	)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeMessage(w, http.StatusOK, resp.(*emptypb.Empty))
+}
+
+// credentials returns the CSRF token and the refresh token of the
+// cookies. The CSRF token has to match the CSRFHeader, otherwise the
+// error is written and false is returned.
+func (s *session) credentials(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	csrf, err := r.Cookie(CSRFCookie)
+	if err != nil || csrf.Value == "" || subtle.ConstantTimeCompare(
+		[]byte(csrf.Value), []byte(r.Header.Get(CSRFHeader)),
+	) != 1 {
+		writeError(w, status.Error(codes.PermissionDenied, "csrf token is missing or invalid"))
+		return "", "", false
+	}
+	tkn, err := r.Cookie(RefreshCookie)
+	if err != nil || tkn.Value == "" {
+		writeError(w, status.Error(codes.Unauthenticated, "refresh token cookie is missing"))
+		return "", "", false
+	}
+	return csrf.Value, tkn.Value, true
+}
+
+// setCookies sets the cookies until the expiration of the refresh token
+func (s *session) setCookies(w http.ResponseWriter, tkn, csrf string) {
+	exp := expiration(tkn)
+	http.SetCookie(w, s.newCookie(RefreshCookie, tkn, SessionPrefix, exp))
+	http.SetCookie(w, s.newCookie(CSRFCookie, csrf, "/", exp))
+	w.Header().Set(CSRFHeader, csrf)
+}
+
+func (s *session) clearCookies(w http.ResponseWriter) {
+	for _, c := range []*http.Cookie{
+		s.newCookie(RefreshCookie, "", SessionPrefix, time.Time{}),
+		s.newCookie(CSRFCookie, "", "/", time.Time{}),
+	} {
+		c.MaxAge = -1
+		http.SetCookie(w, c)
+	}
+}
+
+// newCookie returns a secure cookie, only the refresh token is HttpOnly.
+// Without expiration it is a cookie of the browser session.
+func (s *session) newCookie(name, value, path string, exp time.Time) *http.Cookie {
+	c := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   s.cookie.Domain,
+		Secure:   true,
+		HttpOnly: name == RefreshCookie,
+		SameSite: s.cookie.SameSite,
+	}
+	if !exp.IsZero() {
+		c.Expires = exp
+		c.MaxAge = int(time.Until(exp).Seconds())
+	}
+	return c
+}
+
+func newCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,151 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dictyBase/go-genproto/dictybaseapis/auth"
+	"github.com/dictyBase/modware-auth/internal/app/service"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type sessionServer struct {
+	auth.UnimplementedAuthServiceServer
+	refresh   string
+	access    string
+	loggedOut string
+	md        metadata.MD
+}
+
+func (s *sessionServer) Login(ctx context.Context, l *auth.NewLogin) (*auth.Auth, error) {
+	return &auth.Auth{Token: "access", RefreshToken: s.refresh}, nil
+}
+
+func (s *sessionServer) Relogin(ctx context.Context, r *auth.NewRelogin) (*auth.Auth, error) {
+	if r.RefreshToken != s.refresh {
+		return nil, status.Error(codes.NotFound, "session not found")
+	}
+	return &auth.Auth{Token: "access", RefreshToken: s.refresh}, nil
+}
+
+func (s *sessionServer) GetRefreshToken(ctx context.Context, t *auth.NewToken) (*auth.Token, error) {
+	s.md, _ = metadata.FromIncomingContext(ctx)
+	if sc := s.md.Get(service.ClientSecretMetadata); len(sc) > 0 && sc[0] != "s3cret" {
+		return nil, status.Error(codes.Unauthenticated, service.ErrInvalidClient.Error())
+	}
+	if t.RefreshToken != s.refresh {
+		return nil, status.Error(codes.NotFound, "session not found")
+	}
+	if s.access != "" {
+		return &auth.Token{Token: s.access, RefreshToken: s.refresh}, nil
+	}
+	return &auth.Token{Token: "access", RefreshToken: s.refresh}, nil
+}
+
+func (s *sessionServer) Logout(ctx context.Context, t *auth.NewRefreshToken) (*emptypb.Empty, error) {
+	s.loggedOut = t.RefreshToken
+	return &emptypb.Empty{}, nil
+}
+
+func sessionRequest(h http.Handler, path, csrf string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, SessionPrefix+path, strings.NewReader(`{}`))
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	if csrf != "" {
+		req.Header.Set(CSRFHeader, csrf)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func responseCookie(w *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestSession(t *testing.T) {
+	assert := assert.New(t)
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	refresh, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+		ExpiresAt: exp.Unix(),
+	}).SignedString([]byte("secret"))
+	assert.NoError(err, "error in signing token")
+	srv := &sessionServer{refresh: refresh}
+	h, err := NewHandler(&HandlerParams{
+		Service: srv,
+		Cookie:  &CookieParams{Domain: "dictycr.org", SameSite: http.SameSiteStrictMode},
+	})
+	assert.NoError(err, "error in creating handler")
+	w := sessionRequest(h, "/login", "", nil)
+	assert.Equal(http.StatusOK, w.Code, "should login")
+	resp := &auth.Auth{}
+	assert.NoError(protojson.Unmarshal(w.Body.Bytes(), resp), "error in decoding response")
+	assert.Equal("access", resp.Token, "should respond with access token")
+	assert.Empty(resp.RefreshToken, "should not respond with refresh token")
+	rc := responseCookie(w, RefreshCookie)
+	assert.NotNil(rc, "should set refresh token cookie")
+	assert.Equal(refresh, rc.Value, "should set refresh token")
+	assert.True(rc.HttpOnly, "should hide refresh token from scripts")
+	assert.True(rc.Secure, "should only send refresh token over https")
+	assert.Equal(http.SameSiteStrictMode, rc.SameSite, "should set same site attribute")
+	assert.Equal(SessionPrefix, rc.Path, "should scope refresh token to session endpoints")
+	assert.Equal("dictycr.org", rc.Domain, "should set domain")
+	assert.True(exp.Equal(rc.Expires), "should expire with refresh token")
+	cc := responseCookie(w, CSRFCookie)
+	assert.NotNil(cc, "should set csrf cookie")
+	assert.False(cc.HttpOnly, "should expose csrf token to scripts")
+	assert.Equal(cc.Value, w.Header().Get(CSRFHeader), "should send csrf token as header")
+	cookies := []*http.Cookie{
+		{Name: RefreshCookie, Value: rc.Value},
+		{Name: CSRFCookie, Value: cc.Value},
+	}
+	w = sessionRequest(h, "/relogin", "", cookies)
+	assert.Equal(http.StatusForbidden, w.Code, "should reject relogin without csrf header")
+	w = sessionRequest(h, "/relogin", "forged", cookies)
+	assert.Equal(http.StatusForbidden, w.Code, "should reject relogin with other csrf token")
+	w = sessionRequest(h, "/relogin", cc.Value, cookies[1:])
+	assert.Equal(http.StatusUnauthorized, w.Code, "should reject relogin without refresh token")
+	w = sessionRequest(h, "/relogin", cc.Value, cookies)
+	assert.Equal(http.StatusOK, w.Code, "should relogin with refresh token cookie")
+	assert.NotNil(responseCookie(w, RefreshCookie), "should renew refresh token cookie")
+	assert.Equal(cc.Value, responseCookie(w, CSRFCookie).Value, "should keep csrf token")
+	w = sessionRequest(h, "/refresh", cc.Value, cookies)
+	assert.Equal(http.StatusOK, w.Code, "should refresh with refresh token cookie")
+	tkn := &auth.Token{}
+	assert.NoError(protojson.Unmarshal(w.Body.Bytes(), tkn), "error in decoding response")
+	assert.Empty(tkn.RefreshToken, "should not respond with refresh token")
+	w = sessionRequest(h, "/logout", cc.Value, cookies)
+	assert.Equal(http.StatusOK, w.Code, "should logout")
+	assert.Equal(refresh, srv.loggedOut, "should logout session of cookie")
+	for _, name := range []string{RefreshCookie, CSRFCookie} {
+		c := responseCookie(w, name)
+		assert.NotNil(c, "should clear cookie")
+		assert.Empty(c.Value, "should clear cookie")
+		assert.Equal(-1, c.MaxAge, "should expire cookie")
+	}
+}
+
+func TestParseSameSite(t *testing.T) {
+	assert := assert.New(t)
+	ss, err := ParseSameSite("Lax")
+	assert.NoError(err, "should parse same site attribute")
+	assert.Equal(http.SameSiteLaxMode, ss, "should parse lax")
+	_, err = ParseSameSite("sometimes")
+	assert.Error(err, "should reject unknown attribute")
+}
@@ -0,0 +1,62 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+)
+
+// allowedHeaders are the request headers that browsers may send
+var allowedHeaders = []string{
+	"Authorization",
+	"Content-Type",
+	"X-Request-Id",
+	"X-Auth-Audience",
+	"X-Auth-Scope",
+	CSRFHeader,
+}
+
+// CORS returns the handler that allows the cross origin requests of the
+// given origins. Credentials are only allowed for the origins that are
+// listed explicitly, not for the * wildcard. Preflight requests are
+// answered without calling the next handler.
+func CORS(origins []string, next http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(origins))
+	for _, o := range origins {
+		allowed[strings.TrimSuffix(strings.TrimSpace(o), "/")] = true
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions &&
+			r.Header.Get("Access-Control-Request-Method") != ""
+		switch {
+		case allowed[origin]:
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Allow-Credentials", "true")
+		case allowed["*"]:
+			h.Set("Access-Control-Allow-Origin", "*")
+		default:
+			// the browser rejects the response without the headers
+			if preflight {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		h.Set("Access-Control-Expose-Headers", "Retry-After, "+CSRFHeader)
+		if preflight {
+			h.Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+			h.Set("Access-Control-Allow-Headers", strings.Join(allowedHeaders, ", "))
+			h.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
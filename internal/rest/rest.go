@@ -0,0 +1,252 @@
+// Package rest exposes the login methods of the auth service as HTTP/JSON
+// endpoints for the clients that do not speak grpc. The calls go through
+// the same interceptors as the grpc server, so they are validated, logged,
+// audited and rate limited alike.
+package rest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/dictyBase/go-genproto/dictybaseapis/auth"
+	"github.com/dictyBase/modware-auth/internal/app/service"
+	"github.com/dictyBase/modware-auth/internal/authapi"
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Prefix is the path of all endpoints
+const Prefix = "/v1/auth"
+
+// maxBodySize is the limit of the request body in bytes
+const maxBodySize = 1 << 20
+
+// forwardedHeaders are passed on to the service as incoming metadata
+var forwardedHeaders = []string{
+	"authorization",
+	"user-agent",
+	"x-forwarded-for",
+	"x-request-id",
+	service.AudienceMetadata,
+	service.ScopeMetadata,
+	service.ClientIDMetadata,
+	service.ClientSecretMetadata,
+}
+
+// HandlerParams are the attributes that are required for creating the
+// HTTP handler
+type HandlerParams struct {
+	Service auth.AuthServiceServer `validate:"required"`
+	// Interceptor is applied to every call as in the grpc server, by
+	// default the service is called directly
+	Interceptor grpc.UnaryServerInterceptor
+	// Origins are the web origins that may call the endpoints from a
+	// browser, * allows every origin
+	Origins []string
+	// Cookie enables the endpoints of the cookie session mode
+	Cookie *CookieParams
+	// Clients issues the tokens of the client credentials grant, the
+	// grant is not supported if it is nil
+	Clients *service.ClientTokens
+}
+
+type handler struct {
+	service     auth.AuthServiceServer
+	interceptor grpc.UnaryServerInterceptor
+}
+
+// NewHandler returns the handler of the endpoints
+//
+//	POST /v1/auth/login   NewLogin        -> Auth
+//	POST /v1/auth/relogin NewRelogin      -> Auth
+//	POST /v1/auth/refresh NewToken        -> Token
+//	POST /v1/auth/logout  NewRefreshToken -> Empty
+//
+// along with the OAuth2 token endpoint at /oauth/token and the endpoints of
+// the cookie session mode below /v1/auth/session if it is enabled. Requests
+// and responses are the JSON mapping of the protocol buffers. Errors are
+// the JSON mapping of the grpc status with the HTTP status that corresponds
+// to its code.
+func NewHandler(p *HandlerParams) (http.Handler, error) {
+	if err := validator.New().Struct(p); err != nil {
+		return nil, err
+	}
+	h := &handler{service: p.Service, interceptor: p.Interceptor}
+	if h.interceptor == nil {
+		h.interceptor = func(
+			ctx context.Context,
+			req interface{},
+			_ *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler,
+		) (interface{}, error) {
+			return handler(ctx, req)
+		}
+	}
+	mux := http.NewServeMux()
+	mux.Handle(Prefix+"/login", h.endpoint(
+		"Login",
+		func() proto.Message { return &auth.NewLogin{} },
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return p.Service.Login(ctx, req.(*auth.NewLogin))
+		},
+	))
+	mux.Handle(Prefix+"/relogin", h.endpoint(
+		"Relogin",
+		func() proto.Message { return &auth.NewRelogin{} },
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return p.Service.Relogin(ctx, req.(*auth.NewRelogin))
+		},
+	))
+	mux.Handle(Prefix+"/refresh", h.endpoint(
+		"GetRefreshToken",
+		func() proto.Message { return &auth.NewToken{} },
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return p.Service.GetRefreshToken(ctx, req.(*auth.NewToken))
+		},
+	))
+	mux.Handle(Prefix+"/logout", h.endpoint(
+		"Logout",
+		func() proto.Message { return &auth.NewRefreshToken{} },
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return p.Service.Logout(ctx, req.(*auth.NewRefreshToken))
+		},
+	))
+	te := &tokenEndpoint{handler: h}
+	if p.Clients != nil {
+		te.clients = service.NewClientService(p.Clients)
+	}
+	mux.Handle(TokenPath, te)
+	if p.Cookie != nil {
+		registerSession(mux, h, p.Cookie)
+	}
+	return CORS(p.Origins, mux), nil
+}
+
+// endpoint decodes the request, calls the method through the interceptor
+// and encodes its response
+func (h *handler) endpoint(
+	method string,
+	newReq func() proto.Message,
+	call grpc.UnaryHandler,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := newReq()
+		if !readRequest(w, r, req) {
+			return
+		}
+		resp, err := h.invoke(r, method, req, call)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeMessage(w, http.StatusOK, resp.(proto.Message))
+	})
+}
+
+// invoke calls the method of the auth service through the interceptor
+func (h *handler) invoke(
+	r *http.Request,
+	method string,
+	req interface{},
+	call grpc.UnaryHandler,
+) (interface{}, error) {
+	return h.interceptor(IncomingContext(r), req, &grpc.UnaryServerInfo{
+		Server:     h.service,
+		FullMethod: fmt.Sprintf("/%s/%s", auth.AuthService_ServiceDesc.ServiceName, method),
+	}, call)
+}
+
+// invokeClient calls the method of the client service through the
+// interceptor
+func (h *handler) invokeClient(
+	r *http.Request,
+	srv *service.ClientService,
+	method string,
+	req interface{},
+	call grpc.UnaryHandler,
+) (interface{}, error) {
+	return h.interceptor(IncomingContext(r), req, &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: fmt.Sprintf("/%s/%s", authapi.ClientService_ServiceDesc.ServiceName, method),
+	}, call)
+}
+
+// readRequest decodes the body of a POST request, otherwise the error is
+// written and false is returned
+func readRequest(w http.ResponseWriter, r *http.Request, msg proto.Message) bool {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeStatus(w, http.StatusMethodNotAllowed, status.Newf(
+			codes.Unimplemented, "method %s is not allowed", r.Method,
+		))
+		return false
+	}
+	if err := decode(r, msg); err != nil {
+		writeError(w, status.Errorf(
+			codes.InvalidArgument, "invalid request body %s", err,
+		))
+		return false
+	}
+	return true
+}
+
+// IncomingContext returns the context of the request with the forwarded
+// headers as incoming metadata and the remote address as peer, the same
+// way the grpc server presents a call to the service
+func IncomingContext(r *http.Request) context.Context {
+	md := metadata.MD{}
+	for _, k := range forwardedHeaders {
+		if v := r.Header.Values(k); len(v) > 0 {
+			md.Set(k, v...)
+		}
+	}
+	ctx := metadata.NewIncomingContext(r.Context(), md)
+	return peer.NewContext(ctx, &peer.Peer{Addr: remoteAddr(r.RemoteAddr)})
+}
+
+// remoteAddr is the address of the HTTP client
+type remoteAddr string
+
+func (a remoteAddr) Network() string {
+	return "tcp"
+}
+
+func (a remoteAddr) String() string {
+	return string(a)
+}
+
+var _ net.Addr = remoteAddr("")
+
+func decode(r *http.Request, msg proto.Message) error {
+	data, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodySize))
+	if err != nil {
+		return err
+	}
+	// an empty body is an empty message that fails the validation
+	if len(data) == 0 {
+		return nil
+	}
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, msg)
+}
+
+func writeMessage(w http.ResponseWriter, code int, msg proto.Message) {
+	data, err := protojson.Marshal(msg)
+	if err != nil {
+		writeError(w, status.Errorf(
+			codes.Internal, "error in encoding response %s", err,
+		))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data) //nolint:errcheck
+}
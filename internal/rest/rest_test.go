@@ -0,0 +1,138 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dictyBase/go-genproto/dictybaseapis/auth"
+	"github.com/dictyBase/modware-auth/internal/app/service"
+	"github.com/dictyBase/modware-auth/internal/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type authServer struct {
+	auth.UnimplementedAuthServiceServer
+}
+
+func (s *authServer) Relogin(ctx context.Context, r *auth.NewRelogin) (*auth.Auth, error) {
+	if r.RefreshToken != "valid" {
+		return nil, status.Error(codes.Unauthenticated, "invalid refresh token")
+	}
+	return &auth.Auth{Token: "access", RefreshToken: "refresh"}, nil
+}
+
+func (s *authServer) GetRefreshToken(ctx context.Context, t *auth.NewToken) (*auth.Token, error) {
+	return nil, ratelimit.Exhausted(ctx, 1500*time.Millisecond, errors.New("slow down"))
+}
+
+func (s *authServer) Logout(ctx context.Context, t *auth.NewRefreshToken) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
+func newTestHandler(t *testing.T, ic grpc.UnaryServerInterceptor) http.Handler {
+	t.Helper()
+	h, err := NewHandler(&HandlerParams{
+		Service:     &authServer{},
+		Interceptor: ic,
+		Origins:     []string{"https://dictybase.org"},
+	})
+	if err != nil {
+		t.Fatalf("error in creating handler %s", err)
+	}
+	return h
+}
+
+func post(h http.Handler, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, Prefix+path, strings.NewReader(body))
+	req.Header.Set("User-Agent", "perl")
+	req.Header.Set("X-Auth-Audience", "admin")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandler(t *testing.T) {
+	assert := assert.New(t)
+	var method, agent, audience string
+	var addr string
+	h := newTestHandler(t, func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		method = info.FullMethod
+		md, _ := metadata.FromIncomingContext(ctx)
+		agent = md.Get("user-agent")[0]
+		audience = md.Get(service.AudienceMetadata)[0]
+		p, _ := peer.FromContext(ctx)
+		addr = p.Addr.String()
+		return handler(ctx, req)
+	})
+	w := post(h, "/relogin", `{"refreshToken": "valid", "unknown": 1}`)
+	assert.Equal(http.StatusOK, w.Code, "should relogin")
+	assert.Equal("application/json", w.Header().Get("Content-Type"), "should respond with json")
+	resp := &auth.Auth{}
+	assert.NoError(protojson.Unmarshal(w.Body.Bytes(), resp), "error in decoding response")
+	assert.Equal("access", resp.Token, "should respond with the tokens")
+	assert.Equal("/dictybase.auth.AuthService/Relogin", method, "should call interceptor with grpc method")
+	assert.Equal("perl", agent, "should forward headers as metadata")
+	assert.Equal("admin", audience, "should forward requested audience")
+	assert.Equal("192.0.2.1:1234", addr, "should pass remote address as peer")
+	w = post(h, "/relogin", `{"refresh_token": "expired"}`)
+	assert.Equal(http.StatusUnauthorized, w.Code, "should map unauthenticated to 401")
+	assert.Contains(w.Body.String(), "invalid refresh token", "should respond with status message")
+	w = post(h, "/relogin", `{"refresh_token":`)
+	assert.Equal(http.StatusBadRequest, w.Code, "should reject malformed body")
+	w = post(h, "/refresh", `{}`)
+	assert.Equal(http.StatusTooManyRequests, w.Code, "should map resource exhausted to 429")
+	assert.Equal("2", w.Header().Get("Retry-After"), "should send retry delay")
+	w = post(h, "/logout", `{"refreshToken": "valid"}`)
+	assert.Equal(http.StatusOK, w.Code, "should logout")
+	w = post(h, "/login", `{}`)
+	assert.Equal(http.StatusNotImplemented, w.Code, "should map unimplemented to 501")
+	req := httptest.NewRequest(http.MethodGet, Prefix+"/logout", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(http.StatusMethodNotAllowed, w.Code, "should only allow post")
+}
+
+func TestCORS(t *testing.T) {
+	assert := assert.New(t)
+	h := newTestHandler(t, nil)
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, Prefix+"/login", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+	w := preflight("https://dictybase.org")
+	assert.Equal(http.StatusNoContent, w.Code, "should answer preflight")
+	assert.Equal("https://dictybase.org", w.Header().Get("Access-Control-Allow-Origin"), "should allow listed origin")
+	assert.Equal("true", w.Header().Get("Access-Control-Allow-Credentials"), "should allow credentials of listed origin")
+	assert.Contains(w.Header().Get("Access-Control-Allow-Methods"), http.MethodPost, "should allow post")
+	w = preflight("https://evil.example")
+	assert.Equal(http.StatusNoContent, w.Code, "should answer preflight")
+	assert.Empty(w.Header().Get("Access-Control-Allow-Origin"), "should not allow other origins")
+	wild := CORS([]string{"*"}, http.NotFoundHandler())
+	req := httptest.NewRequest(http.MethodPost, Prefix+"/login", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	w = httptest.NewRecorder()
+	wild.ServeHTTP(w, req)
+	assert.Equal("*", w.Header().Get("Access-Control-Allow-Origin"), "should allow every origin")
+	assert.Empty(w.Header().Get("Access-Control-Allow-Credentials"), "should not allow credentials of every origin")
+}
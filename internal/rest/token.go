@@ -0,0 +1,239 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dictyBase/go-genproto/dictybaseapis/auth"
+	"github.com/dictyBase/modware-auth/internal/app/service"
+	"github.com/dictyBase/modware-auth/internal/authapi"
+	"github.com/golang-jwt/jwt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TokenPath is the path of the OAuth2 token endpoint
+const TokenPath = "/oauth/token"
+
+// errors of the token endpoint, RFC 6749 section 5.2
+const (
+	errInvalidRequest       = "invalid_request"
+	errInvalidClient        = "invalid_client"
+	errInvalidGrant         = "invalid_grant"
+	errInvalidScope         = "invalid_scope"
+	errUnsupportedGrantType = "unsupported_grant_type"
+	errServer               = "server_error"
+	errUnavailable          = "temporarily_unavailable"
+)
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+type tokenError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// tokenEndpoint serves the token endpoint of RFC 6749 for the
+// refresh_token and the client_credentials grants. The parameters are
+// form encoded, clients authenticate with HTTP Basic or with the
+// client_id and client_secret parameters. Refresh tokens are rotated as
+// with GetRefreshToken, a refresh of a token for another than the default
+// audience has to authenticate as the client the token was issued to.
+type tokenEndpoint struct {
+	*handler
+	clients *service.ClientService
+}
+
+func (t *tokenEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeTokenError(w, http.StatusMethodNotAllowed, errInvalidRequest, "method is not allowed")
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+	if err := r.ParseForm(); err != nil {
+		writeTokenError(w, http.StatusBadRequest, errInvalidRequest, err.Error())
+		return
+	}
+	switch gt := r.PostForm.Get("grant_type"); gt {
+	case "refresh_token":
+		t.refreshToken(w, r)
+	case "client_credentials":
+		if t.clients == nil {
+			writeTokenError(w, http.StatusBadRequest, errUnsupportedGrantType, "client credentials are not supported")
+			return
+		}
+		t.clientCredentials(w, r)
+	case "":
+		writeTokenError(w, http.StatusBadRequest, errInvalidRequest, "grant_type is missing")
+	default:
+		writeTokenError(w, http.StatusBadRequest, errUnsupportedGrantType, "unsupported grant type "+gt)
+	}
+}
+
+func (t *tokenEndpoint) refreshToken(w http.ResponseWriter, r *http.Request) {
+	rt := r.PostForm.Get("refresh_token")
+	if rt == "" {
+		writeTokenError(w, http.StatusBadRequest, errInvalidRequest, "refresh_token is missing")
+		return
+	}
+	basic := refreshHeaders(r)
+	resp, err := t.invoke(r, "GetRefreshToken", &auth.NewToken{RefreshToken: rt},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return t.service.GetRefreshToken(ctx, req.(*auth.NewToken))
+		},
+	)
+	if err != nil {
+		st := status.Convert(err)
+		if st.Code() == codes.Unauthenticated &&
+			strings.Contains(st.Message(), service.ErrInvalidClient.Error()) {
+			if basic {
+				w.Header().Set("WWW-Authenticate", `Basic realm="modware-auth"`)
+			}
+			writeTokenError(w, http.StatusUnauthorized, errInvalidClient, st.Message())
+			return
+		}
+		writeGrantError(w, err)
+		return
+	}
+	tkn := resp.(*auth.Token)
+	writeToken(w, &tokenResponse{
+		AccessToken:  tkn.Token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(service.AccessTokenLifetime.Seconds()),
+		RefreshToken: tkn.RefreshToken,
+	})
+}
+
+func (t *tokenEndpoint) clientCredentials(w http.ResponseWriter, r *http.Request) {
+	id, secret, basic := clientCredentials(r)
+	req := &authapi.ClientCredentials{
+		ClientId:     id,
+		ClientSecret: secret,
+		Scopes:       strings.Fields(r.PostForm.Get("scope")),
+	}
+	resp, err := t.invokeClient(r, t.clients, "IssueToken", req,
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return t.clients.IssueToken(ctx, req.(*authapi.ClientCredentials))
+		},
+	)
+	if err != nil {
+		st := status.Convert(err)
+		switch st.Code() {
+		case codes.Unauthenticated:
+			if basic {
+				w.Header().Set("WWW-Authenticate", `Basic realm="modware-auth"`)
+			}
+			writeTokenError(w, http.StatusUnauthorized, errInvalidClient, st.Message())
+		case codes.PermissionDenied:
+			writeTokenError(w, http.StatusBadRequest, errInvalidScope, st.Message())
+		default:
+			writeGrantError(w, err)
+		}
+		return
+	}
+	ct := resp.(*authapi.ClientToken)
+	writeToken(w, &tokenResponse{
+		AccessToken: ct.Token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(ct.ExpiresIn.AsDuration().Seconds()),
+		Scope:       strings.Join(ct.Scopes, " "),
+	})
+}
+
+// refreshHeaders passes the credentials of the client and the requested
+// scope of a refresh on to the service as the headers of the client
+// metadata and of the scope, RFC 6749 section 6. It reports whether the
+// client authenticates with HTTP Basic.
+func refreshHeaders(r *http.Request) bool {
+	id, secret, basic := clientCredentials(r)
+	if len(id) > 0 || len(secret) > 0 {
+		r.Header.Set(service.ClientIDMetadata, id)
+		r.Header.Set(service.ClientSecretMetadata, secret)
+	}
+	if sc, ok := r.PostForm["scope"]; ok {
+		r.Header.Set(service.ScopeMetadata, strings.Join(sc, " "))
+	}
+	return basic
+}
+
+// clientCredentials returns the credentials of the HTTP Basic
+// authentication, whose values are form encoded, or otherwise of the
+// client_id and client_secret parameters
+func clientCredentials(r *http.Request) (string, string, bool) {
+	if id, secret, ok := r.BasicAuth(); ok {
+		uid, err := url.QueryUnescape(id)
+		if err != nil {
+			return "", "", true
+		}
+		usecret, err := url.QueryUnescape(secret)
+		if err != nil {
+			return "", "", true
+		}
+		return uid, usecret, true
+	}
+	return r.PostForm.Get("client_id"), r.PostForm.Get("client_secret"), false
+}
+
+// writeGrantError writes the error of a grant with the grpc status of its
+// method
+func writeGrantError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	switch st.Code() {
+	case codes.InvalidArgument:
+		writeTokenError(w, http.StatusBadRequest, errInvalidRequest, st.Message())
+	case codes.Unauthenticated, codes.NotFound, codes.PermissionDenied:
+		writeTokenError(w, http.StatusBadRequest, errInvalidGrant, st.Message())
+	case codes.ResourceExhausted:
+		setRetryAfter(w, st)
+		writeTokenError(w, http.StatusTooManyRequests, errUnavailable, st.Message())
+	case codes.Unavailable:
+		writeTokenError(w, http.StatusServiceUnavailable, errUnavailable, st.Message())
+	default:
+		writeTokenError(w, http.StatusInternalServerError, errServer, "")
+	}
+}
+
+func writeTokenError(w http.ResponseWriter, code int, e, desc string) {
+	writeJSON(w, code, &tokenError{Error: e, Description: desc})
+}
+
+func writeToken(w http.ResponseWriter, resp *tokenResponse) {
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// writeJSON writes the response of the token endpoint, which must not be
+// cached
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h := w.Header()
+	h.Set("Content-Type", "application/json;charset=UTF-8")
+	h.Set("Cache-Control", "no-store")
+	h.Set("Pragma", "no-cache")
+	w.WriteHeader(code)
+	w.Write(data) //nolint:errcheck
+}
+
+// expiration returns the expiration of the token without verifying it,
+// it is zero if the token cannot be parsed
+func expiration(tkn string) time.Time {
+	claims := &jwt.StandardClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(tkn, claims); err != nil || claims.ExpiresAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.ExpiresAt, 0)
+}
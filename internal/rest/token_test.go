@@ -0,0 +1,152 @@
+package rest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dictyBase/modware-auth/internal/app/service"
+	"github.com/dictyBase/modware-auth/internal/jwtauth"
+	"github.com/dictyBase/modware-auth/internal/repository"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type clientRegistry map[string]*repository.Client
+
+func (r clientRegistry) GetClient(id string) (*repository.Client, error) {
+	if c, ok := r[id]; ok {
+		return c, nil
+	}
+	return nil, repository.ErrClientNotFound
+}
+
+func newTestClientTokens(t *testing.T) *service.ClientTokens {
+	t.Helper()
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error in generating key %s", err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("error in hashing secret %s", err)
+	}
+	ct, err := service.NewClientTokens(&service.ClientTokenParams{
+		Registry: clientRegistry{"loader": &repository.Client{
+			ID: "loader", SecretHash: string(hash), Scopes: []string{"read:stock"},
+		}},
+		JWTAuth: *jwtauth.NewJwtAuth(jwt.SigningMethodRS512, private, &private.PublicKey),
+	})
+	if err != nil {
+		t.Fatalf("error in creating client tokens %s", err)
+	}
+	return ct
+}
+
+func tokenRequest(h http.Handler, form url.Values, basic ...string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	req := httptest.NewRequest(http.MethodPost, TokenPath, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if len(basic) == 2 {
+		req.SetBasicAuth(basic[0], basic[1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	body := map[string]interface{}{}
+	json.Unmarshal(w.Body.Bytes(), &body) //nolint:errcheck
+	return w, body
+}
+
+func TestTokenRefreshGrant(t *testing.T) {
+	assert := assert.New(t)
+	access, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+		ExpiresAt: time.Now().Add(15 * time.Minute).Unix(),
+	}).SignedString([]byte("secret"))
+	assert.NoError(err, "error in signing token")
+	h, err := NewHandler(&HandlerParams{
+		Service: &sessionServer{refresh: "valid", access: access},
+	})
+	assert.NoError(err, "error in creating handler")
+	w, body := tokenRequest(h, url.Values{
+		"grant_type": {"refresh_token"}, "refresh_token": {"valid"},
+	})
+	assert.Equal(http.StatusOK, w.Code, "should refresh tokens")
+	assert.Equal("no-store", w.Header().Get("Cache-Control"), "should not cache tokens")
+	assert.Equal(access, body["access_token"], "should respond with access token")
+	assert.Equal("Bearer", body["token_type"], "should respond with bearer token")
+	assert.Equal("valid", body["refresh_token"], "should respond with refresh token")
+	assert.InDelta(900, body["expires_in"], 2, "should respond with lifetime of access token")
+	w, body = tokenRequest(h, url.Values{
+		"grant_type": {"refresh_token"}, "refresh_token": {"revoked"},
+	})
+	assert.Equal(http.StatusBadRequest, w.Code, "should reject revoked token")
+	assert.Equal("invalid_grant", body["error"], "should respond with invalid grant")
+	_, body = tokenRequest(h, url.Values{"grant_type": {"refresh_token"}})
+	assert.Equal("invalid_request", body["error"], "should reject missing token")
+	_, body = tokenRequest(h, url.Values{"grant_type": {"password"}})
+	assert.Equal("unsupported_grant_type", body["error"], "should reject password grant")
+	_, body = tokenRequest(h, url.Values{"grant_type": {"client_credentials"}})
+	assert.Equal("unsupported_grant_type", body["error"], "should reject client credentials without registry")
+}
+
+func TestTokenRefreshGrantClient(t *testing.T) {
+	assert := assert.New(t)
+	srv := &sessionServer{refresh: "valid"}
+	h, err := NewHandler(&HandlerParams{Service: srv})
+	assert.NoError(err, "error in creating handler")
+	w, _ := tokenRequest(h, url.Values{
+		"grant_type": {"refresh_token"}, "refresh_token": {"valid"}, "scope": {"read"},
+	}, "loader", "s3cret")
+	assert.Equal(http.StatusOK, w.Code, "should refresh tokens")
+	assert.Equal([]string{"loader"}, srv.md.Get(service.ClientIDMetadata), "should forward client id of basic authentication")
+	assert.Equal([]string{"s3cret"}, srv.md.Get(service.ClientSecretMetadata), "should forward client secret of basic authentication")
+	assert.Equal([]string{"read"}, srv.md.Get(service.ScopeMetadata), "should forward scope")
+	w, _ = tokenRequest(h, url.Values{
+		"grant_type": {"refresh_token"}, "refresh_token": {"valid"},
+		"client_id": {"loader"}, "client_secret": {"s3cret"},
+	})
+	assert.Equal(http.StatusOK, w.Code, "should refresh tokens")
+	assert.Equal([]string{"loader"}, srv.md.Get(service.ClientIDMetadata), "should forward client id parameter")
+	assert.Equal([]string{"s3cret"}, srv.md.Get(service.ClientSecretMetadata), "should forward client secret parameter")
+	assert.Empty(srv.md.Get(service.ScopeMetadata), "should not forward missing scope")
+	w, body := tokenRequest(h, url.Values{
+		"grant_type": {"refresh_token"}, "refresh_token": {"valid"},
+	}, "loader", "wrong")
+	assert.Equal(http.StatusUnauthorized, w.Code, "should reject wrong client secret")
+	assert.Equal("invalid_client", body["error"], "should respond with invalid client")
+	assert.NotEmpty(w.Header().Get("WWW-Authenticate"), "should challenge basic authentication")
+}
+
+func TestTokenClientCredentialsGrant(t *testing.T) {
+	assert := assert.New(t)
+	h, err := NewHandler(&HandlerParams{
+		Service: &sessionServer{},
+		Clients: newTestClientTokens(t),
+	})
+	assert.NoError(err, "error in creating handler")
+	w, body := tokenRequest(h, url.Values{"grant_type": {"client_credentials"}}, "loader", "s3cret")
+	assert.Equal(http.StatusOK, w.Code, "should issue token")
+	assert.NotEmpty(body["access_token"], "should respond with access token")
+	assert.Equal("read:stock", body["scope"], "should respond with granted scope")
+	assert.NotContains(body, "refresh_token", "should not issue refresh token")
+	_, body = tokenRequest(h, url.Values{
+		"grant_type": {"client_credentials"}, "client_id": {"loader"},
+		"client_secret": {"s3cret"}, "scope": {"read:stock"},
+	})
+	assert.NotEmpty(body["access_token"], "should accept credentials of the form")
+	w, body = tokenRequest(h, url.Values{"grant_type": {"client_credentials"}}, "loader", "guess")
+	assert.Equal(http.StatusUnauthorized, w.Code, "should reject wrong secret")
+	assert.Equal("invalid_client", body["error"], "should respond with invalid client")
+	assert.NotEmpty(w.Header().Get("WWW-Authenticate"), "should challenge basic authentication")
+	w, body = tokenRequest(h, url.Values{
+		"grant_type": {"client_credentials"}, "scope": {"write:stock"},
+	}, "loader", "s3cret")
+	assert.Equal(http.StatusBadRequest, w.Code, "should reject scope")
+	assert.Equal("invalid_scope", body["error"], "should respond with invalid scope")
+}
@@ -0,0 +1,264 @@
+// Package tlsconfig provides the TLS configuration of the gRPC server and
+// of its clients. The certificates and the certificate authorities are
+// read from files that are polled for changes, so renewed certificates
+// are picked up without restarting the server.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// Files are the PEM encoded files of a TLS endpoint
+type Files struct {
+	// Cert and Key are the certificate and private key of the endpoint
+	Cert string
+	Key  string
+	// CA are the certificate authorities for verifying the peer, for the
+	// server they enable mTLS
+	CA string
+}
+
+// ReloaderParams are the attributes that are required for creating a new
+// Reloader
+type ReloaderParams struct {
+	Files    Files
+	Interval time.Duration `validate:"required"`
+	Logger   *logrus.Entry `validate:"required"`
+}
+
+// Reloader holds the certificate and the certificate authorities of the
+// files and reloads them once any of the files is changed
+type Reloader struct {
+	files    Files
+	interval time.Duration
+	logger   *logrus.Entry
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	pool     *x509.CertPool
+	stamps   map[string]stamp
+	stop     chan struct{}
+	once     sync.Once
+}
+
+// stamp identifies the version of a file
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewReloader reads the files and returns the Reloader for them
+func NewReloader(p *ReloaderParams) (*Reloader, error) {
+	if err := validator.New().Struct(p); err != nil {
+		return &Reloader{}, err
+	}
+	if (p.Files.Cert == "") != (p.Files.Key == "") {
+		return &Reloader{}, errors.New("certificate and key are required together")
+	}
+	r := &Reloader{
+		files:    p.Files,
+		interval: p.Interval,
+		logger:   p.Logger,
+		stop:     make(chan struct{}),
+	}
+	if err := r.load(); err != nil {
+		return &Reloader{}, err
+	}
+	return r, nil
+}
+
+// Start polls the files for changes in the background
+func (r *Reloader) Start() {
+	go r.run()
+}
+
+// Stop stops the polling of the files, it is safe to call on a nil
+// Reloader
+func (r *Reloader) Stop() {
+	if r == nil {
+		return
+	}
+	r.once.Do(func() {
+		close(r.stop)
+	})
+}
+
+func (r *Reloader) run() {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.reload()
+		}
+	}
+}
+
+// reload loads the files if any of them changed. The previous
+// certificates are kept if the files cannot be loaded, for example while
+// they are only partially replaced.
+func (r *Reloader) reload() {
+	if !r.changed() {
+		return
+	}
+	if err := r.load(); err != nil {
+		r.logger.WithError(err).Error("error in reloading certificates")
+		return
+	}
+	r.logger.WithField("cert", r.files.Cert).Info("reloaded certificates")
+}
+
+// changed reports whether any of the files is modified since it was
+// loaded
+func (r *Reloader) changed() bool {
+	stamps, err := r.stat()
+	if err != nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for f, s := range stamps {
+		if s != r.stamps[f] {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Reloader) stat() (map[string]stamp, error) {
+	stamps := make(map[string]stamp)
+	for _, f := range []string{r.files.Cert, r.files.Key, r.files.CA} {
+		if f == "" {
+			continue
+		}
+		fi, err := os.Stat(f)
+		if err != nil {
+			return stamps, err
+		}
+		stamps[f] = stamp{modTime: fi.ModTime(), size: fi.Size()}
+	}
+	return stamps, nil
+}
+
+func (r *Reloader) load() error {
+	stamps, err := r.stat()
+	if err != nil {
+		return err
+	}
+	var cert *tls.Certificate
+	if r.files.Cert != "" {
+		c, err := tls.LoadX509KeyPair(r.files.Cert, r.files.Key)
+		if err != nil {
+			return fmt.Errorf("error in loading certificate %s", err)
+		}
+		cert = &c
+	}
+	var pool *x509.CertPool
+	if r.files.CA != "" {
+		pem, err := os.ReadFile(r.files.CA)
+		if err != nil {
+			return fmt.Errorf("error in reading certificate authorities %s", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate authority found in %s", r.files.CA)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert, r.pool, r.stamps = cert, pool, stamps
+	return nil
+}
+
+func (r *Reloader) certificate() *tls.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert
+}
+
+func (r *Reloader) certPool() *x509.CertPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.pool
+}
+
+// ServerConfig returns the configuration of the server with the current
+// certificate. Client certificates are required and verified if the
+// certificate authorities are given. The application protocols default to
+// h2 of grpc.
+func (r *Reloader) ServerConfig(protos ...string) *tls.Config {
+	return r.serverConfig(true, protos)
+}
+
+// PublicServerConfig returns the configuration of a server with the
+// current certificate that never asks for client certificates, such as
+// one that is called by browsers
+func (r *Reloader) PublicServerConfig(protos ...string) *tls.Config {
+	return r.serverConfig(false, protos)
+}
+
+func (r *Reloader) serverConfig(clientAuth bool, protos []string) *tls.Config {
+	if len(protos) == 0 {
+		protos = []string{"h2"}
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.certificate()},
+				NextProtos:   protos,
+			}
+			if pool := r.certPool(); clientAuth && pool != nil {
+				cfg.ClientCAs = pool
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return cfg, nil
+		},
+	}
+}
+
+// ClientConfig returns the configuration of a client that presents the
+// current certificate, if any. The server is verified against the current
+// certificate authorities or else against the roots of the system.
+func (r *Reloader) ClientConfig() *tls.Config {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if r.files.Cert != "" {
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.certificate(), nil
+		}
+	}
+	if r.files.CA != "" {
+		// the pool of tls.Config is fixed, the server is verified in
+		// VerifyConnection against the reloaded pool instead
+		cfg.InsecureSkipVerify = true //nolint:gosec
+		cfg.VerifyConnection = r.verifyServer
+	}
+	return cfg
+}
+
+func (r *Reloader) verifyServer(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("server presented no certificate")
+	}
+	opts := x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         r.certPool(),
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, c := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
@@ -0,0 +1,212 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error in generating key %s", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("error in creating ca %s", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue returns the PEM encoded certificate and key for localhost
+func (ca *testCA) issue(t *testing.T, serial int64) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error in generating key %s", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("error in issuing certificate %s", err)
+	}
+	kder, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("error in encoding key %s", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder})
+}
+
+func writeFile(t *testing.T, name string, data []byte, mtime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		t.Fatalf("error in writing %s %s", name, err)
+	}
+	if err := os.Chtimes(name, mtime, mtime); err != nil {
+		t.Fatalf("error in changing time of %s %s", name, err)
+	}
+}
+
+func newTestReloader(t *testing.T, f Files) *Reloader {
+	t.Helper()
+	r, err := NewReloader(&ReloaderParams{
+		Files:    f,
+		Interval: time.Hour,
+		Logger:   logrus.NewEntry(logrus.New()),
+	})
+	if err != nil {
+		t.Fatalf("error in creating reloader %s", err)
+	}
+	return r
+}
+
+// handshake connects the client to the server and returns the error of
+// the client side
+func handshake(t *testing.T, server, client *tls.Config) error {
+	t.Helper()
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", server)
+	if err != nil {
+		t.Fatalf("error in listening %s", err)
+	}
+	defer lis.Close()
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.(*tls.Conn).Handshake() //nolint:errcheck
+		conn.Read(make([]byte, 1))   //nolint:errcheck
+	}()
+	_, port, _ := net.SplitHostPort(lis.Addr().String())
+	client.ServerName = "localhost"
+	conn, err := tls.Dial("tcp", net.JoinHostPort("127.0.0.1", port), client)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// the server rejects a missing client certificate after the client
+	// finished its handshake
+	conn.SetReadDeadline(time.Now().Add(time.Second)) //nolint:errcheck
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func TestMutualTLS(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	scert, skey := ca.issue(t, 2)
+	ccert, ckey := ca.issue(t, 3)
+	now := time.Now()
+	for name, data := range map[string][]byte{
+		"ca.pem": ca.pem, "server.pem": scert, "server-key.pem": skey,
+		"client.pem": ccert, "client-key.pem": ckey,
+	} {
+		writeFile(t, filepath.Join(dir, name), data, now)
+	}
+	server := newTestReloader(t, Files{
+		Cert: filepath.Join(dir, "server.pem"),
+		Key:  filepath.Join(dir, "server-key.pem"),
+		CA:   filepath.Join(dir, "ca.pem"),
+	})
+	client := newTestReloader(t, Files{
+		Cert: filepath.Join(dir, "client.pem"),
+		Key:  filepath.Join(dir, "client-key.pem"),
+		CA:   filepath.Join(dir, "ca.pem"),
+	})
+	assert.NoError(handshake(t, server.ServerConfig(), client.ClientConfig()), "should connect with client certificate")
+	anonymous := newTestReloader(t, Files{CA: filepath.Join(dir, "ca.pem")})
+	assert.Error(handshake(t, server.ServerConfig(), anonymous.ClientConfig()), "should reject client without certificate")
+	assert.NoError(handshake(t, server.PublicServerConfig(), anonymous.ClientConfig()), "should not ask public clients for certificate")
+	other := newTestCA(t)
+	writeFile(t, filepath.Join(dir, "other.pem"), other.pem, now)
+	untrusted := newTestReloader(t, Files{CA: filepath.Join(dir, "other.pem")})
+	assert.Error(handshake(t, server.ServerConfig(), untrusted.ClientConfig()), "should reject server of unknown authority")
+}
+
+func TestReload(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	cert, key := ca.issue(t, 2)
+	files := Files{Cert: filepath.Join(dir, "cert.pem"), Key: filepath.Join(dir, "key.pem")}
+	now := time.Now().Add(-time.Minute)
+	writeFile(t, files.Cert, cert, now)
+	writeFile(t, files.Key, key, now)
+	r := newTestReloader(t, files)
+	serial := func() int64 {
+		leaf, err := x509.ParseCertificate(r.certificate().Certificate[0])
+		if err != nil {
+			t.Fatalf("error in parsing certificate %s", err)
+		}
+		return leaf.SerialNumber.Int64()
+	}
+	r.reload()
+	assert.Equal(int64(2), serial(), "should keep unchanged certificate")
+	writeFile(t, files.Cert, []byte("partially written"), now.Add(time.Second))
+	r.reload()
+	assert.Equal(int64(2), serial(), "should keep certificate if the files are invalid")
+	cert, key = ca.issue(t, 3)
+	writeFile(t, files.Cert, cert, now.Add(2*time.Second))
+	writeFile(t, files.Key, key, now.Add(2*time.Second))
+	r.reload()
+	assert.Equal(int64(3), serial(), "should load renewed certificate")
+}
+
+func TestNewReloader(t *testing.T) {
+	_, err := NewReloader(&ReloaderParams{
+		Files:    Files{Cert: "cert.pem"},
+		Interval: time.Minute,
+		Logger:   logrus.NewEntry(logrus.New()),
+	})
+	assert.Error(t, err, "should require key along with certificate")
+}
@@ -0,0 +1,35 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// W3C trace context headers
+const (
+	traceParentHeader = "traceparent"
+	traceStateHeader  = "tracestate"
+)
+
+// TraceContext returns the W3C traceparent and tracestate of the span of
+// the context, both are empty without a span
+func TraceContext(ctx context.Context) (parent, state string) {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return carrier.Get(traceParentHeader), carrier.Get(traceStateHeader)
+}
+
+// WithTraceContext returns the context with the remote span given by the
+// W3C traceparent and tracestate, the context is returned as it is if the
+// traceparent is empty
+func WithTraceContext(ctx context.Context, parent, state string) context.Context {
+	if len(parent) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier{
+		traceParentHeader: parent,
+		traceStateHeader:  state,
+	})
+}
@@ -0,0 +1,214 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/dictyBase/modware-auth/internal/repository"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedRepo records a span for every operation of the wrapped
+// repository. The repository is not aware of the request, so the spans
+// are children of the span in the context given to WithContext.
+type tracedRepo struct {
+	repo repository.AuthRepository
+	ctx  context.Context
+}
+
+// Repository returns the repository that records the spans of its
+// operations once it is bound to a request with WithContext
+func Repository(repo repository.AuthRepository) repository.AuthRepository {
+	return &tracedRepo{repo: repo, ctx: context.Background()}
+}
+
+// WithContext returns the repository recording the spans as children of
+// the span in the context, the repository is returned unchanged if it is
+// not traced
+func WithContext(ctx context.Context, repo repository.AuthRepository) repository.AuthRepository {
+	if r, ok := repo.(*tracedRepo); ok {
+		return &tracedRepo{repo: r.repo, ctx: ctx}
+	}
+	return repo
+}
+
+// start starts the span of the operation, operations outside of a trace
+// such as the relaying of the outbox are not recorded
+func (r *tracedRepo) start(op string) trace.Span {
+	if !trace.SpanContextFromContext(r.ctx).IsValid() {
+		return trace.SpanFromContext(r.ctx)
+	}
+	_, span := Start(
+		r.ctx, "repository."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("repository.operation", op)),
+	)
+	return span
+}
+
+func (r *tracedRepo) GetToken(key string) (string, error) {
+	span := r.start("GetToken")
+	v, err := r.repo.GetToken(key)
+	End(span, err)
+	return v, err
+}
+
+func (r *tracedRepo) SetToken(key, val string, ttl time.Duration) error {
+	span := r.start("SetToken")
+	err := r.repo.SetToken(key, val, ttl)
+	End(span, err)
+	return err
+}
+
+func (r *tracedRepo) DeleteToken(key string) error {
+	span := r.start("DeleteToken")
+	err := r.repo.DeleteToken(key)
+	End(span, err)
+	return err
+}
+
+func (r *tracedRepo) HasToken(key string) (bool, error) {
+	span := r.start("HasToken")
+	ok, err := r.repo.HasToken(key)
+	End(span, err)
+	return ok, err
+}
+
+func (r *tracedRepo) SetSession(
+	sess *repository.Session,
+	token string,
+	ttl time.Duration,
+	events ...*repository.OutboxEvent,
+) error {
+	span := r.start("SetSession")
+	err := r.repo.SetSession(sess, token, ttl, events...)
+	End(span, err)
+	return err
+}
+
+func (r *tracedRepo) RefreshSession(
+	sess *repository.Session,
+	token string,
+	ttl time.Duration,
+	events ...*repository.OutboxEvent,
+) error {
+	span := r.start("RefreshSession")
+	err := r.repo.RefreshSession(sess, token, ttl, events...)
+	End(span, err)
+	return err
+}
+
+func (r *tracedRepo) GetSession(id string) (*repository.Session, error) {
+	span := r.start("GetSession")
+	sess, err := r.repo.GetSession(id)
+	End(span, err)
+	return sess, err
+}
+
+func (r *tracedRepo) ListSessions(userID int64) ([]*repository.Session, error) {
+	span := r.start("ListSessions")
+	sessions, err := r.repo.ListSessions(userID)
+	End(span, err)
+	return sessions, err
+}
+
+func (r *tracedRepo) DeleteSession(id string) error {
+	span := r.start("DeleteSession")
+	err := r.repo.DeleteSession(id)
+	End(span, err)
+	return err
+}
+
+func (r *tracedRepo) DeleteUserSessions(userID int64) (int, error) {
+	span := r.start("DeleteUserSessions")
+	n, err := r.repo.DeleteUserSessions(userID)
+	End(span, err)
+	return n, err
+}
+
+func (r *tracedRepo) LockUser(userID int64, d time.Duration) error {
+	span := r.start("LockUser")
+	err := r.repo.LockUser(userID, d)
+	End(span, err)
+	return err
+}
+
+func (r *tracedRepo) IsUserLocked(userID int64) (bool, error) {
+	span := r.start("IsUserLocked")
+	locked, err := r.repo.IsUserLocked(userID)
+	End(span, err)
+	return locked, err
+}
+
+func (r *tracedRepo) UnlockUser(userID int64) error {
+	span := r.start("UnlockUser")
+	err := r.repo.UnlockUser(userID)
+	End(span, err)
+	return err
+}
+
+func (r *tracedRepo) PendingEvents(limit int) ([]*repository.OutboxEvent, error) {
+	span := r.start("PendingEvents")
+	events, err := r.repo.PendingEvents(limit)
+	End(span, err)
+	return events, err
+}
+
+func (r *tracedRepo) ClaimEvents(limit int, lease time.Duration) ([]*repository.OutboxEvent, error) {
+	span := r.start("ClaimEvents")
+	events, err := r.repo.ClaimEvents(limit, lease)
+	End(span, err)
+	return events, err
+}
+
+func (r *tracedRepo) ReleaseEvent(id string) error {
+	span := r.start("ReleaseEvent")
+	err := r.repo.ReleaseEvent(id)
+	End(span, err)
+	return err
+}
+
+func (r *tracedRepo) DeleteEvent(id string) error {
+	span := r.start("DeleteEvent")
+	err := r.repo.DeleteEvent(id)
+	End(span, err)
+	return err
+}
+
+func (r *tracedRepo) SetClient(c *repository.Client) error {
+	span := r.start("SetClient")
+	err := r.repo.SetClient(c)
+	End(span, err)
+	return err
+}
+
+func (r *tracedRepo) GetClient(id string) (*repository.Client, error) {
+	span := r.start("GetClient")
+	c, err := r.repo.GetClient(id)
+	End(span, err)
+	return c, err
+}
+
+func (r *tracedRepo) ListClients() ([]*repository.Client, error) {
+	span := r.start("ListClients")
+	cl, err := r.repo.ListClients()
+	End(span, err)
+	return cl, err
+}
+
+func (r *tracedRepo) DeleteClient(id string) error {
+	span := r.start("DeleteClient")
+	err := r.repo.DeleteClient(id)
+	End(span, err)
+	return err
+}
+
+// Ping is not traced, it is only called by the health checks
+func (r *tracedRepo) Ping() error {
+	return r.repo.Ping()
+}
+
+func (r *tracedRepo) Close() error {
+	return r.repo.Close()
+}
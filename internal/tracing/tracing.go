@@ -0,0 +1,110 @@
+// Package tracing sets up OpenTelemetry tracing of the server and
+// provides the helpers for instrumenting its dependencies.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentation = "github.com/dictyBase/modware-auth"
+
+// Params configures the exporting of the spans
+type Params struct {
+	// Exporter is either of otlp, stdout or none
+	Exporter string
+	// Endpoint is the host:port of the OTLP gRPC collector
+	Endpoint string
+	// Insecure disables TLS for the connection to the collector
+	Insecure bool
+	// Service is the name of the service in the exported spans
+	Service string
+	// SampleRatio is the fraction of the traces that are sampled
+	SampleRatio float64
+}
+
+// Provider exports the spans until it is shut down
+type Provider struct {
+	tp *sdktrace.TracerProvider
+}
+
+// NewProvider creates the tracer provider for the exporter and installs
+// it along with the W3C trace context propagator as the global one. With
+// the none exporter spans are neither recorded nor exported, but the
+// trace context of incoming requests is still propagated.
+func NewProvider(ctx context.Context, p *Params) (*Provider, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+	var exp sdktrace.SpanExporter
+	switch p.Exporter {
+	case "none":
+		return &Provider{}, nil
+	case "stdout":
+		e, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, fmt.Errorf("error in creating stdout exporter %s", err)
+		}
+		exp = e
+	case "otlp":
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(p.Endpoint)}
+		if p.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		e, err := otlptracegrpc.New(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("error in creating otlp exporter %s", err)
+		}
+		exp = e
+	default:
+		return nil, fmt.Errorf("exporter %s is not supported", p.Exporter)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL, semconv.ServiceName(p.Service),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error in creating resource %s", err)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(
+			sdktrace.TraceIDRatioBased(p.SampleRatio),
+		)),
+	)
+	otel.SetTracerProvider(tp)
+	return &Provider{tp: tp}, nil
+}
+
+// Shutdown exports the remaining spans and stops the exporter
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p.tp == nil {
+		return nil
+	}
+	return p.tp.Shutdown(ctx)
+}
+
+// Start starts a span of the server with the global tracer provider
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, opts...)
+}
+
+// End records the error, if any, on the span and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,86 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dictyBase/modware-auth/internal/repository"
+	"github.com/dictyBase/modware-auth/internal/repository/redis"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTestRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return sr
+}
+
+func newTestRepo(t *testing.T) repository.AuthRepository {
+	t.Helper()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("unable to start miniredis %s", err)
+	}
+	t.Cleanup(mr.Close)
+	repo, err := redis.NewAuthRepo(mr.Addr(), "modware-auth-test")
+	if err != nil {
+		t.Fatalf("error connecting to redis %s", err)
+	}
+	t.Cleanup(func() { repo.Close() }) //nolint:errcheck
+	return repo
+}
+
+func TestRepository(t *testing.T) {
+	assert := assert.New(t)
+	sr := newTestRecorder(t)
+	repo := Repository(newTestRepo(t))
+	assert.NoError(repo.SetToken("jti", "token", time.Minute), "error in setting token")
+	assert.Empty(sr.Ended(), "should not record operations outside of a trace")
+	ctx, span := Start(context.Background(), "Login")
+	_, err := WithContext(ctx, repo).GetSession("missing")
+	assert.ErrorIs(err, repository.ErrSessionNotFound, "should return error of repository")
+	ok, err := WithContext(ctx, repo).HasToken("jti")
+	assert.NoError(err, "error in checking token")
+	assert.True(ok, "should find the token")
+	span.End()
+	spans := sr.Ended()
+	assert.Len(spans, 3, "should record every operation in the trace")
+	assert.Equal("repository.GetSession", spans[0].Name(), "should name span after operation")
+	assert.Equal("Error", spans[0].Status().Code.String(), "should record the error")
+	assert.Equal(span.SpanContext().SpanID(), spans[1].Parent().SpanID(), "should be child of request span")
+}
+
+func TestWithContextUntraced(t *testing.T) {
+	repo := newTestRepo(t)
+	assert.Equal(t, repo, WithContext(context.Background(), repo), "should return untraced repository")
+}
+
+func TestStartPublish(t *testing.T) {
+	assert := assert.New(t)
+	sr := newTestRecorder(t)
+	ctx, parent := Start(context.Background(), "Logout")
+	msg := nats.NewMsg("AuthService.Logout")
+	span := StartPublish(ctx, msg)
+	End(span, nil)
+	parent.End()
+	assert.NotEmpty(msg.Header.Get("traceparent"), "should inject lowercased traceparent")
+	sc := trace.SpanContextFromContext(Extract(context.Background(), msg))
+	assert.Equal(parent.SpanContext().TraceID(), sc.TraceID(), "should propagate the trace")
+	assert.Equal(span.SpanContext().SpanID(), sc.SpanID(), "should propagate the producer span")
+	spans := sr.Ended()
+	assert.Equal("AuthService.Logout publish", spans[0].Name(), "should name span after subject")
+	assert.Equal(trace.SpanKindProducer, spans[0].SpanKind(), "should record producer span")
+}